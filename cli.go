@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"coredns-multi-configuration/pkg/gitops"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+const cliUsage = `Usage:
+  coredns-manager                      start the web server
+  coredns-manager plan -f rules.yaml [-out plan.json]
+  coredns-manager apply plan.json
+  coredns-manager keygen               print a new master key for encryption.key_file
+
+Common flags:
+  -server    manager URL (env COREDNS_MANAGER_URL, default http://localhost:80)
+  -token     API token (env COREDNS_MANAGER_TOKEN)
+  -username  login username when no token is given (env AUTH_USERNAME)
+  -password  login password when no token is given (env AUTH_PASSWORD)
+`
+
+// apiClient is a minimal client for the manager API used by the CLI
+type apiClient struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// runCLI runs a command-line subcommand against a running manager
+func runCLI(args []string) int {
+	switch args[0] {
+	case "plan":
+		return runPlan(args[1:])
+	case "apply":
+		return runApply(args[1:])
+	case "keygen":
+		return runKeygen()
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+// runKeygen prints a new base64 master key for encrypting stored kubeconfigs
+func runKeygen() int {
+	key, err := store.GenerateKey()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "keygen: %v\n", err)
+		return 1
+	}
+	fmt.Println(key)
+	return 0
+}
+
+func runPlan(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	manifestFile := fs.String("f", "", "rule manifest (YAML)")
+	outFile := fs.String("out", "", "write the plan to this file for apply")
+	fs.Parse(args)
+
+	if *manifestFile == "" {
+		fmt.Fprint(os.Stderr, "plan: -f is required\n")
+		return 2
+	}
+
+	manifest, err := os.ReadFile(*manifestFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "plan: %v\n", err)
+		return 1
+	}
+
+	client, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "plan: %v\n", err)
+		return 1
+	}
+
+	var resp struct {
+		Plan    *gitops.Plan `json:"plan"`
+		Summary string       `json:"summary"`
+	}
+	if err := client.do(http.MethodPost, "/api/gitops/plan", "application/yaml", manifest, &resp); err != nil {
+		fmt.Fprintf(os.Stderr, "plan: %v\n", err)
+		return 1
+	}
+
+	fmt.Print(resp.Summary)
+
+	if *outFile != "" {
+		data, err := json.MarshalIndent(resp.Plan, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "plan: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(*outFile, data, 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "plan: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Plan saved to %s, run \"coredns-manager apply %s\" to apply it.\n", *outFile, *outFile)
+	}
+	return 0
+}
+
+func runApply(args []string) int {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	newClient := clientFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, "apply: expected a plan file\n")
+		return 2
+	}
+
+	planData, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apply: %v\n", err)
+		return 1
+	}
+
+	client, err := newClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "apply: %v\n", err)
+		return 1
+	}
+
+	var resp struct {
+		Results []gitops.ApplyResult `json:"results"`
+	}
+	applyErr := client.do(http.MethodPost, "/api/gitops/apply", "application/json", planData, &resp)
+
+	for _, r := range resp.Results {
+		if r.Success {
+			fmt.Printf("cluster %s: %d changes applied\n", r.ClusterName, r.Changes)
+		} else {
+			fmt.Printf("cluster %s: failed: %s\n", r.ClusterName, r.Error)
+		}
+	}
+	if applyErr != nil {
+		fmt.Fprintf(os.Stderr, "apply: %v\n", applyErr)
+		return 1
+	}
+	return 0
+}
+
+// clientFlags registers the connection flags and returns a constructor
+// that logs in once the flags are parsed
+func clientFlags(fs *flag.FlagSet) func() (*apiClient, error) {
+	server := fs.String("server", envOr("COREDNS_MANAGER_URL", "http://localhost:80"), "manager URL")
+	token := fs.String("token", os.Getenv("COREDNS_MANAGER_TOKEN"), "API token")
+	username := fs.String("username", os.Getenv("AUTH_USERNAME"), "login username")
+	password := fs.String("password", os.Getenv("AUTH_PASSWORD"), "login password")
+
+	return func() (*apiClient, error) {
+		client := &apiClient{
+			server: strings.TrimRight(*server, "/"),
+			token:  *token,
+			http:   &http.Client{Timeout: 2 * time.Minute},
+		}
+		if client.token != "" {
+			return client, nil
+		}
+		if *username == "" || *password == "" {
+			return nil, fmt.Errorf("either -token or -username and -password are required")
+		}
+
+		body, _ := json.Marshal(models.LoginRequest{Username: *username, Password: *password})
+		var resp models.LoginResponse
+		if err := client.do(http.MethodPost, "/api/login", "application/json", body, &resp); err != nil {
+			return nil, fmt.Errorf("login failed: %w", err)
+		}
+		client.token = resp.Token
+		return client, nil
+	}
+}
+
+// do sends a request and decodes the JSON response into out
+func (c *apiClient) do(method, path, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.server+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Decode even on error so partial results are available to the caller
+	if out != nil && len(data) > 0 {
+		json.Unmarshal(data, out)
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// envOr returns the value of an environment variable or a default
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"coredns-multi-configuration/pkg/auth"
 	"coredns-multi-configuration/pkg/config"
 	"coredns-multi-configuration/pkg/handlers"
 	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/monitor"
 	"coredns-multi-configuration/pkg/store"
 	"coredns-multi-configuration/templates"
 
@@ -17,6 +22,11 @@ import (
 )
 
 func main() {
+	// Subcommands (plan, apply, keygen) run instead of starting the server
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load("config.yaml")
 	if err != nil {
@@ -24,19 +34,56 @@ func main() {
 	}
 
 	// Initialize store
-	dataStore, err := store.New(cfg.DataDir)
+	keys, err := loadKeyring(cfg.Encryption)
+	if err != nil {
+		log.Fatalf("Failed to load encryption keys: %v", err)
+	}
+	if keys == nil {
+		log.Printf("No encryption key configured, kubeconfigs are stored in plaintext")
+	}
+	dataStore, err := store.Open(cfg.Storage.Backend, cfg.DataDir, keys)
 	if err != nil {
 		log.Fatalf("Failed to initialize store: %v", err)
 	}
+	defer dataStore.Close()
+
+	// Register the hosting cluster in in-cluster mode
+	if cfg.InCluster.Enabled {
+		registerLocalCluster(dataStore, cfg.InCluster.Name)
+	}
 
 	// Initialize auth
 	authService := auth.New(&cfg.Auth)
 
 	// Initialize K8s manager
 	k8sManager := k8s.NewManager()
+	coreDNSHandler := k8s.NewCoreDNSHandler(k8sManager, k8s.RolloutOptions{
+		Window:       cfg.Rollout.Window,
+		AutoRollback: cfg.Rollout.AutoRollback,
+	}, k8s.BackupOptions{
+		Keep: cfg.Backup.Keep,
+	})
+
+	// A rolled back change must not be re-applied by drift remediation
+	coreDNSHandler.OnRollback(func(clusterID, corefile string) {
+		if err := dataStore.SetDesiredRules(clusterID, k8s.ParseForwardRules(corefile)); err != nil {
+			log.Printf("Failed to save desired rules for cluster %s: %v", clusterID, err)
+		}
+	})
+
+	// Start background monitors
+	ctx := context.Background()
+	shadowAuditor := monitor.NewShadowAuditor(dataStore, coreDNSHandler, cfg.Shadow.AuditInterval)
+	shadowAuditor.Start(ctx)
+	driftDetector := monitor.NewDriftDetector(dataStore, coreDNSHandler, cfg.Drift.Interval, cfg.Drift.AutoRemediate)
+	driftDetector.Start(ctx)
+	driftDetector.Watch(ctx, k8sManager)
+	healthMonitor := monitor.NewHealthMonitor(dataStore, k8sManager, cfg.Health.Interval, cfg.Health.Timeout)
+	healthMonitor.Start(ctx)
+	resumeQueryLogs(dataStore, coreDNSHandler)
 
 	// Initialize handlers
-	h := handlers.New(cfg, dataStore, authService, k8sManager)
+	h := handlers.New(cfg, dataStore, authService, k8sManager, coreDNSHandler, shadowAuditor, driftDetector, healthMonitor)
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
@@ -44,6 +91,7 @@ func main() {
 
 	// Auth middleware
 	r.Use(authService.Middleware())
+	r.Use(handlers.UserContext())
 
 	// Page routes
 	r.GET("/login", func(c *gin.Context) {
@@ -64,13 +112,54 @@ func main() {
 		// Cluster management
 		api.GET("/clusters", h.ListClusters)
 		api.POST("/clusters", h.AddCluster)
+		api.POST("/clusters/import/contexts", h.ListKubeconfigContexts)
+		api.POST("/clusters/import", h.ImportClusters)
+		api.PATCH("/clusters/:id", h.UpdateCluster)
 		api.DELETE("/clusters/:id", h.DeleteCluster)
+		api.PUT("/clusters/:id/settings", h.UpdateClusterSettings)
+		api.POST("/clusters/:id/access", h.CheckClusterAccess)
+
+		// Least-privilege onboarding of new clusters
+		api.GET("/onboarding/manifests", h.GetOnboardingManifests)
+		api.POST("/onboarding/register", h.RegisterOnboardedCluster)
 
 		// CoreDNS management
 		api.GET("/clusters/:id/coredns", h.GetCoreDNSConfig)
 		api.PUT("/clusters/:id/coredns", h.UpdateCorefile)
+		api.GET("/clusters/:id/coredns/status", h.GetCoreDNSStatus)
+		api.GET("/clusters/:id/coredns/migration", h.GetMigrationPlan)
+		api.GET("/clusters/:id/coredns/logs", h.StreamCoreDNSLogs)
+		api.GET("/clusters/:id/nodelocal", h.GetNodeLocal)
+		api.POST("/clusters/:id/nodelocal/sync", h.SyncNodeLocal)
+		api.POST("/clusters/:id/coredns/restart", h.RestartCoreDNS)
+		api.GET("/clusters/:id/coredns/restart", h.GetRestartStatus)
 		api.POST("/clusters/:id/rules", h.AddForwardRule)
 		api.DELETE("/clusters/:id/rules/:namespace", h.DeleteForwardRule)
+		api.POST("/clusters/:id/rules/:namespace/querylog", h.StartQueryLog)
+		api.GET("/clusters/:id/querylogs", h.ListQueryLogs)
+		api.GET("/clusters/:id/querylogs/:session", h.GetQueryLog)
+		api.DELETE("/clusters/:id/querylogs/:session", h.StopQueryLog)
+		api.GET("/clusters/:id/backups", h.ListBackups)
+		api.GET("/clusters/:id/backups/:backup", h.GetBackup)
+		api.POST("/clusters/:id/backups/:backup/restore", h.RestoreBackup)
+		api.GET("/clusters/:id/changes", h.ListChanges)
+		api.GET("/clusters/:id/changes/:change", h.GetChange)
+
+		// Bulk rule operations on clusters matching a label selector
+		api.POST("/bulk/rules", h.BulkAddForwardRule)
+		api.DELETE("/bulk/rules/:namespace", h.BulkDeleteForwardRule)
+
+		// Audits
+		api.GET("/clusters/:id/shadowing", h.GetShadowReport)
+		api.GET("/audit/shadowing", h.ListShadowReports)
+		api.GET("/clusters/:id/drift", h.GetDriftReport)
+		api.POST("/clusters/:id/drift/remediate", h.RemediateDrift)
+		api.GET("/audit/drift", h.ListDriftReports)
+		api.GET("/topology", h.GetTopology)
+
+		// GitOps
+		api.POST("/gitops/plan", h.PlanManifest)
+		api.POST("/gitops/apply", h.ApplyPlan)
 	}
 
 	// Start server
@@ -83,6 +172,70 @@ func main() {
 	}
 }
 
+// loadKeyring loads the master keys that encrypt stored kubeconfigs,
+// returning nil if encryption is not configured
+func loadKeyring(cfg config.EncryptionConfig) (*store.Keyring, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	var primary []byte
+	var err error
+	if cfg.Key != "" {
+		primary, err = store.ParseKey(cfg.Key)
+	} else {
+		primary, err = store.ReadKeyFile(cfg.KeyFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make([][]byte, 0, len(cfg.OldKeyFiles))
+	for _, path := range cfg.OldKeyFiles {
+		key, err := store.ReadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return store.NewKeyring(primary, previous...)
+}
+
+// resumeQueryLogs removes query log directives left behind by sessions of a
+// previous run once they expire
+func resumeQueryLogs(dataStore store.Store, coreDNSHandler *k8s.CoreDNSHandler) {
+	for _, cluster := range dataStore.GetClusters() {
+		go func(cluster models.Cluster) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := coreDNSHandler.ResumeQueryLogs(ctx, &cluster); err != nil {
+				log.Printf("Failed to resume query logs on cluster %s: %v", cluster.Name, err)
+			}
+		}(cluster)
+	}
+}
+
+// registerLocalCluster adds the built-in cluster that uses the pod's ServiceAccount
+func registerLocalCluster(dataStore store.Store, name string) {
+	if !k8s.InClusterAvailable() {
+		log.Printf("In-cluster mode enabled but no ServiceAccount is mounted, skipping the %q cluster", name)
+		return
+	}
+
+	added, err := dataStore.EnsureCluster(models.Cluster{
+		ID:        models.LocalClusterID,
+		Name:      name,
+		Source:    models.SourceInCluster,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Fatalf("Failed to register in-cluster cluster: %v", err)
+	}
+	if added {
+		log.Printf("Registered the hosting cluster as %q", name)
+	}
+}
+
 // render renders a templ component
 func render(c *gin.Context, status int, template templ.Component) {
 	c.Status(status)
@@ -2,16 +2,25 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/goccy/go-yaml"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server   ServerConfig `yaml:"server"`
-	Auth     AuthConfig   `yaml:"auth"`
-	DataDir  string       `yaml:"data_dir"`
-	LogLevel string       `yaml:"log_level"`
+	Server     ServerConfig     `yaml:"server"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Shadow     ShadowConfig     `yaml:"shadow"`
+	Drift      DriftConfig      `yaml:"drift"`
+	Rollout    RolloutConfig    `yaml:"rollout"`
+	Health     HealthConfig     `yaml:"health"`
+	Backup     BackupConfig     `yaml:"backup"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Storage    StorageConfig    `yaml:"storage"`
+	InCluster  InClusterConfig  `yaml:"in_cluster"`
+	DataDir    string           `yaml:"data_dir"`
+	LogLevel   string           `yaml:"log_level"`
 }
 
 // ServerConfig represents HTTP server configuration
@@ -27,6 +36,64 @@ type AuthConfig struct {
 	JWTSecret string `yaml:"jwt_secret"`
 }
 
+// Shadow check modes
+const (
+	ShadowModeWarn  = "warn"
+	ShadowModeBlock = "block"
+)
+
+// ShadowConfig represents namespace shadowing detection configuration
+type ShadowConfig struct {
+	Mode          string        `yaml:"mode"`           // "warn" or "block"
+	AuditInterval time.Duration `yaml:"audit_interval"` // 0 disables the periodic audit
+}
+
+// DriftConfig represents desired-state drift detection configuration
+type DriftConfig struct {
+	Interval      time.Duration `yaml:"interval"`       // 0 disables the periodic check
+	AutoRemediate bool          `yaml:"auto_remediate"` // restore missing rules instead of only alerting
+}
+
+// RolloutConfig represents post-change verification configuration
+type RolloutConfig struct {
+	Window       time.Duration `yaml:"window"`        // 0 derives it from the reload plugin interval
+	AutoRollback bool          `yaml:"auto_rollback"` // restore the previous Corefile when CoreDNS breaks
+}
+
+// HealthConfig represents cluster health polling configuration
+type HealthConfig struct {
+	Interval time.Duration `yaml:"interval"` // 0 disables background polling
+	Timeout  time.Duration `yaml:"timeout"`  // per-cluster check timeout
+}
+
+// BackupConfig represents in-cluster Corefile backup configuration
+type BackupConfig struct {
+	Keep int `yaml:"keep"` // backup ConfigMaps kept per cluster, 0 disables backups
+}
+
+// StorageConfig represents the storage backend configuration
+type StorageConfig struct {
+	Backend string `yaml:"backend"` // "json" or "bolt", both kept in data_dir
+}
+
+// EncryptionConfig represents at-rest encryption of stored credentials
+type EncryptionConfig struct {
+	Key         string   `yaml:"-"`             // base64 master key from ENCRYPTION_KEY, takes precedence over KeyFile
+	KeyFile     string   `yaml:"key_file"`      // file with the base64 master key, empty disables encryption
+	OldKeyFiles []string `yaml:"old_key_files"` // previous master keys, entries sealed with them are re-encrypted on startup
+}
+
+// Enabled reports whether a master key is configured
+func (c EncryptionConfig) Enabled() bool {
+	return c.Key != "" || c.KeyFile != ""
+}
+
+// InClusterConfig represents in-cluster mode configuration
+type InClusterConfig struct {
+	Enabled bool   `yaml:"enabled"` // register the hosting cluster using the pod's ServiceAccount
+	Name    string `yaml:"name"`    // display name of the built-in cluster
+}
+
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
@@ -39,6 +106,29 @@ func DefaultConfig() *Config {
 			Password:  "admin123",
 			JWTSecret: "coredns-manager-secret-key-change-me",
 		},
+		Shadow: ShadowConfig{
+			Mode:          ShadowModeWarn,
+			AuditInterval: 10 * time.Minute,
+		},
+		Drift: DriftConfig{
+			Interval: 5 * time.Minute,
+		},
+		Rollout: RolloutConfig{
+			AutoRollback: true,
+		},
+		Health: HealthConfig{
+			Interval: 30 * time.Second,
+			Timeout:  5 * time.Second,
+		},
+		Backup: BackupConfig{
+			Keep: 10,
+		},
+		Storage: StorageConfig{
+			Backend: "json",
+		},
+		InCluster: InClusterConfig{
+			Name: "local",
+		},
 		DataDir:  "./data",
 		LogLevel: "info",
 	}
@@ -77,6 +167,18 @@ func applyEnvOverrides(cfg *Config) {
 	if jwtSecret := os.Getenv("AUTH_JWT_SECRET"); jwtSecret != "" {
 		cfg.Auth.JWTSecret = jwtSecret
 	}
+	if key := os.Getenv("ENCRYPTION_KEY"); key != "" {
+		cfg.Encryption.Key = key
+	}
+	if keyFile := os.Getenv("ENCRYPTION_KEY_FILE"); keyFile != "" {
+		cfg.Encryption.KeyFile = keyFile
+	}
+	if backend := os.Getenv("STORAGE_BACKEND"); backend != "" {
+		cfg.Storage.Backend = backend
+	}
+	if inCluster := os.Getenv("IN_CLUSTER"); inCluster != "" {
+		cfg.InCluster.Enabled = inCluster == "true"
+	}
 }
 
 // Save saves configuration to a YAML file
@@ -85,5 +187,5 @@ func (c *Config) Save(path string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0644)
+	return os.WriteFile(path, data, 0600)
 }
@@ -0,0 +1,97 @@
+package gitops
+
+import (
+	"fmt"
+	"net"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Manifest is a declarative list of clusters and the forward rules each of
+// them should have. Clusters that are not listed are left untouched; for
+// listed clusters, rules that are not in the manifest are removed.
+//
+// Example:
+//
+//	clusters:
+//	  - name: prod-a
+//	    rules:
+//	      - name: payments
+//	        target: 10.96.0.10
+//	      - name: mysql.tidb-cluster
+//	        target: 10.96.0.10
+type Manifest struct {
+	Clusters []ManifestCluster `yaml:"clusters" json:"clusters"`
+}
+
+// ManifestCluster lists the rules of one cluster, referenced by name
+type ManifestCluster struct {
+	Name  string         `yaml:"name" json:"name"`
+	Rules []ManifestRule `yaml:"rules" json:"rules"`
+}
+
+// ManifestRule is a forward rule in the same input format as the dashboard:
+// "namespace", "service.namespace" or "*.svc.cluster.local"
+type ManifestRule struct {
+	Name   string `yaml:"name" json:"name"`
+	Target string `yaml:"target" json:"target"`
+}
+
+// ParseManifest parses and validates a YAML manifest
+func ParseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks cluster names and rules for duplicates and invalid targets
+func (m *Manifest) Validate() error {
+	clusters := make(map[string]bool)
+	for _, c := range m.Clusters {
+		if c.Name == "" {
+			return fmt.Errorf("manifest cluster without name")
+		}
+		if clusters[c.Name] {
+			return fmt.Errorf("cluster %s is listed more than once", c.Name)
+		}
+		clusters[c.Name] = true
+
+		blocks := make(map[string]bool)
+		for _, rule := range c.ForwardRules() {
+			block := rule.GetDomainBlock()
+			if rule.Namespace == "" {
+				return fmt.Errorf("cluster %s: rule without name", c.Name)
+			}
+			if net.ParseIP(rule.TargetIP) == nil {
+				return fmt.Errorf("cluster %s: rule %s has invalid target %q", c.Name, block, rule.TargetIP)
+			}
+			if blocks[block] {
+				return fmt.Errorf("cluster %s: rule %s is listed more than once", c.Name, block)
+			}
+			blocks[block] = true
+		}
+	}
+	return nil
+}
+
+// ForwardRules converts the manifest rules of a cluster to forward rules
+func (c *ManifestCluster) ForwardRules() []models.ForwardRule {
+	rules := make([]models.ForwardRule, 0, len(c.Rules))
+	for _, r := range c.Rules {
+		serviceName, namespace, isFullFQDN := models.ParseNameInput(r.Name)
+		rules = append(rules, models.ForwardRule{
+			Namespace:   namespace,
+			ServiceName: serviceName,
+			TargetIP:    r.Target,
+			IsFullFQDN:  isFullFQDN,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,121 @@
+package gitops
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string // substring of the error, empty if valid
+	}{
+		{
+			name: "valid",
+			yaml: `
+clusters:
+  - name: prod-a
+    rules:
+      - name: payments
+        target: 10.96.0.10
+      - name: mysql.tidb-cluster
+        target: 10.96.0.11
+      - name: orders.svc.cluster.local
+        target: 10.96.0.12
+  - name: prod-b
+`,
+		},
+		{name: "not YAML", yaml: "clusters: [", wantErr: "failed to parse manifest"},
+		{
+			name:    "cluster without name",
+			yaml:    "clusters:\n  - rules: []\n",
+			wantErr: "without name",
+		},
+		{
+			name:    "cluster listed twice",
+			yaml:    "clusters:\n  - name: prod-a\n  - name: prod-a\n",
+			wantErr: "cluster prod-a is listed more than once",
+		},
+		{
+			name: "rule without name",
+			yaml: `
+clusters:
+  - name: prod-a
+    rules:
+      - target: 10.96.0.10
+`,
+			wantErr: "rule without name",
+		},
+		{
+			name: "invalid target",
+			yaml: `
+clusters:
+  - name: prod-a
+    rules:
+      - name: payments
+        target: dns.example.com
+`,
+			wantErr: `invalid target "dns.example.com"`,
+		},
+		{
+			name: "same block twice",
+			yaml: `
+clusters:
+  - name: prod-a
+    rules:
+      - name: payments
+        target: 10.96.0.10
+      - name: payments
+        target: 10.96.0.11
+`,
+			wantErr: "rule payments:53 is listed more than once",
+		},
+		{
+			name: "short and FQDN rule of one namespace",
+			yaml: `
+clusters:
+  - name: prod-a
+    rules:
+      - name: payments
+        target: 10.96.0.10
+      - name: payments.svc.cluster.local
+        target: 10.96.0.11
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseManifest([]byte(tt.yaml))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("ParseManifest() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("ParseManifest() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManifestForwardRules(t *testing.T) {
+	c := ManifestCluster{Name: "prod-a", Rules: []ManifestRule{
+		{Name: "payments", Target: "10.96.0.10"},
+		{Name: "mysql.tidb-cluster", Target: "10.96.0.11"},
+		{Name: "orders.svc.cluster.local", Target: "10.96.0.12"},
+	}}
+
+	rules := c.ForwardRules()
+	want := []string{"payments:53", "mysql.tidb-cluster:53", "orders.svc.cluster.local:53"}
+	if len(rules) != len(want) {
+		t.Fatalf("ForwardRules() = %+v", rules)
+	}
+	for i, rule := range rules {
+		if got := rule.GetDomainBlock(); got != want[i] {
+			t.Errorf("rule %d block = %s, want %s", i, got, want[i])
+		}
+	}
+	if !rules[2].IsFullFQDN || rules[0].IsFullFQDN {
+		t.Errorf("IsFullFQDN = %v, %v; want false, true", rules[0].IsFullFQDN, rules[2].IsFullFQDN)
+	}
+}
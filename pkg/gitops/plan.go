@@ -0,0 +1,274 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+
+	"github.com/google/uuid"
+)
+
+// Plan actions
+const (
+	ActionAdd    = "add"
+	ActionUpdate = "update"
+	ActionRemove = "remove"
+)
+
+// PlanTTL is how long a plan can be applied after it was made
+const PlanTTL = time.Hour
+
+var (
+	// ErrPlanStale is returned by Apply when a cluster's Corefile changed after planning
+	ErrPlanStale = errors.New("live state changed since the plan was made, run plan again")
+	// ErrPlanNotFound is returned by Apply for unknown, expired or already applied plans
+	ErrPlanNotFound = errors.New("plan not found or expired, run plan again")
+)
+
+// Plan is the set of rule changes needed to bring clusters to a manifest
+type Plan struct {
+	ID        string        `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Clusters  []ClusterPlan `json:"clusters"`
+}
+
+// ClusterPlan holds the changes for a single cluster
+type ClusterPlan struct {
+	ClusterID   string               `json:"cluster_id"`
+	ClusterName string               `json:"cluster_name"`
+	Fingerprint string               `json:"fingerprint"` // hash of the live Corefile at plan time
+	Desired     []models.ForwardRule `json:"desired"`
+	Changes     []Change             `json:"changes"`
+}
+
+// Change is a single rule change
+type Change struct {
+	Action   string              `json:"action"`
+	Rule     models.ForwardRule  `json:"rule"`
+	Previous *models.ForwardRule `json:"previous,omitempty"` // live rule replaced by an update
+}
+
+// ApplyResult reports the outcome of applying a plan to one cluster
+type ApplyResult struct {
+	ClusterID   string `json:"cluster_id"`
+	ClusterName string `json:"cluster_name"`
+	Changes     int    `json:"changes"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+}
+
+// liveCoreDNS reads and writes the live Corefiles, see k8s.CoreDNSHandler
+type liveCoreDNS interface {
+	GetCoreDNSInfo(ctx context.Context, cluster *models.Cluster) (*k8s.CoreDNSInfo, error)
+	ApplyPlannedRuleChanges(ctx context.Context, cluster *models.Cluster, fingerprint string, remove, add []models.ForwardRule) (*models.CorefileChange, error)
+}
+
+// Planner diffs manifests against live clusters and applies plans. Plans are
+// kept on the server until applied, so apply runs exactly what plan produced.
+type Planner struct {
+	store   store.Store
+	coreDNS liveCoreDNS
+
+	mu    sync.Mutex
+	plans map[string]*Plan // plans awaiting apply by ID
+}
+
+// NewPlanner creates a new Planner
+func NewPlanner(store store.Store, coreDNS *k8s.CoreDNSHandler) *Planner {
+	return &Planner{store: store, coreDNS: coreDNS, plans: make(map[string]*Plan)}
+}
+
+// Plan diffs a manifest against the live Corefiles of the clusters it lists
+func (p *Planner) Plan(ctx context.Context, manifest *Manifest) (*Plan, error) {
+	plan := &Plan{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now(),
+		Clusters:  make([]ClusterPlan, 0, len(manifest.Clusters)),
+	}
+
+	for _, mc := range manifest.Clusters {
+		cluster, err := p.store.GetClusterByName(mc.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := p.coreDNS.GetCoreDNSInfo(ctx, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+
+		desired := mc.ForwardRules()
+		cp := ClusterPlan{
+			ClusterID:   cluster.ID,
+			ClusterName: cluster.Name,
+			Fingerprint: k8s.CorefileFingerprint(info.Corefile),
+			Desired:     desired,
+			Changes:     make([]Change, 0),
+		}
+
+		for _, item := range models.DiffRules(desired, info.ForwardRules) {
+			switch item.Type {
+			case models.DriftMissing:
+				cp.Changes = append(cp.Changes, Change{Action: ActionAdd, Rule: *item.Desired})
+			case models.DriftChanged:
+				cp.Changes = append(cp.Changes, Change{Action: ActionUpdate, Rule: *item.Desired, Previous: item.Live})
+			case models.DriftUnmanaged:
+				cp.Changes = append(cp.Changes, Change{Action: ActionRemove, Rule: *item.Live})
+			}
+		}
+
+		plan.Clusters = append(plan.Clusters, cp)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, old := range p.plans {
+		if time.Since(old.CreatedAt) > PlanTTL {
+			delete(p.plans, id)
+		}
+	}
+	p.plans[plan.ID] = plan
+
+	return plan, nil
+}
+
+// getPlan returns a stored plan that hasn't expired
+func (p *Planner) getPlan(id string) (*Plan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	plan, ok := p.plans[id]
+	if !ok || time.Since(plan.CreatedAt) > PlanTTL {
+		return nil, ErrPlanNotFound
+	}
+	return plan, nil
+}
+
+// takePlan removes a stored plan so it is applied only once, even by
+// concurrent requests
+func (p *Planner) takePlan(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.plans[id]; !ok {
+		return ErrPlanNotFound
+	}
+	delete(p.plans, id)
+	return nil
+}
+
+// Apply executes the stored plan with the given ID. Every cluster's Corefile
+// is checked against the fingerprint recorded at plan time first; if any
+// changed, nothing is applied. Each write checks the fingerprint again.
+func (p *Planner) Apply(ctx context.Context, id string) ([]ApplyResult, error) {
+	plan, err := p.getPlan(id)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]*models.Cluster, len(plan.Clusters))
+	for i, cp := range plan.Clusters {
+		cluster, found := p.store.GetCluster(cp.ClusterID)
+		if !found {
+			return nil, fmt.Errorf("cluster %s no longer exists", cp.ClusterName)
+		}
+
+		info, err := p.coreDNS.GetCoreDNSInfo(ctx, cluster)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, err)
+		}
+		if k8s.CorefileFingerprint(info.Corefile) != cp.Fingerprint {
+			return nil, fmt.Errorf("cluster %s: %w", cluster.Name, ErrPlanStale)
+		}
+		clusters[i] = cluster
+	}
+	if err := p.takePlan(id); err != nil {
+		return nil, err
+	}
+
+	results := make([]ApplyResult, 0, len(plan.Clusters))
+	for i, cp := range plan.Clusters {
+		result := ApplyResult{
+			ClusterID:   cp.ClusterID,
+			ClusterName: cp.ClusterName,
+			Changes:     len(cp.Changes),
+		}
+
+		var remove, add []models.ForwardRule
+		for _, change := range cp.Changes {
+			switch change.Action {
+			case ActionAdd:
+				add = append(add, change.Rule)
+			case ActionUpdate:
+				if change.Previous != nil {
+					remove = append(remove, *change.Previous)
+				}
+				add = append(add, change.Rule)
+			case ActionRemove:
+				remove = append(remove, change.Rule)
+			}
+		}
+
+		_, err := p.coreDNS.ApplyPlannedRuleChanges(k8s.WithReason(ctx, "apply GitOps plan"), clusters[i], cp.Fingerprint, remove, add)
+		if errors.Is(err, k8s.ErrCorefileChanged) {
+			err = ErrPlanStale
+		}
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		if err := p.store.SetDesiredRules(cp.ClusterID, cp.Desired); err != nil {
+			result.Error = "applied, but failed to save desired rules: " + err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Success = true
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Summary renders a plan in a human readable form
+func (p *Plan) Summary() string {
+	var b strings.Builder
+	var adds, updates, removes int
+
+	for _, cp := range p.Clusters {
+		if len(cp.Changes) == 0 {
+			fmt.Fprintf(&b, "cluster %s: no changes\n", cp.ClusterName)
+			continue
+		}
+		fmt.Fprintf(&b, "cluster %s:\n", cp.ClusterName)
+		for _, change := range cp.Changes {
+			switch change.Action {
+			case ActionAdd:
+				adds++
+				fmt.Fprintf(&b, "  + %s -> %s\n", change.Rule.GetDomainBlock(), change.Rule.TargetIP)
+			case ActionUpdate:
+				updates++
+				previous := ""
+				if change.Previous != nil {
+					previous = change.Previous.TargetIP
+				}
+				fmt.Fprintf(&b, "  ~ %s -> %s (was %s)\n", change.Rule.GetDomainBlock(), change.Rule.TargetIP, previous)
+			case ActionRemove:
+				removes++
+				fmt.Fprintf(&b, "  - %s -> %s\n", change.Rule.GetDomainBlock(), change.Rule.TargetIP)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "Plan: %d to add, %d to change, %d to remove.\n", adds, updates, removes)
+	return b.String()
+}
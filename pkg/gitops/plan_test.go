@@ -0,0 +1,219 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+// fakeCoreDNS serves Corefiles from memory. Its rules are set directly
+// instead of being parsed from the Corefile.
+type fakeCoreDNS struct {
+	corefiles map[string]string
+	rules     map[string][]models.ForwardRule
+	applied   []string // IDs of the clusters written to
+}
+
+func (f *fakeCoreDNS) GetCoreDNSInfo(_ context.Context, cluster *models.Cluster) (*k8s.CoreDNSInfo, error) {
+	corefile, ok := f.corefiles[cluster.ID]
+	if !ok {
+		return nil, errors.New("unreachable")
+	}
+	return &k8s.CoreDNSInfo{Corefile: corefile, ForwardRules: f.rules[cluster.ID]}, nil
+}
+
+func (f *fakeCoreDNS) ApplyPlannedRuleChanges(_ context.Context, cluster *models.Cluster, fingerprint string, remove, add []models.ForwardRule) (*models.CorefileChange, error) {
+	if k8s.CorefileFingerprint(f.corefiles[cluster.ID]) != fingerprint {
+		return nil, k8s.ErrCorefileChanged
+	}
+	f.applied = append(f.applied, cluster.ID)
+	f.corefiles[cluster.ID] += "\n# applied"
+	return &models.CorefileChange{}, nil
+}
+
+// testPlanner returns a planner over clusters prod-a and prod-b. prod-a
+// forwards payments and orders, prod-b has no rules.
+func testPlanner(t *testing.T) (*Planner, *fakeCoreDNS, store.Store) {
+	t.Helper()
+	s, err := store.NewJSONStore(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	for _, c := range []models.Cluster{{ID: "a", Name: "prod-a"}, {ID: "b", Name: "prod-b"}} {
+		if err := s.AddCluster(c); err != nil {
+			t.Fatalf("AddCluster: %v", err)
+		}
+	}
+
+	live := &fakeCoreDNS{
+		corefiles: map[string]string{"a": ".:53 {\n}\npayments:53 {\n}\norders:53 {\n}", "b": ".:53 {\n}"},
+		rules: map[string][]models.ForwardRule{"a": {
+			{Namespace: "payments", TargetIP: "10.96.0.10"},
+			{Namespace: "orders", TargetIP: "10.96.0.11"},
+		}},
+	}
+	return &Planner{store: s, coreDNS: live, plans: make(map[string]*Plan)}, live, s
+}
+
+func testManifest(t *testing.T, yaml string) *Manifest {
+	t.Helper()
+	m, err := ParseManifest([]byte(yaml))
+	if err != nil {
+		t.Fatalf("ParseManifest: %v", err)
+	}
+	return m
+}
+
+const manifestYAML = `
+clusters:
+  - name: prod-a
+    rules:
+      - name: payments
+        target: 10.96.0.20
+      - name: mysql.tidb-cluster
+        target: 10.96.0.10
+  - name: prod-b
+    rules:
+      - name: payments
+        target: 10.96.0.20
+`
+
+func TestPlan(t *testing.T) {
+	p, live, _ := testPlanner(t)
+
+	plan, err := p.Plan(context.Background(), testManifest(t, manifestYAML))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if len(plan.Clusters) != 2 {
+		t.Fatalf("Plan() clusters = %+v, want 2", plan.Clusters)
+	}
+
+	for _, cp := range plan.Clusters {
+		if want := k8s.CorefileFingerprint(live.corefiles[cp.ClusterID]); cp.Fingerprint != want {
+			t.Errorf("fingerprint of %s = %s, want the hash of its live Corefile", cp.ClusterName, cp.Fingerprint)
+		}
+	}
+
+	want := `cluster prod-a:
+  ~ payments:53 -> 10.96.0.20 (was 10.96.0.10)
+  + mysql.tidb-cluster:53 -> 10.96.0.10
+  - orders:53 -> 10.96.0.11
+cluster prod-b:
+  + payments:53 -> 10.96.0.20
+Plan: 2 to add, 1 to change, 1 to remove.
+`
+	if got := plan.Summary(); got != want {
+		t.Errorf("Summary() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlanUnknownOrAmbiguousCluster(t *testing.T) {
+	p, _, s := testPlanner(t)
+
+	if _, err := p.Plan(context.Background(), testManifest(t, "clusters:\n  - name: prod-c\n")); err == nil {
+		t.Error("Plan() for an unregistered cluster succeeded")
+	}
+
+	// Names are unique for new clusters, but older data may hold duplicates
+	if err := s.UpdateCluster(models.Cluster{ID: "b", Name: "prod-a"}); err != nil {
+		t.Fatalf("UpdateCluster: %v", err)
+	}
+	_, err := p.Plan(context.Background(), testManifest(t, "clusters:\n  - name: prod-a\n"))
+	if err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Plan() for an ambiguous name error = %v, want ambiguous", err)
+	}
+	if len(p.plans) != 0 {
+		t.Errorf("failed Plan() stored %d plans", len(p.plans))
+	}
+}
+
+func TestApply(t *testing.T) {
+	p, live, s := testPlanner(t)
+	plan, err := p.Plan(context.Background(), testManifest(t, manifestYAML))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	results, err := p.Apply(context.Background(), plan.ID)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	for _, r := range results {
+		if !r.Success {
+			t.Errorf("Apply() on %s failed: %s", r.ClusterName, r.Error)
+		}
+	}
+	if len(live.applied) != 2 {
+		t.Errorf("Apply() wrote %v, want both clusters", live.applied)
+	}
+	if rules, ok := s.GetDesiredRules("a"); !ok || len(rules) != 2 {
+		t.Errorf("desired rules of prod-a = %+v, %v; want the manifest rules", rules, ok)
+	}
+
+	// A plan is applied only once
+	if _, err := p.Apply(context.Background(), plan.ID); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("second Apply() error = %v, want ErrPlanNotFound", err)
+	}
+}
+
+func TestApplyRejectsStalePlan(t *testing.T) {
+	p, live, _ := testPlanner(t)
+	plan, err := p.Plan(context.Background(), testManifest(t, manifestYAML))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	// Someone edits prod-b after planning
+	live.corefiles["b"] += "\n# edited by hand"
+
+	if _, err := p.Apply(context.Background(), plan.ID); !errors.Is(err, ErrPlanStale) {
+		t.Fatalf("Apply() error = %v, want ErrPlanStale", err)
+	}
+	if len(live.applied) != 0 {
+		t.Errorf("stale Apply() wrote %v, want nothing", live.applied)
+	}
+
+	// The plan is kept, but stays stale until planned again
+	if _, err := p.Apply(context.Background(), plan.ID); !errors.Is(err, ErrPlanStale) {
+		t.Errorf("retried Apply() error = %v, want ErrPlanStale", err)
+	}
+}
+
+func TestApplyRejectsExpiredPlan(t *testing.T) {
+	p, live, _ := testPlanner(t)
+	plan, err := p.Plan(context.Background(), testManifest(t, manifestYAML))
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+
+	plan.CreatedAt = time.Now().Add(-PlanTTL - time.Minute)
+
+	if _, err := p.Apply(context.Background(), plan.ID); !errors.Is(err, ErrPlanNotFound) {
+		t.Fatalf("Apply() error = %v, want ErrPlanNotFound", err)
+	}
+	if len(live.applied) != 0 {
+		t.Errorf("expired Apply() wrote %v, want nothing", live.applied)
+	}
+
+	// Expired plans are dropped when the next plan is made
+	if _, err := p.Plan(context.Background(), testManifest(t, manifestYAML)); err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	if _, ok := p.plans[plan.ID]; ok {
+		t.Error("expired plan still stored after the next Plan()")
+	}
+}
+
+func TestApplyUnknownPlan(t *testing.T) {
+	p, _, _ := testPlanner(t)
+	if _, err := p.Apply(context.Background(), "no-such-plan"); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("Apply() error = %v, want ErrPlanNotFound", err)
+	}
+}
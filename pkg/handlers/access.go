@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ============== Access Handlers ==============
+
+// CheckClusterAccess re-runs the RBAC preflight of a cluster, e.g. after its
+// permissions were changed in the cluster
+func (h *Handlers) CheckClusterAccess(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	caps, err := h.k8sManager.CheckAccess(ctx, cluster)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	cluster.Capabilities = caps
+	if err := h.store.UpdateCluster(*cluster); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cluster"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"capabilities": caps,
+		"missing":      caps.Missing(),
+	})
+}
+
+// preflight records the capability matrix of a cluster's credentials. A
+// failed review leaves the capabilities unknown rather than failing the caller.
+func (h *Handlers) preflight(ctx context.Context, cluster *models.Cluster) {
+	caps, err := h.k8sManager.CheckAccess(ctx, cluster)
+	if err != nil {
+		log.Printf("RBAC preflight failed for cluster %s: %v", cluster.Name, err)
+		cluster.Capabilities = nil
+		return
+	}
+	if missing := caps.Missing(); len(missing) > 0 {
+		log.Printf("Cluster %s credentials lack permissions for: %v", cluster.Name, missing)
+	}
+	cluster.Capabilities = caps
+}
+
+// k8sErrorStatus maps a Kubernetes API error to an HTTP status, so that
+// missing RBAC permissions are reported as 403 instead of 500
+func k8sErrorStatus(err error) int {
+	if apierrors.IsForbidden(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
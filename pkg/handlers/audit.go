@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Audit Handlers ==============
+
+// GetShadowReport runs a shadowing audit for a cluster and returns the report
+func (h *Handlers) GetShadowReport(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	report := h.shadowAuditor.AuditCluster(c.Request.Context(), cluster)
+	c.JSON(http.StatusOK, report)
+}
+
+// ListShadowReports returns the cached shadowing reports of all clusters
+func (h *Handlers) ListShadowReports(c *gin.Context) {
+	c.JSON(http.StatusOK, h.shadowAuditor.Reports())
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Backup Handlers ==============
+
+// ListBackups returns the in-cluster Corefile backups of a cluster, newest first
+func (h *Handlers) ListBackups(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	backups, err := h.coreDNSHandler.ListBackups(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, backups)
+}
+
+// GetBackup returns one Corefile backup
+func (h *Handlers) GetBackup(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	backup, err := h.coreDNSHandler.GetBackup(ctx, cluster, c.Param("backup"))
+	if errors.Is(err, k8s.ErrBackupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, backup)
+}
+
+// RestoreBackup writes the Corefile of a backup back to CoreDNS
+func (h *Handlers) RestoreBackup(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	corefile, change, err := h.coreDNSHandler.RestoreBackup(ctx, cluster, c.Param("backup"))
+	if errors.Is(err, k8s.ErrBackupNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Like a manual edit, a restore is intentional and becomes the desired state
+	if err := h.store.SetDesiredRules(cluster.ID, k8s.ParseForwardRules(corefile)); err != nil {
+		log.Printf("Failed to save desired rules for cluster %s: %v", cluster.Name, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "backup restored successfully",
+		"change_id": changeID(change),
+	})
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/topology"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ============== Bulk Rule Handlers ==============
+
+// BulkResult reports the outcome of a bulk operation on one cluster
+type BulkResult struct {
+	ClusterID      string                 `json:"cluster_id"`
+	ClusterName    string                 `json:"cluster_name"`
+	Success        bool                   `json:"success"`
+	Skipped        bool                   `json:"skipped,omitempty"` // not attempted after an earlier failure
+	Error          string                 `json:"error,omitempty"`
+	Warnings       []models.ShadowFinding `json:"warnings,omitempty"`
+	ChangeID       string                 `json:"change_id,omitempty"`       // Corefile change made on this cluster
+	LoopUnverified []string               `json:"loop_unverified,omitempty"` // unknown clusters the loop check could not follow into
+}
+
+// BulkAddForwardRuleRequest represents a bulk add forward rule request
+type BulkAddForwardRuleRequest struct {
+	Selector      string `json:"selector" binding:"required"` // e.g., "env=staging"
+	Namespace     string `json:"namespace" binding:"required"`
+	TargetIP      string `json:"target_ip" binding:"required"`
+	Force         bool   `json:"force"`
+	StopOnFailure bool   `json:"stop_on_failure"`
+}
+
+// BulkAddForwardRule adds a forward rule to every cluster matching a selector
+func (h *Handlers) BulkAddForwardRule(c *gin.Context) {
+	var req BulkAddForwardRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	clusters, err := h.store.SelectClusters(req.Selector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	serviceName, namespace, isFullFQDN := models.ParseNameInput(req.Namespace)
+	rule := models.ForwardRule{
+		Namespace:   namespace,
+		ServiceName: serviceName,
+		TargetIP:    req.TargetIP,
+		IsFullFQDN:  isFullFQDN,
+	}
+
+	// Read every cluster once and apply each added rule to the nodes, so later
+	// clusters are checked for loops through the earlier ones
+	nodes := h.topology.Collect(c.Request.Context())
+
+	results := h.runBulk(c.Request.Context(), clusters, req.StopOnFailure, func(ctx context.Context, cluster *models.Cluster, result *BulkResult) error {
+		added, err := h.addForwardRule(ctx, cluster, rule, req.Force, nodes)
+		if err != nil {
+			var shadowErr *shadowError
+			if errors.As(err, &shadowErr) {
+				result.Warnings = shadowErr.findings
+			}
+			return err
+		}
+		nodes = topology.WithRule(nodes, cluster.ID, rule)
+		result.ChangeID = changeID(added.change)
+		result.Warnings = added.findings
+		result.LoopUnverified = added.loopUnverified
+		return nil
+	})
+
+	respondBulk(c, "forward rule added", results)
+}
+
+// BulkDeleteForwardRule removes a forward rule from every cluster matching
+// the selector query, e.g. DELETE /api/bulk/rules/payments?selector=env=staging
+func (h *Handlers) BulkDeleteForwardRule(c *gin.Context) {
+	name := c.Param("namespace")
+	isFullFQDN := c.Query("fqdn") == "true"
+	stopOnFailure := c.Query("stop_on_failure") == "true"
+
+	selector := c.Query("selector")
+	if selector == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "selector required"})
+		return
+	}
+
+	clusters, err := h.store.SelectClusters(selector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results := h.runBulk(c.Request.Context(), clusters, stopOnFailure, func(ctx context.Context, cluster *models.Cluster, result *BulkResult) error {
+		change, err := h.deleteForwardRule(ctx, cluster, name, isFullFQDN)
+		result.ChangeID = changeID(change)
+		return err
+	})
+
+	respondBulk(c, "forward rule deleted", results)
+}
+
+// runBulk runs op on each cluster in order. With stopOnFailure, clusters
+// after the first failure are reported as skipped.
+func (h *Handlers) runBulk(ctx context.Context, clusters []models.Cluster, stopOnFailure bool,
+	op func(ctx context.Context, cluster *models.Cluster, result *BulkResult) error) []BulkResult {
+	results := make([]BulkResult, 0, len(clusters))
+	failed := false
+
+	for i := range clusters {
+		cluster := &clusters[i]
+		result := BulkResult{ClusterID: cluster.ID, ClusterName: cluster.Name}
+
+		if failed && stopOnFailure {
+			result.Skipped = true
+			results = append(results, result)
+			continue
+		}
+
+		opCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := op(opCtx, cluster, &result)
+		cancel()
+
+		if err != nil {
+			result.Error = err.Error()
+			failed = true
+		} else {
+			result.Success = true
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// respondBulk writes bulk results, using 207 Multi-Status if any cluster failed
+func respondBulk(c *gin.Context, action string, results []BulkResult) {
+	succeeded := 0
+	for _, r := range results {
+		if r.Success {
+			succeeded++
+		}
+	}
+
+	status := http.StatusOK
+	if succeeded < len(results) {
+		status = http.StatusMultiStatus
+	}
+
+	c.JSON(status, gin.H{
+		"message": fmt.Sprintf("%s on %d of %d clusters", action, succeeded, len(results)),
+		"results": results,
+	})
+}
+
+// validateLabels checks a cluster group and labels against Kubernetes label syntax
+func validateLabels(group string, labels map[string]string) error {
+	if group != "" {
+		if errs := validation.IsValidLabelValue(group); len(errs) > 0 {
+			return fmt.Errorf("invalid group %q: %s", group, strings.Join(errs, "; "))
+		}
+	}
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %q: %s", v, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
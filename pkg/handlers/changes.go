@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Change Handlers ==============
+
+// ListChanges returns the tracked Corefile changes of a cluster, newest first
+func (h *Handlers) ListChanges(c *gin.Context) {
+	id := c.Param("id")
+	if _, found := h.store.GetCluster(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.coreDNSHandler.Changes(id))
+}
+
+// GetChange returns the rollout status of a Corefile change
+func (h *Handlers) GetChange(c *gin.Context) {
+	id := c.Param("id")
+	if _, found := h.store.GetCluster(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	change, found := h.coreDNSHandler.GetChange(id, c.Param("change"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "change not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, change)
+}
+
+// changeID returns the ID of a Corefile change, or "" if the write was a no-op
+func changeID(change *models.CorefileChange) string {
+	if change == nil {
+		return ""
+	}
+	return change.ID
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Drift Handlers ==============
+
+// GetDriftReport compares a cluster's desired rules with its live Corefile
+func (h *Handlers) GetDriftReport(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	report := h.driftDetector.Check(c.Request.Context(), cluster, false)
+	c.JSON(http.StatusOK, report)
+}
+
+// RemediateDrift restores missing and changed rules of a cluster
+func (h *Handlers) RemediateDrift(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	report := h.driftDetector.Check(c.Request.Context(), cluster, true)
+	if report.Error != "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": report.Error, "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListDriftReports returns the cached drift reports of all clusters
+func (h *Handlers) ListDriftReports(c *gin.Context) {
+	c.JSON(http.StatusOK, h.driftDetector.Reports())
+}
+
+// adoptDesiredRules records the live rules as desired state for clusters
+// that have none yet, so a single rule change doesn't mark the rest unmanaged
+func (h *Handlers) adoptDesiredRules(ctx context.Context, cluster *models.Cluster) {
+	if _, ok := h.store.GetDesiredRules(cluster.ID); ok {
+		return
+	}
+
+	info, err := h.coreDNSHandler.GetCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return
+	}
+	if err := h.store.SetDesiredRules(cluster.ID, info.ForwardRules); err != nil {
+		log.Printf("Failed to save desired rules for cluster %s: %v", cluster.Name, err)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/gitops"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== GitOps Handlers ==============
+
+// PlanManifest diffs a YAML rule manifest against the live state of its clusters
+func (h *Handlers) PlanManifest(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	manifest, err := gitops.ParseManifest(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	plan, err := h.planner.Plan(ctx, manifest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to plan: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"plan":    plan,
+		"summary": plan.Summary(),
+	})
+}
+
+// ApplyPlanRequest represents apply plan request. The plan returned by
+// PlanManifest can be sent as is, only its ID is used.
+type ApplyPlanRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+// ApplyPlan executes a plan returned by PlanManifest
+func (h *Handlers) ApplyPlan(c *gin.Context) {
+	var req ApplyPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	results, err := h.planner.Apply(ctx, req.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gitops.ErrPlanStale):
+			status = http.StatusConflict
+		case errors.Is(err, gitops.ErrPlanNotFound):
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, r := range results {
+		if !r.Success {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "plan partially applied",
+				"results": results,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "plan applied successfully",
+		"results": results,
+	})
+}
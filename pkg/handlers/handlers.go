@@ -3,14 +3,20 @@ package handlers
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"coredns-multi-configuration/pkg/auth"
 	"coredns-multi-configuration/pkg/config"
+	"coredns-multi-configuration/pkg/gitops"
 	"coredns-multi-configuration/pkg/k8s"
 	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/monitor"
 	"coredns-multi-configuration/pkg/store"
+	"coredns-multi-configuration/pkg/topology"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,20 +25,32 @@ import (
 // Handlers contains all HTTP handlers
 type Handlers struct {
 	config         *config.Config
-	store          *store.Store
+	store          store.Store
 	auth           *auth.Auth
 	k8sManager     *k8s.Manager
 	coreDNSHandler *k8s.CoreDNSHandler
+	shadowAuditor  *monitor.ShadowAuditor
+	driftDetector  *monitor.DriftDetector
+	healthMonitor  *monitor.HealthMonitor
+	planner        *gitops.Planner
+	topology       *topology.Analyzer
 }
 
 // New creates a new Handlers instance
-func New(cfg *config.Config, store *store.Store, auth *auth.Auth, k8sManager *k8s.Manager) *Handlers {
+func New(cfg *config.Config, store store.Store, auth *auth.Auth, k8sManager *k8s.Manager,
+	coreDNSHandler *k8s.CoreDNSHandler, shadowAuditor *monitor.ShadowAuditor, driftDetector *monitor.DriftDetector,
+	healthMonitor *monitor.HealthMonitor) *Handlers {
 	return &Handlers{
 		config:         cfg,
 		store:          store,
 		auth:           auth,
 		k8sManager:     k8sManager,
-		coreDNSHandler: k8s.NewCoreDNSHandler(k8sManager),
+		coreDNSHandler: coreDNSHandler,
+		shadowAuditor:  shadowAuditor,
+		driftDetector:  driftDetector,
+		healthMonitor:  healthMonitor,
+		planner:        gitops.NewPlanner(store, coreDNSHandler),
+		topology:       topology.NewAnalyzer(store, coreDNSHandler),
 	}
 }
 
@@ -74,28 +92,37 @@ func (h *Handlers) Logout(c *gin.Context) {
 
 // ============== Cluster Handlers ==============
 
+// UserContext attaches the logged in user to the request context, so that
+// changes written to the clusters can be attributed to them
+func UserContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if username := c.GetString("username"); username != "" {
+			c.Request = c.Request.WithContext(k8s.WithUser(c.Request.Context(), username))
+		}
+		c.Next()
+	}
+}
+
 // ListClusters returns all clusters
+// The optional selector query filters by labels, e.g. ?selector=env=staging
 func (h *Handlers) ListClusters(c *gin.Context) {
-	clusters := h.store.GetClusters()
+	clusters, err := h.store.SelectClusters(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Add connection status for each cluster
+	// Add the cached connection status of each cluster
 	type ClusterWithStatus struct {
 		models.Cluster
-		Connected bool   `json:"connected"`
-		Error     string `json:"error,omitempty"`
+		models.ClusterStatus
 	}
 
 	result := make([]ClusterWithStatus, 0, len(clusters))
 	for _, cluster := range clusters {
 		cws := ClusterWithStatus{Cluster: cluster}
-
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
-		if err := h.k8sManager.TestConnection(ctx, &cluster); err != nil {
-			cws.Error = err.Error()
-		} else {
-			cws.Connected = true
-		}
-		cancel()
+		// Clusters are checked when added or loaded, until then the status is empty
+		cws.ClusterStatus, _ = h.healthMonitor.Status(cluster.ID)
 
 		// Don't expose kubeconfig
 		cws.Kubeconfig = ""
@@ -107,8 +134,25 @@ func (h *Handlers) ListClusters(c *gin.Context) {
 
 // AddClusterRequest represents add cluster request
 type AddClusterRequest struct {
-	Name       string `json:"name" binding:"required"`
-	Kubeconfig string `json:"kubeconfig" binding:"required"` // Can be base64 or plain text
+	Name        string            `json:"name" binding:"required"`
+	Group       string            `json:"group"`
+	Labels      map[string]string `json:"labels"`
+	AutoRestart bool              `json:"auto_restart"`
+	ClusterCredentials
+}
+
+// ClusterCredentials is either a kubeconfig or a server URL with a bearer
+// token, e.g. of a ServiceAccount
+type ClusterCredentials struct {
+	Kubeconfig string `json:"kubeconfig"` // Can be base64 or plain text
+	Server     string `json:"server"`
+	Token      string `json:"token"`
+	CACert     string `json:"ca_cert"` // PEM, plain or base64; system roots if empty
+}
+
+// empty reports whether no credentials were given
+func (c ClusterCredentials) empty() bool {
+	return c.Kubeconfig == "" && c.Server == "" && c.Token == "" && c.CACert == ""
 }
 
 // AddCluster adds a new cluster
@@ -119,18 +163,35 @@ func (h *Handlers) AddCluster(c *gin.Context) {
 		return
 	}
 
-	// Check if kubeconfig is already base64 encoded
-	kubeconfig := req.Kubeconfig
-	if _, err := base64.StdEncoding.DecodeString(kubeconfig); err != nil {
-		// Not base64, encode it
-		kubeconfig = base64.StdEncoding.EncodeToString([]byte(kubeconfig))
+	h.addCluster(c, req)
+}
+
+// addCluster tests the credentials of a new cluster and saves it
+func (h *Handlers) addCluster(c *gin.Context, req AddClusterRequest) {
+	if err := validateLabels(req.Group, req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.nameTaken("", req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": "cluster " + req.Name + " already exists"})
+		return
+	}
+
+	kubeconfig, err := clusterKubeconfig(req.Name, req.ClusterCredentials)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	cluster := models.Cluster{
-		ID:         uuid.New().String(),
-		Name:       req.Name,
-		Kubeconfig: kubeconfig,
-		CreatedAt:  time.Now(),
+		ID:          uuid.New().String(),
+		Name:        req.Name,
+		Group:       req.Group,
+		Labels:      req.Labels,
+		Kubeconfig:  kubeconfig,
+		CreatedAt:   time.Now(),
+		AutoRestart: req.AutoRestart,
 	}
 
 	// Test connection before saving
@@ -141,18 +202,155 @@ func (h *Handlers) AddCluster(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to connect to cluster: " + err.Error()})
 		return
 	}
+	h.preflight(ctx, &cluster)
 
+	// The store checks the name again in case another request added it meanwhile
 	if err := h.store.AddCluster(cluster); err != nil {
+		h.k8sManager.RemoveClient(cluster.ID)
+		if errors.Is(err, store.ErrClusterExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": "cluster " + req.Name + " already exists"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cluster"})
 		return
 	}
 
+	// Record the status now instead of waiting for the next poll
+	h.healthMonitor.Schedule(cluster)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "cluster added successfully",
+		"message":      "cluster added successfully",
+		"id":           cluster.ID,
+		"capabilities": cluster.Capabilities,
+	})
+}
+
+// nameTaken reports whether a cluster other than id already has the given name
+func (h *Handlers) nameTaken(id, name string) bool {
+	for _, other := range h.store.GetClusters() {
+		if other.ID != id && other.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateClusterRequest represents update cluster request. Omitted fields are
+// left unchanged.
+type UpdateClusterRequest struct {
+	Name   *string           `json:"name"`
+	Group  *string           `json:"group"`
+	Labels map[string]string `json:"labels"` // replaces all labels; {} removes them
+	ClusterCredentials
+}
+
+// UpdateCluster updates the name, labels or credentials of a cluster.
+// New credentials are tested before they replace the old ones.
+func (h *Handlers) UpdateCluster(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	var req UpdateClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		if h.nameTaken(id, name) {
+			c.JSON(http.StatusConflict, gin.H{"error": "cluster " + name + " already exists"})
+			return
+		}
+		cluster.Name = name
+	}
+	if req.Group != nil {
+		cluster.Group = *req.Group
+	}
+	if req.Labels != nil {
+		cluster.Labels = req.Labels
+	}
+	if err := validateLabels(cluster.Group, cluster.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rotated := !req.ClusterCredentials.empty()
+	if rotated {
+		if cluster.Source == models.SourceInCluster {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the in-cluster cluster uses the pod's ServiceAccount and has no credentials to update"})
+			return
+		}
+
+		kubeconfig, err := clusterKubeconfig(cluster.Name, req.ClusterCredentials)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		cluster.Kubeconfig = kubeconfig
+
+		// Test the new credentials before swapping them in
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		if err := h.k8sManager.TestCredentials(ctx, cluster); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to connect to cluster: " + err.Error()})
+			return
+		}
+		h.preflight(ctx, cluster)
+	}
+
+	if err := h.store.UpdateCluster(*cluster); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cluster"})
+		return
+	}
+
+	if rotated {
+		// Drop the client and informers built from the old credentials
+		h.k8sManager.RemoveClient(id)
+		h.healthMonitor.Schedule(*cluster)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "cluster updated successfully",
 		"id":      cluster.ID,
 	})
 }
 
+// clusterKubeconfig returns the base64 encoded kubeconfig of the credentials,
+// building one from the server, token and CA when none is given
+func clusterKubeconfig(name string, req ClusterCredentials) (string, error) {
+	if req.Kubeconfig != "" {
+		// Check if kubeconfig is already base64 encoded
+		if _, err := base64.StdEncoding.DecodeString(req.Kubeconfig); err != nil {
+			// Not base64, encode it
+			return base64.StdEncoding.EncodeToString([]byte(req.Kubeconfig)), nil
+		}
+		return req.Kubeconfig, nil
+	}
+
+	if req.Server == "" || req.Token == "" {
+		return "", errors.New("either kubeconfig or server and token are required")
+	}
+	if !strings.HasPrefix(req.Server, "https://") {
+		return "", errors.New("server must be an https:// URL")
+	}
+
+	data, err := k8s.BuildTokenKubeconfig(name, req.Server, strings.TrimSpace(req.Token), decodeBase64OrText(strings.TrimSpace(req.CACert)))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
 // DeleteCluster deletes a cluster
 func (h *Handlers) DeleteCluster(c *gin.Context) {
 	id := c.Param("id")
@@ -161,7 +359,18 @@ func (h *Handlers) DeleteCluster(c *gin.Context) {
 		return
 	}
 
+	// The built-in cluster would be registered again on the next start
+	if cluster, found := h.store.GetCluster(id); found && cluster.Source == models.SourceInCluster && h.config.InCluster.Enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the built-in in-cluster cluster cannot be deleted while in-cluster mode is enabled"})
+		return
+	}
+
 	h.k8sManager.RemoveClient(id)
+	h.shadowAuditor.Forget(id)
+	h.driftDetector.Forget(id)
+	h.healthMonitor.Forget(id)
+	h.coreDNSHandler.ForgetChanges(id)
+	h.coreDNSHandler.ForgetQueryLogs(id)
 
 	if err := h.store.DeleteCluster(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete cluster"})
@@ -187,13 +396,34 @@ func (h *Handlers) GetCoreDNSConfig(c *gin.Context) {
 
 	info, err := h.coreDNSHandler.GetCoreDNSInfo(ctx, cluster)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, info)
 }
 
+// GetCoreDNSStatus returns the CoreDNS workloads, pods and recent pod events of a cluster
+func (h *Handlers) GetCoreDNSStatus(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.coreDNSHandler.GetCoreDNSStatus(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
 // UpdateCorefileRequest represents update corefile request
 type UpdateCorefileRequest struct {
 	Corefile string `json:"corefile" binding:"required"`
@@ -217,18 +447,28 @@ func (h *Handlers) UpdateCorefile(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	if err := h.coreDNSHandler.UpdateCorefile(ctx, cluster, req.Corefile); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	change, err := h.coreDNSHandler.UpdateCorefile(k8s.WithReason(ctx, "edit Corefile"), cluster, req.Corefile)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "corefile updated successfully"})
+	// A manual edit through the manager is intentional: it becomes the desired state
+	if err := h.store.SetDesiredRules(cluster.ID, k8s.ParseForwardRules(req.Corefile)); err != nil {
+		log.Printf("Failed to save desired rules for cluster %s: %v", cluster.Name, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "corefile updated successfully",
+		"change_id": changeID(change),
+	})
 }
 
 // AddForwardRuleRequest represents add forward rule request
 type AddForwardRuleRequest struct {
 	Namespace string `json:"namespace" binding:"required"`
 	TargetIP  string `json:"target_ip" binding:"required"`
+	Force     bool   `json:"force"` // add even if the rule shadows a local namespace or service
 }
 
 // AddForwardRule adds a forward rule to CoreDNS
@@ -259,12 +499,91 @@ func (h *Handlers) AddForwardRule(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	if err := h.coreDNSHandler.AddForwardRule(ctx, cluster, rule); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	added, err := h.addForwardRule(ctx, cluster, rule, req.Force, h.topology.Collect(ctx))
+	if err != nil {
+		var shadowErr *shadowError
+		if errors.As(err, &shadowErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "findings": shadowErr.findings})
+			return
+		}
+		var loopErr *loopError
+		if errors.As(err, &loopErr) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "forward rule added successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "forward rule added successfully",
+		"warnings":        added.findings,
+		"change_id":       changeID(added.change),
+		"loop_unverified": added.loopUnverified,
+	})
+}
+
+// ruleAddition is the outcome of adding a forward rule
+type ruleAddition struct {
+	findings       []models.ShadowFinding // shadowing warnings
+	change         *models.CorefileChange
+	loopUnverified []string // unknown clusters the loop check could not follow into
+}
+
+// shadowError is returned when a rule shadows local resources in block mode
+type shadowError struct {
+	findings []models.ShadowFinding
+}
+
+func (e *shadowError) Error() string {
+	return "forward rule shadows local resources: " + e.findings[0].Message
+}
+
+// loopError is returned when a rule would create a forwarding loop across clusters
+type loopError struct {
+	description string
+}
+
+func (e *loopError) Error() string {
+	return "forward rule rejected: " + e.description
+}
+
+// addForwardRule checks a rule for forwarding loops against the collected
+// topology nodes and for shadowing, adds it to the Corefile and records it in
+// the desired state. A loop check that could not follow the rule into an
+// unknown cluster does not block the rule but is reported along with the
+// shadow findings.
+func (h *Handlers) addForwardRule(ctx context.Context, cluster *models.Cluster, rule models.ForwardRule, force bool, nodes []topology.Node) (*ruleAddition, error) {
+	// Loops are never allowed, force only overrides shadowing
+	loop, description, unverified := topology.WouldCreateCycle(nodes, cluster.ID, rule)
+	if loop {
+		return nil, &loopError{description: description}
+	}
+	if len(unverified) > 0 {
+		log.Printf("Loop check for %s on cluster %s is partial, unknown clusters: %s",
+			rule.GetFullName(), cluster.Name, strings.Join(unverified, ", "))
+	}
+
+	// Check whether the rule hides a namespace or service that exists locally
+	findings, err := h.coreDNSHandler.CheckShadowing(ctx, cluster, []models.ForwardRule{rule}, h.store.GetClusters())
+	if err != nil {
+		log.Printf("Shadow check for cluster %s failed: %v", cluster.Name, err)
+	}
+	if len(findings) > 0 && h.config.Shadow.Mode == config.ShadowModeBlock && !force {
+		return nil, &shadowError{findings: findings}
+	}
+
+	h.adoptDesiredRules(ctx, cluster)
+
+	change, err := h.coreDNSHandler.AddForwardRule(ctx, cluster, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.store.AddDesiredRule(cluster.ID, rule); err != nil {
+		log.Printf("Failed to save desired rules for cluster %s: %v", cluster.Name, err)
+	}
+	return &ruleAddition{findings: findings, change: change, loopUnverified: unverified}, nil
 }
 
 // DeleteForwardRule removes a forward rule from CoreDNS
@@ -282,10 +601,31 @@ func (h *Handlers) DeleteForwardRule(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	if err := h.coreDNSHandler.DeleteForwardRule(ctx, cluster, name, isFullFQDN); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	change, err := h.deleteForwardRule(ctx, cluster, name, isFullFQDN)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "forward rule deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "forward rule deleted successfully",
+		"change_id": changeID(change),
+	})
+}
+
+// deleteForwardRule removes a rule from the Corefile and from the desired state
+func (h *Handlers) deleteForwardRule(ctx context.Context, cluster *models.Cluster, name string, isFullFQDN bool) (*models.CorefileChange, error) {
+	h.adoptDesiredRules(ctx, cluster)
+
+	change, err := h.coreDNSHandler.DeleteForwardRule(ctx, cluster, name, isFullFQDN)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName, namespace, _ := models.ParseNameInput(name)
+	rule := models.ForwardRule{Namespace: namespace, ServiceName: serviceName, IsFullFQDN: isFullFQDN}
+	if err := h.store.DeleteDesiredRule(cluster.ID, rule.GetDomainBlock()); err != nil {
+		log.Printf("Failed to save desired rules for cluster %s: %v", cluster.Name, err)
+	}
+	return change, nil
 }
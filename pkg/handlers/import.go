@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ============== Import Handlers ==============
+
+// ListKubeconfigContextsRequest represents list kubeconfig contexts request
+type ListKubeconfigContextsRequest struct {
+	Kubeconfig string `json:"kubeconfig" binding:"required"` // Can be base64 or plain text
+}
+
+// ListKubeconfigContexts returns the contexts of a kubeconfig for import
+func (h *Handlers) ListKubeconfigContexts(c *gin.Context) {
+	var req ListKubeconfigContextsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	contexts, err := k8s.ListContexts(decodeBase64OrText(req.Kubeconfig))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contexts)
+}
+
+// ImportClustersRequest represents import clusters request
+type ImportClustersRequest struct {
+	Kubeconfig string            `json:"kubeconfig" binding:"required"` // Can be base64 or plain text
+	Contexts   []string          `json:"contexts" binding:"required"`   // contexts to register as clusters
+	Group      string            `json:"group"`
+	Labels     map[string]string `json:"labels"`
+}
+
+// ImportClusters registers selected contexts of a kubeconfig as separate
+// clusters, each storing only its own context. Connections are tested in parallel.
+func (h *Handlers) ImportClusters(c *gin.Context) {
+	var req ImportClustersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+	if len(req.Contexts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no contexts selected"})
+		return
+	}
+
+	if err := validateLabels(req.Group, req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Contexts))
+	for _, name := range req.Contexts {
+		if seen[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("context %s selected more than once", name)})
+			return
+		}
+		seen[name] = true
+	}
+
+	kubeconfig := decodeBase64OrText(req.Kubeconfig)
+	results := make([]BulkResult, len(req.Contexts))
+
+	var wg sync.WaitGroup
+	for i, name := range req.Contexts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			results[i] = h.importContext(c.Request.Context(), kubeconfig, name, req.Group, req.Labels)
+		}(i, name)
+	}
+	wg.Wait()
+
+	respondBulk(c, "imported", results)
+}
+
+// importContext tests and registers one kubeconfig context as a cluster named after it
+func (h *Handlers) importContext(ctx context.Context, kubeconfig []byte, name, group string, labels map[string]string) BulkResult {
+	result := BulkResult{ClusterName: name}
+
+	if h.nameTaken("", name) {
+		result.Error = fmt.Sprintf("cluster %s already exists", name)
+		return result
+	}
+
+	minimal, err := k8s.ExtractContext(kubeconfig, name)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	clusterLabels := make(map[string]string, len(labels))
+	for k, v := range labels {
+		clusterLabels[k] = v
+	}
+
+	cluster := models.Cluster{
+		ID:         uuid.New().String(),
+		Name:       name,
+		Group:      group,
+		Labels:     clusterLabels,
+		Kubeconfig: base64.StdEncoding.EncodeToString(minimal),
+		CreatedAt:  time.Now(),
+	}
+	result.ClusterID = cluster.ID
+
+	testCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := h.k8sManager.TestConnection(testCtx, &cluster); err != nil {
+		h.k8sManager.RemoveClient(cluster.ID)
+		result.ClusterID = ""
+		result.Error = "failed to connect to cluster: " + err.Error()
+		return result
+	}
+	h.preflight(testCtx, &cluster)
+
+	if err := h.store.AddCluster(cluster); err != nil {
+		h.k8sManager.RemoveClient(cluster.ID)
+		result.ClusterID = ""
+		if errors.Is(err, store.ErrClusterExists) {
+			result.Error = fmt.Sprintf("cluster %s already exists", name)
+		} else {
+			result.Error = "failed to save cluster"
+		}
+		return result
+	}
+
+	h.healthMonitor.Schedule(cluster)
+
+	result.Success = true
+	return result
+}
+
+// decodeBase64OrText accepts input such as a kubeconfig as base64 or plain text
+func decodeBase64OrText(input string) []byte {
+	if data, err := base64.StdEncoding.DecodeString(input); err == nil {
+		return data
+	}
+	return []byte(input)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Log Handlers ==============
+
+// StreamCoreDNSLogs streams the logs of all CoreDNS pods of a cluster as
+// server-sent events, e.g. GET /api/clusters/:id/coredns/logs?filter=payments&tail=50
+func (h *Handlers) StreamCoreDNSLogs(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	tailLines := int64(50)
+	if tail := c.Query("tail"); tail != "" {
+		n, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tail"})
+			return
+		}
+		tailLines = n
+	}
+
+	ctx := c.Request.Context()
+	lines := make(chan models.LogLine, 100)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.coreDNSHandler.StreamLogs(ctx, cluster, c.Query("filter"), tailLines, lines)
+	}()
+
+	// Keep idle connections open through proxies
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line := <-lines:
+			c.SSEvent("log", line)
+			return true
+		case err := <-errCh:
+			if err != nil {
+				c.SSEvent("error", gin.H{"error": err.Error()})
+			}
+			return false
+		case <-keepAlive.C:
+			c.SSEvent("ping", "")
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
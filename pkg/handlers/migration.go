@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Migration Handlers ==============
+
+// GetMigrationPlan checks the Corefile against the running CoreDNS version
+// and previews its migration to the target version given by ?target=
+func (h *Handlers) GetMigrationPlan(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	report, err := h.coreDNSHandler.PlanMigration(ctx, cluster, c.Query("target"))
+	if errors.Is(err, k8s.ErrInvalidTargetVersion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== NodeLocal DNSCache Handlers ==============
+
+// GetNodeLocal returns the NodeLocal DNSCache Corefile of a cluster, if it runs one
+func (h *Handlers) GetNodeLocal(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	info, err := h.coreDNSHandler.GetNodeLocalInfo(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nodelocal": info,
+		"mirror":    cluster.MirrorNodeLocal,
+	})
+}
+
+// SyncNodeLocal mirrors the current CoreDNS forward rules into NodeLocal DNSCache
+func (h *Handlers) SyncNodeLocal(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	info, err := h.coreDNSHandler.GetCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	detected, err := h.coreDNSHandler.SyncNodeLocal(ctx, cluster, info.ForwardRules)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if !detected {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node-local-dns is not deployed in this cluster"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "forward rules mirrored to node-local-dns",
+		"rules":   len(info.ForwardRules),
+	})
+}
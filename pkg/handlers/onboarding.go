@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"coredns-multi-configuration/pkg/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Onboarding Handlers ==============
+
+// GetOnboardingManifests returns the least-privilege manifests to apply in a
+// new cluster before registering it
+func (h *Handlers) GetOnboardingManifests(c *gin.Context) {
+	manifests, err := k8s.OnboardingManifests(c.DefaultQuery("name", k8s.DefaultServiceAccountName), h.config.Backup.Keep)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", manifests)
+}
+
+// RegisterOnboardedClusterRequest represents register onboarded cluster request.
+// The token and CA are taken from Secret when given, else from Token and CACert.
+type RegisterOnboardedClusterRequest struct {
+	AddClusterRequest
+	Secret string `json:"secret"` // token Secret as YAML or JSON, e.g. from kubectl get secret -o yaml
+}
+
+// RegisterOnboardedCluster builds the minimal kubeconfig from the token of
+// the onboarding ServiceAccount and registers the cluster
+func (h *Handlers) RegisterOnboardedCluster(c *gin.Context) {
+	var req RegisterOnboardedClusterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request: " + err.Error()})
+		return
+	}
+
+	if req.Kubeconfig != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "onboarding takes a server and token, not a kubeconfig"})
+		return
+	}
+
+	if req.Secret != "" {
+		token, ca, err := k8s.ParseTokenSecret([]byte(req.Secret))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Token = token
+		if len(ca) > 0 {
+			req.CACert = string(ca)
+		}
+	}
+
+	h.addCluster(c, req.AddClusterRequest)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Query Log Handlers ==============
+
+// StartQueryLogRequest represents start query log request
+type StartQueryLogRequest struct {
+	Minutes int `json:"minutes"` // defaults to 5
+}
+
+// StartQueryLog temporarily enables query logging for a forward rule,
+// e.g. POST /api/clusters/:id/rules/payments/querylog?fqdn=false
+func (h *Handlers) StartQueryLog(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	var req StartQueryLogRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+	}
+	if req.Minutes == 0 {
+		req.Minutes = 5
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	session, err := h.coreDNSHandler.StartQueryLog(ctx, cluster, c.Param("namespace"), c.Query("fqdn") == "true",
+		time.Duration(req.Minutes)*time.Minute)
+	switch {
+	case errors.Is(err, k8s.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, k8s.ErrQueryLogEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "query logging enabled",
+		"session":   session,
+		"change_id": session.ChangeID,
+	})
+}
+
+// ListQueryLogs returns the query log sessions of a cluster, newest first
+func (h *Handlers) ListQueryLogs(c *gin.Context) {
+	id := c.Param("id")
+	if _, found := h.store.GetCluster(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.coreDNSHandler.QueryLogs(id))
+}
+
+// GetQueryLog returns a query log session with the captured lines
+func (h *Handlers) GetQueryLog(c *gin.Context) {
+	id := c.Param("id")
+	if _, found := h.store.GetCluster(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	session, found := h.coreDNSHandler.GetQueryLog(id, c.Param("session"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "query log session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// StopQueryLog ends a query log session early and removes the log directive
+func (h *Handlers) StopQueryLog(c *gin.Context) {
+	id := c.Param("id")
+	if _, found := h.store.GetCluster(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	if err := h.coreDNSHandler.StopQueryLog(id, c.Param("session")); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "query logging stopping"})
+}
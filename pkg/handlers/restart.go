@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Restart Handlers ==============
+
+// RestartCoreDNS starts a rolling restart of the CoreDNS Deployment, needed
+// for Corefile changes to take effect when the reload plugin is not enabled
+func (h *Handlers) RestartCoreDNS(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.coreDNSHandler.RestartCoreDNS(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "coredns restart started",
+		"status":  status,
+	})
+}
+
+// GetRestartStatus returns the progress of a CoreDNS rolling restart
+func (h *Handlers) GetRestartStatus(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	status, err := h.coreDNSHandler.GetRestartStatus(ctx, cluster)
+	if err != nil {
+		c.JSON(k8sErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// UpdateClusterSettingsRequest represents update cluster settings request
+type UpdateClusterSettingsRequest struct {
+	AutoRestart     *bool `json:"auto_restart"`
+	MirrorNodeLocal *bool `json:"mirror_node_local"`
+}
+
+// UpdateClusterSettings updates the per-cluster behaviour settings
+func (h *Handlers) UpdateClusterSettings(c *gin.Context) {
+	id := c.Param("id")
+	cluster, found := h.store.GetCluster(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cluster not found"})
+		return
+	}
+
+	var req UpdateClusterSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if req.AutoRestart != nil {
+		cluster.AutoRestart = *req.AutoRestart
+	}
+	if req.MirrorNodeLocal != nil {
+		cluster.MirrorNodeLocal = *req.MirrorNodeLocal
+	}
+
+	if err := h.store.UpdateCluster(*cluster); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save cluster"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "cluster settings updated",
+		"auto_restart":      cluster.AutoRestart,
+		"mirror_node_local": cluster.MirrorNodeLocal,
+	})
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"coredns-multi-configuration/pkg/topology"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============== Topology Handlers ==============
+
+// GetTopology returns the forwarding graph of all clusters with cycles and dead-ends
+func (h *Handlers) GetTopology(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	nodes := h.topology.Collect(ctx)
+	c.JSON(http.StatusOK, topology.Analyze(nodes))
+}
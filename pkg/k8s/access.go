@@ -0,0 +1,198 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// accessCheck is one SelfSubjectAccessReview of the RBAC preflight. A
+// capability is granted only if all of its checks are allowed.
+type accessCheck struct {
+	attributes authorizationv1.ResourceAttributes
+	capability func(caps *models.Capabilities) *bool
+}
+
+func readCorefile(caps *models.Capabilities) *bool   { return &caps.ReadCorefile }
+func updateCorefile(caps *models.Capabilities) *bool { return &caps.UpdateCorefile }
+func readService(caps *models.Capabilities) *bool    { return &caps.ReadService }
+func readLogs(caps *models.Capabilities) *bool       { return &caps.ReadLogs }
+func restart(caps *models.Capabilities) *bool        { return &caps.Restart }
+func readStatus(caps *models.Capabilities) *bool     { return &caps.ReadStatus }
+func nodeLocal(caps *models.Capabilities) *bool      { return &caps.NodeLocal }
+func backup(caps *models.Capabilities) *bool         { return &caps.Backup }
+
+// accessChecks are the permissions the manager needs for its CoreDNS operations.
+// The ConfigMap and Service are read through informers, which list and watch
+// them by name. Every request the manager makes for an operation has a check
+// here, so the dashboard only enables what the credentials allow.
+var accessChecks = []accessCheck{
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Resource: "configmaps", Name: CoreDNSConfigMapName},
+		capability: readCorefile,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "configmaps", Name: CoreDNSConfigMapName},
+		capability: readCorefile,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "watch", Resource: "configmaps", Name: CoreDNSConfigMapName},
+		capability: readCorefile,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "update", Resource: "configmaps", Name: CoreDNSConfigMapName},
+		capability: updateCorefile,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Resource: "services", Name: KubeDNSServiceName},
+		capability: readService,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "services", Name: KubeDNSServiceName},
+		capability: readService,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "watch", Resource: "services", Name: KubeDNSServiceName},
+		capability: readService,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		capability: func(caps *models.Capabilities) *bool { return &caps.ListPods },
+	},
+	// Log streams find the CoreDNS pods first
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		capability: readLogs,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Resource: "pods", Subresource: "log"},
+		capability: readLogs,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Group: "apps", Resource: "deployments", Name: CoreDNSDeploymentName},
+		capability: restart,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "patch", Group: "apps", Resource: "deployments", Name: CoreDNSDeploymentName},
+		capability: restart,
+	},
+	// The status panel lists the CoreDNS workloads, their pods and events
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Group: "apps", Resource: "deployments"},
+		capability: readStatus,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Group: "apps", Resource: "daemonsets"},
+		capability: readStatus,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "pods"},
+		capability: readStatus,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "list", Resource: "events"},
+		capability: readStatus,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Resource: "configmaps", Name: NodeLocalDNSName},
+		capability: nodeLocal,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "update", Resource: "configmaps", Name: NodeLocalDNSName},
+		capability: nodeLocal,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Group: "apps", Resource: "daemonsets", Name: NodeLocalDNSName},
+		capability: nodeLocal,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "create", Resource: "events"},
+		capability: func(caps *models.Capabilities) *bool { return &caps.RecordEvents },
+	},
+	// Backup slots are granted together, the first one stands for all of them
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "create", Resource: "configmaps"},
+		capability: backup,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "get", Resource: "configmaps", Name: BackupConfigMapPrefix + "0"},
+		capability: backup,
+	},
+	{
+		attributes: authorizationv1.ResourceAttributes{Verb: "update", Resource: "configmaps", Name: BackupConfigMapPrefix + "0"},
+		capability: backup,
+	},
+}
+
+// CheckAccess runs the RBAC preflight of a cluster's credentials with
+// SelfSubjectAccessReviews and returns the capability matrix. Like
+// TestCredentials it uses a new client, so credentials can be checked before
+// they are saved.
+func (m *Manager) CheckAccess(ctx context.Context, cluster *models.Cluster) (*models.Capabilities, error) {
+	client, err := m.createClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	allowed := make([]bool, len(accessChecks))
+	errs := make([]error, len(accessChecks))
+
+	for i, check := range accessChecks {
+		wg.Add(1)
+		go func(i int, check accessCheck) {
+			defer wg.Done()
+			allowed[i], errs[i] = reviewAccess(ctx, client, check.attributes)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	caps := capabilitiesFrom(accessChecks, allowed)
+	caps.CheckedAt = time.Now()
+	return caps, nil
+}
+
+// capabilitiesFrom builds the capability matrix from the results of checks.
+// A capability is granted only if every check mapped to it is allowed.
+func capabilitiesFrom(checks []accessCheck, allowed []bool) *models.Capabilities {
+	caps := &models.Capabilities{}
+	seen := make(map[*bool]bool)
+	for i, check := range checks {
+		field := check.capability(caps)
+		if !seen[field] {
+			seen[field] = true
+			*field = allowed[i]
+		} else {
+			*field = *field && allowed[i]
+		}
+	}
+	return caps
+}
+
+// reviewAccess asks the API server whether the current credentials may
+// perform an action in the CoreDNS namespace
+func reviewAccess(ctx context.Context, client kubernetes.Interface, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	attributes.Namespace = CoreDNSNamespace
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+	}
+
+	result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s %s: %w", attributes.Verb, attributes.Resource, err)
+	}
+	return result.Status.Allowed, nil
+}
@@ -0,0 +1,106 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+func TestCapabilitiesFrom(t *testing.T) {
+	all := []string{"read_corefile", "update_corefile", "read_service", "list_pods", "read_logs",
+		"restart", "read_status", "node_local", "record_events", "backup"}
+
+	tests := []struct {
+		name   string
+		denied func(a authorizationv1.ResourceAttributes) bool // permissions the API server denies
+		want   []string                                        // missing capabilities
+	}{
+		{
+			name:   "everything allowed",
+			denied: func(a authorizationv1.ResourceAttributes) bool { return false },
+		},
+		{
+			name:   "everything denied",
+			denied: func(a authorizationv1.ResourceAttributes) bool { return true },
+			want:   all,
+		},
+		{
+			name: "informer needs list on the Corefile",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "list" && a.Resource == "configmaps"
+			},
+			want: []string{"read_corefile"},
+		},
+		{
+			name: "informer needs watch on the Service",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "watch" && a.Resource == "services"
+			},
+			want: []string{"read_service"},
+		},
+		{
+			name: "listing pods is shared by logs and status",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "list" && a.Resource == "pods"
+			},
+			want: []string{"list_pods", "read_logs", "read_status"},
+		},
+		{
+			name: "restart reads the Deployment first",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "get" && a.Resource == "deployments"
+			},
+			want: []string{"restart"},
+		},
+		{
+			name: "status lists events",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "list" && a.Resource == "events"
+			},
+			want: []string{"read_status"},
+		},
+		{
+			name: "node-local-dns ConfigMap",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "update" && a.Name == NodeLocalDNSName
+			},
+			want: []string{"node_local"},
+		},
+		{
+			name: "change events",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "create" && a.Resource == "events"
+			},
+			want: []string{"record_events"},
+		},
+		{
+			name: "backup slots by name",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "update" && a.Name == BackupConfigMapPrefix+"0"
+			},
+			want: []string{"backup"},
+		},
+		{
+			name: "backup needs create",
+			denied: func(a authorizationv1.ResourceAttributes) bool {
+				return a.Verb == "create" && a.Resource == "configmaps"
+			},
+			want: []string{"backup"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := make([]bool, len(accessChecks))
+			for i, check := range accessChecks {
+				allowed[i] = !tt.denied(check.attributes)
+			}
+
+			got := capabilitiesFrom(accessChecks, allowed).Missing()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Missing() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
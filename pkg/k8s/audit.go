@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Annotations set on the CoreDNS ConfigMap by every write, so that anyone
+// looking at it can tell the manager changed it, who did and why
+const (
+	LastAppliedByAnnotation = "coredns-manager/last-applied-by"
+	RevisionAnnotation      = "coredns-manager/revision"
+	ReasonAnnotation        = "coredns-manager/reason"
+	TimestampAnnotation     = "coredns-manager/timestamp"
+)
+
+// Change event settings
+const (
+	EventComponent       = "coredns-manager"
+	CorefileUpdatedEvent = "CorefileUpdated"
+)
+
+// SystemUser is recorded for changes made by the manager itself, e.g.
+// automatic rollbacks and drift remediation
+const SystemUser = "system"
+
+type userKey struct{}
+
+type reasonKey struct{}
+
+// WithUser returns a context carrying the name of the user making a change
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user of a context, SystemUser if there is none
+func UserFrom(ctx context.Context) string {
+	if user, ok := ctx.Value(userKey{}).(string); ok && user != "" {
+		return user
+	}
+	return SystemUser
+}
+
+// WithReason returns a context carrying why a change is made, e.g.
+// "remediate drift". It takes precedence over the reason derived by the
+// CoreDNSHandler method making the change.
+func WithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// withDefaultReason sets the reason of a context unless one is set already
+func withDefaultReason(ctx context.Context, reason string) context.Context {
+	if reasonFrom(ctx) != "" {
+		return ctx
+	}
+	return WithReason(ctx, reason)
+}
+
+func reasonFrom(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+// annotateChange sets the change annotations on the CoreDNS ConfigMap before
+// it is written and returns the new revision
+func annotateChange(configMap *corev1.ConfigMap, user, reason string) int {
+	revision, _ := strconv.Atoi(configMap.Annotations[RevisionAnnotation])
+	revision++
+
+	if configMap.Annotations == nil {
+		configMap.Annotations = make(map[string]string)
+	}
+	configMap.Annotations[LastAppliedByAnnotation] = user
+	configMap.Annotations[RevisionAnnotation] = strconv.Itoa(revision)
+	configMap.Annotations[ReasonAnnotation] = reason
+	configMap.Annotations[TimestampAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return revision
+}
+
+// recordChangeEvent emits a Kubernetes Event on the written CoreDNS ConfigMap.
+// Failures are only logged, the change itself already succeeded.
+func recordChangeEvent(ctx context.Context, client kubernetes.Interface, configMap *corev1.ConfigMap, revision int, user, reason string) {
+	message := fmt.Sprintf("Corefile revision %d applied by %s", revision, user)
+	if reason != "" {
+		message += ": " + reason
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", configMap.Name, now.UnixNano()),
+			Namespace: configMap.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      "v1",
+			Kind:            "ConfigMap",
+			Name:            configMap.Name,
+			Namespace:       configMap.Namespace,
+			UID:             configMap.UID,
+			ResourceVersion: configMap.ResourceVersion,
+		},
+		Reason:         CorefileUpdatedEvent,
+		Message:        message,
+		Type:           corev1.EventTypeNormal,
+		Source:         corev1.EventSource{Component: EventComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+
+	if _, err := client.CoreV1().Events(configMap.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		log.Printf("Failed to record change event on %s/%s: %v", configMap.Namespace, configMap.Name, err)
+	}
+}
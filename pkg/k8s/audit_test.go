@@ -0,0 +1,86 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAnnotateChange(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		wantRevision int
+	}{
+		{name: "never written by the manager", wantRevision: 1},
+		{name: "other annotations only", annotations: map[string]string{"owner": "platform"}, wantRevision: 1},
+		{name: "next revision", annotations: map[string]string{RevisionAnnotation: "41"}, wantRevision: 42},
+		{name: "unparsable revision restarts", annotations: map[string]string{RevisionAnnotation: "v3"}, wantRevision: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}
+
+			revision := annotateChange(cm, "alice", "add forward rule payments")
+			if revision != tt.wantRevision {
+				t.Errorf("annotateChange() = %d, want %d", revision, tt.wantRevision)
+			}
+
+			want := map[string]string{
+				LastAppliedByAnnotation: "alice",
+				RevisionAnnotation:      cm.Annotations[RevisionAnnotation],
+				ReasonAnnotation:        "add forward rule payments",
+			}
+			for key, value := range want {
+				if cm.Annotations[key] != value {
+					t.Errorf("annotation %s = %q, want %q", key, cm.Annotations[key], value)
+				}
+			}
+			if _, err := time.Parse(time.RFC3339, cm.Annotations[TimestampAnnotation]); err != nil {
+				t.Errorf("timestamp annotation = %q: %v", cm.Annotations[TimestampAnnotation], err)
+			}
+			if tt.annotations["owner"] != "" && cm.Annotations["owner"] != tt.annotations["owner"] {
+				t.Error("annotateChange() dropped an unrelated annotation")
+			}
+		})
+	}
+}
+
+func TestAnnotateChangeSequence(t *testing.T) {
+	cm := &corev1.ConfigMap{}
+	for want := 1; want <= 3; want++ {
+		if got := annotateChange(cm, SystemUser, ""); got != want {
+			t.Fatalf("write %d: revision = %d", want, got)
+		}
+	}
+	if cm.Annotations[RevisionAnnotation] != "3" {
+		t.Errorf("revision annotation = %q, want 3", cm.Annotations[RevisionAnnotation])
+	}
+	if reason, ok := cm.Annotations[ReasonAnnotation]; !ok || reason != "" {
+		t.Errorf("reason annotation = %q, %v; want the previous reason cleared", reason, ok)
+	}
+}
+
+func TestRecordChangeEvent(t *testing.T) {
+	client := fake.NewClientset()
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: CoreDNSConfigMapName, Namespace: CoreDNSNamespace, UID: "uid-1"}}
+
+	recordChangeEvent(context.Background(), client, cm, 7, "alice", "roll back failed change c1")
+
+	events, err := client.CoreV1().Events(CoreDNSNamespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil || len(events.Items) != 1 {
+		t.Fatalf("events = %v, %v; want one", events, err)
+	}
+	e := events.Items[0]
+	if e.Message != "Corefile revision 7 applied by alice: roll back failed change c1" {
+		t.Errorf("Message = %q", e.Message)
+	}
+	if e.Reason != CorefileUpdatedEvent || e.InvolvedObject.Name != CoreDNSConfigMapName || e.InvolvedObject.UID != "uid-1" {
+		t.Errorf("event = %+v, want it on the CoreDNS ConfigMap", e)
+	}
+}
@@ -0,0 +1,230 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Backup ConfigMaps are named BackupConfigMapPrefix<n> and rotate through
+// BackupOptions.Keep slots. They carry their metadata in annotations so a
+// Corefile can be restored with kubectl alone.
+const (
+	BackupConfigMapPrefix  = CoreDNSConfigMapName + "-backup-"
+	BackupLabel            = "coredns-manager/backup-of"
+	BackupTimeAnnotation   = "coredns-manager/backup-time"
+	BackupUserAnnotation   = "coredns-manager/backup-user"
+	BackupReasonAnnotation = "coredns-manager/backup-reason"
+)
+
+var (
+	// ErrBackupNotFound is returned when a backup ConfigMap does not exist
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrNoBackupSlot is returned when every backup slot holds a ConfigMap
+	// that isn't a backup
+	ErrNoBackupSlot = errors.New("all backup slots are taken by ConfigMaps that aren't backups")
+)
+
+// BackupOptions controls the in-cluster Corefile backups
+type BackupOptions struct {
+	Keep int // backup ConfigMaps kept per cluster, 0 disables backups
+}
+
+// ListBackups returns the Corefile backups of a cluster, newest first
+func (h *CoreDNSHandler) ListBackups(ctx context.Context, cluster *models.Cluster) ([]models.CorefileBackup, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	list, _, err := h.listBackups(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]models.CorefileBackup, 0, len(list))
+	for i := range list {
+		backups = append(backups, backupFromConfigMap(&list[i]))
+	}
+	return backups, nil
+}
+
+// GetBackup returns one Corefile backup of a cluster
+func (h *CoreDNSHandler) GetBackup(ctx context.Context, cluster *models.Cluster, name string) (*models.CorefileBackup, error) {
+	if !strings.HasPrefix(name, BackupConfigMapPrefix) {
+		return nil, ErrBackupNotFound
+	}
+
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && configMap.Labels[BackupLabel] != CoreDNSConfigMapName) {
+		return nil, ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup %s: %w", name, err)
+	}
+
+	backup := backupFromConfigMap(configMap)
+	return &backup, nil
+}
+
+// RestoreBackup writes the Corefile of a backup back to CoreDNS. The Corefile
+// it replaces is backed up first, so a restore can be undone the same way.
+func (h *CoreDNSHandler) RestoreBackup(ctx context.Context, cluster *models.Cluster, name string) (string, *models.CorefileChange, error) {
+	backup, err := h.GetBackup(ctx, cluster, name)
+	if err != nil {
+		return "", nil, err
+	}
+	change, err := h.UpdateCorefile(withDefaultReason(ctx, "restore "+name), cluster, backup.Corefile)
+	if err != nil {
+		return "", nil, err
+	}
+	return backup.Corefile, change, nil
+}
+
+// backupCorefile copies a Corefile into the next backup slot: an unused slot
+// if there is one, else the oldest backup. Nothing is written if the newest
+// backup already holds the same Corefile.
+func (h *CoreDNSHandler) backupCorefile(ctx context.Context, client kubernetes.Interface, corefile, user, reason string) error {
+	if h.backups.Keep <= 0 || corefile == "" {
+		return nil
+	}
+
+	list, foreign, err := h.listBackups(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 && list[0].Data[CorefileName] == corefile {
+		return nil
+	}
+
+	slot, existing, err := pickBackupSlot(h.backups.Keep, list, foreign)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BackupConfigMapPrefix + strconv.Itoa(slot),
+			Namespace: CoreDNSNamespace,
+			Labels:    map[string]string{BackupLabel: CoreDNSConfigMapName},
+			Annotations: map[string]string{
+				BackupTimeAnnotation: time.Now().UTC().Format(time.RFC3339),
+				BackupUserAnnotation: user,
+			},
+		},
+		Data: map[string]string{CorefileName: corefile},
+	}
+	if reason != "" {
+		configMap.Annotations[BackupReasonAnnotation] = reason
+	}
+
+	if existing != nil {
+		configMap.ResourceVersion = existing.ResourceVersion
+		_, err = client.CoreV1().ConfigMaps(CoreDNSNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	} else {
+		_, err = client.CoreV1().ConfigMaps(CoreDNSNamespace).Create(ctx, configMap, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write backup %s: %w", configMap.Name, err)
+	}
+	return nil
+}
+
+// listBackups returns the backup ConfigMaps, newest first, and the slots
+// taken by ConfigMaps of the same name that aren't backups. The slots are read
+// by name, so the manager only needs access to these ConfigMaps rather than
+// list on every ConfigMap in the namespace.
+func (h *CoreDNSHandler) listBackups(ctx context.Context, client kubernetes.Interface) ([]corev1.ConfigMap, map[int]bool, error) {
+	items := make([]corev1.ConfigMap, 0, h.backups.Keep)
+	foreign := make(map[int]bool)
+	for n := 0; n < h.backups.Keep; n++ {
+		name := BackupConfigMapPrefix + strconv.Itoa(n)
+		configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get backup %s: %w", name, err)
+		}
+		// Not ours, don't overwrite it
+		if configMap.Labels[BackupLabel] != CoreDNSConfigMapName {
+			foreign[n] = true
+			continue
+		}
+		items = append(items, *configMap)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return backupTime(&items[i]).After(backupTime(&items[j]))
+	})
+	return items, foreign, nil
+}
+
+// pickBackupSlot returns the slot the next backup is written to: the lowest
+// slot that is free, else the slot of the oldest backup, which is returned
+// too. backups must be sorted newest first. Slots taken by foreign
+// ConfigMaps are skipped.
+func pickBackupSlot(keep int, backups []corev1.ConfigMap, foreign map[int]bool) (int, *corev1.ConfigMap, error) {
+	used := make(map[int]bool, len(backups))
+	for i := range backups {
+		if n, ok := backupIndex(backups[i].Name); ok {
+			used[n] = true
+		}
+	}
+	for n := 0; n < keep; n++ {
+		if !used[n] && !foreign[n] {
+			return n, nil, nil
+		}
+	}
+
+	for i := len(backups) - 1; i >= 0; i-- {
+		if n, ok := backupIndex(backups[i].Name); ok && n < keep {
+			return n, &backups[i], nil
+		}
+	}
+	return 0, nil, ErrNoBackupSlot
+}
+
+func backupFromConfigMap(configMap *corev1.ConfigMap) models.CorefileBackup {
+	return models.CorefileBackup{
+		Name:      configMap.Name,
+		CreatedAt: backupTime(configMap),
+		User:      configMap.Annotations[BackupUserAnnotation],
+		Reason:    configMap.Annotations[BackupReasonAnnotation],
+		Corefile:  configMap.Data[CorefileName],
+	}
+}
+
+// backupTime returns when a backup was taken, falling back to the creation
+// time if the annotation was edited away
+func backupTime(configMap *corev1.ConfigMap) time.Time {
+	if t, err := time.Parse(time.RFC3339, configMap.Annotations[BackupTimeAnnotation]); err == nil {
+		return t
+	}
+	return configMap.CreationTimestamp.Time
+}
+
+// backupIndex returns the slot number of a backup ConfigMap name
+func backupIndex(name string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimPrefix(name, BackupConfigMapPrefix))
+	if err != nil || n < 0 || !strings.HasPrefix(name, BackupConfigMapPrefix) {
+		return 0, false
+	}
+	return n, true
+}
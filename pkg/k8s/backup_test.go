@@ -0,0 +1,127 @@
+package k8s
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testBackup returns a backup ConfigMap in slot n taken age ago
+func testBackup(n int, age time.Duration) corev1.ConfigMap {
+	return corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        BackupConfigMapPrefix + strconv.Itoa(n),
+			Labels:      map[string]string{BackupLabel: CoreDNSConfigMapName},
+			Annotations: map[string]string{BackupTimeAnnotation: time.Now().Add(-age).UTC().Format(time.RFC3339)},
+		},
+	}
+}
+
+func TestPickBackupSlot(t *testing.T) {
+	tests := []struct {
+		name         string
+		keep         int
+		backups      []corev1.ConfigMap // newest first
+		foreign      map[int]bool
+		wantSlot     int
+		wantExisting bool
+		wantErr      error
+	}{
+		{name: "first backup", keep: 3, wantSlot: 0},
+		{
+			name:     "next free slot",
+			keep:     3,
+			backups:  []corev1.ConfigMap{testBackup(1, time.Minute), testBackup(0, time.Hour)},
+			wantSlot: 2,
+		},
+		{
+			name:     "lowest free slot first",
+			keep:     3,
+			backups:  []corev1.ConfigMap{testBackup(2, time.Minute), testBackup(1, time.Hour)},
+			wantSlot: 0,
+		},
+		{
+			name:         "rotate to the oldest backup",
+			keep:         3,
+			backups:      []corev1.ConfigMap{testBackup(0, time.Minute), testBackup(2, time.Hour), testBackup(1, 2*time.Hour)},
+			wantSlot:     1,
+			wantExisting: true,
+		},
+		{
+			name:     "skip a foreign ConfigMap",
+			keep:     3,
+			foreign:  map[int]bool{0: true},
+			wantSlot: 1,
+		},
+		{
+			name:         "rotate around a foreign ConfigMap",
+			keep:         3,
+			backups:      []corev1.ConfigMap{testBackup(2, time.Minute), testBackup(0, time.Hour)},
+			foreign:      map[int]bool{1: true},
+			wantSlot:     0,
+			wantExisting: true,
+		},
+		{
+			name:    "every slot foreign",
+			keep:    2,
+			foreign: map[int]bool{0: true, 1: true},
+			wantErr: ErrNoBackupSlot,
+		},
+		{
+			name:         "slots beyond a lowered keep are not reused",
+			keep:         1,
+			backups:      []corev1.ConfigMap{testBackup(0, time.Minute), testBackup(4, time.Hour)},
+			wantSlot:     0,
+			wantExisting: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot, existing, err := pickBackupSlot(tt.keep, tt.backups, tt.foreign)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("pickBackupSlot() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if slot != tt.wantSlot {
+				t.Errorf("pickBackupSlot() slot = %d, want %d", slot, tt.wantSlot)
+			}
+			if (existing != nil) != tt.wantExisting {
+				t.Fatalf("pickBackupSlot() existing = %v, want existing %v", existing, tt.wantExisting)
+			}
+			if existing != nil && existing.Name != BackupConfigMapPrefix+strconv.Itoa(slot) {
+				t.Errorf("pickBackupSlot() existing = %s, want the backup in slot %d", existing.Name, slot)
+			}
+		})
+	}
+}
+
+func TestBackupIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   int
+		wantOK bool
+	}{
+		{name: BackupConfigMapPrefix + "0", want: 0, wantOK: true},
+		{name: BackupConfigMapPrefix + "12", want: 12, wantOK: true},
+		{name: BackupConfigMapPrefix + "-1"},
+		{name: BackupConfigMapPrefix + "x"},
+		{name: CoreDNSConfigMapName},
+		{name: "other-backup-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, ok := backupIndex(tt.name)
+			if n != tt.want || ok != tt.wantOK {
+				t.Errorf("backupIndex() = %d, %v; want %d, %v", n, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
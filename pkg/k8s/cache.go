@@ -0,0 +1,237 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Kinds of cached objects
+const (
+	KindConfigMap = "ConfigMap"
+	KindService   = "Service"
+)
+
+// CacheEvent notifies subscribers of a change to a cached CoreDNS object
+type CacheEvent struct {
+	ClusterID string
+	Kind      string // ConfigMap or Service
+	Name      string
+	Deleted   bool
+}
+
+// clusterCache watches the CoreDNS ConfigMap and Service of one cluster
+type clusterCache struct {
+	configMaps cache.SharedIndexInformer
+	services   cache.SharedIndexInformer
+	stop       chan struct{}
+
+	mu      sync.Mutex
+	lastErr error // last list/watch error, reported while not synced
+}
+
+// newClusterCache creates informers limited to the CoreDNS objects in kube-system
+func newClusterCache(client kubernetes.Interface) *clusterCache {
+	byName := func(name string) func(*metav1.ListOptions) {
+		return func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}
+	}
+
+	c := &clusterCache{
+		configMaps: coreinformers.NewFilteredConfigMapInformer(client, CoreDNSNamespace, 0, cache.Indexers{}, byName(CoreDNSConfigMapName)),
+		services:   coreinformers.NewFilteredServiceInformer(client, CoreDNSNamespace, 0, cache.Indexers{}, byName(KubeDNSServiceName)),
+		stop:       make(chan struct{}),
+	}
+
+	onError := func(_ *cache.Reflector, err error) {
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+	}
+	c.configMaps.SetWatchErrorHandler(onError)
+	c.services.SetWatchErrorHandler(onError)
+
+	return c
+}
+
+// waitSynced waits until both informers have listed their objects
+func (c *clusterCache) waitSynced(ctx context.Context) error {
+	if cache.WaitForCacheSync(ctx.Done(), c.configMaps.HasSynced, c.services.HasSynced) {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastErr != nil {
+		return fmt.Errorf("failed to sync coredns cache: %w", c.lastErr)
+	}
+	return fmt.Errorf("failed to sync coredns cache: %w", ctx.Err())
+}
+
+// Subscribe registers fn to be called when a cached CoreDNS ConfigMap or
+// Service changes in any cluster. It returns a function that unsubscribes.
+func (m *Manager) Subscribe(fn func(CacheEvent)) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.nextSubscriber
+	m.nextSubscriber++
+	m.subscribers[id] = fn
+
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// notify calls every subscriber with an event
+func (m *Manager) notify(event CacheEvent) {
+	m.mu.RLock()
+	subscribers := make([]func(CacheEvent), 0, len(m.subscribers))
+	for _, fn := range m.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	m.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// getCache returns the started cache of a cluster, creating it on first use
+func (m *Manager) getCache(cluster *models.Cluster) (*clusterCache, error) {
+	m.mu.RLock()
+	c, exists := m.caches[cluster.ID]
+	m.mu.RUnlock()
+	if exists {
+		return c, nil
+	}
+
+	client, err := m.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, exists := m.caches[cluster.ID]; exists {
+		return c, nil
+	}
+
+	c = newClusterCache(client)
+	clusterID := cluster.ID
+	handler := func(kind string) cache.ResourceEventHandler {
+		return cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj interface{}, isInInitialList bool) {
+				if !isInInitialList {
+					m.notify(CacheEvent{ClusterID: clusterID, Kind: kind, Name: objectName(obj)})
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				m.notify(CacheEvent{ClusterID: clusterID, Kind: kind, Name: objectName(newObj)})
+			},
+			DeleteFunc: func(obj interface{}) {
+				m.notify(CacheEvent{ClusterID: clusterID, Kind: kind, Name: objectName(obj), Deleted: true})
+			},
+		}
+	}
+	c.configMaps.AddEventHandler(handler(KindConfigMap))
+	c.services.AddEventHandler(handler(KindService))
+
+	go c.configMaps.Run(c.stop)
+	go c.services.Run(c.stop)
+
+	m.caches[cluster.ID] = c
+	return c, nil
+}
+
+// stopCache stops the informers of a cluster. The caller must hold m.mu.
+func (m *Manager) stopCache(clusterID string) {
+	if c, exists := m.caches[clusterID]; exists {
+		close(c.stop)
+		delete(m.caches, clusterID)
+	}
+}
+
+// GetCachedConfigMap returns the CoreDNS ConfigMap of a cluster from the
+// informer cache. Writes should read the ConfigMap from the API server instead.
+func (m *Manager) GetCachedConfigMap(ctx context.Context, cluster *models.Cluster) (*corev1.ConfigMap, error) {
+	c, err := m.getCache(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitSynced(ctx); err != nil {
+		return nil, err
+	}
+
+	obj, exists, err := c.configMaps.GetStore().GetByKey(CoreDNSNamespace + "/" + CoreDNSConfigMapName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), CoreDNSConfigMapName)
+	}
+	return obj.(*corev1.ConfigMap).DeepCopy(), nil
+}
+
+// GetCachedService returns the kube-dns Service of a cluster from the informer cache
+func (m *Manager) GetCachedService(ctx context.Context, cluster *models.Cluster) (*corev1.Service, error) {
+	c, err := m.getCache(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.waitSynced(ctx); err != nil {
+		return nil, err
+	}
+
+	obj, exists, err := c.services.GetStore().GetByKey(CoreDNSNamespace + "/" + KubeDNSServiceName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apierrors.NewNotFound(corev1.Resource("services"), KubeDNSServiceName)
+	}
+	return obj.(*corev1.Service).DeepCopy(), nil
+}
+
+// PeekCachedService returns the kube-dns Service of a cluster if its informers
+// are already running and synced. Unlike GetCachedService it never starts
+// informers or waits for them.
+func (m *Manager) PeekCachedService(clusterID string) (*corev1.Service, bool) {
+	m.mu.RLock()
+	c, exists := m.caches[clusterID]
+	m.mu.RUnlock()
+	if !exists || !c.services.HasSynced() {
+		return nil, false
+	}
+
+	obj, exists, err := c.services.GetStore().GetByKey(CoreDNSNamespace + "/" + KubeDNSServiceName)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*corev1.Service).DeepCopy(), true
+}
+
+// objectName returns the name of an informer object, including deleted
+// objects whose final state is unknown
+func objectName(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if o, ok := obj.(metav1.Object); ok {
+		return o.GetName()
+	}
+	return ""
+}
@@ -3,12 +3,15 @@ package k8s
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"sync"
 
 	"coredns-multi-configuration/pkg/models"
 
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -16,12 +19,18 @@ import (
 type Manager struct {
 	mu      sync.RWMutex
 	clients map[string]*kubernetes.Clientset
+	caches  map[string]*clusterCache // CoreDNS object informers by cluster ID
+
+	subscribers    map[int]func(CacheEvent)
+	nextSubscriber int
 }
 
 // NewManager creates a new K8s client manager
 func NewManager() *Manager {
 	return &Manager{
-		clients: make(map[string]*kubernetes.Clientset),
+		clients:     make(map[string]*kubernetes.Clientset),
+		caches:      make(map[string]*clusterCache),
+		subscribers: make(map[int]func(CacheEvent)),
 	}
 }
 
@@ -48,25 +57,20 @@ func (m *Manager) GetClient(cluster *models.Cluster) (*kubernetes.Clientset, err
 	return client, nil
 }
 
-// RemoveClient removes a cached client for the specified cluster
+// RemoveClient removes a cached client for the specified cluster and stops
+// its informers
 func (m *Manager) RemoveClient(clusterID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	delete(m.clients, clusterID)
+	m.stopCache(clusterID)
 }
 
-// createClient creates a new Kubernetes client from kubeconfig
+// createClient creates a new Kubernetes client from the cluster's credential source
 func (m *Manager) createClient(cluster *models.Cluster) (*kubernetes.Clientset, error) {
-	// Decode base64 kubeconfig
-	kubeconfigData, err := base64.StdEncoding.DecodeString(cluster.Kubeconfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode kubeconfig: %w", err)
-	}
-
-	// Build config from kubeconfig
-	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfigData)
+	config, err := restConfig(cluster)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
+		return nil, err
 	}
 
 	// Create clientset
@@ -78,20 +82,101 @@ func (m *Manager) createClient(cluster *models.Cluster) (*kubernetes.Clientset,
 	return clientset, nil
 }
 
+// restConfig builds the REST config of a cluster
+func restConfig(cluster *models.Cluster) (*rest.Config, error) {
+	switch cluster.Source {
+	case models.SourceInCluster:
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+		return config, nil
+
+	case models.SourceKubeconfig:
+		// Decode base64 kubeconfig
+		kubeconfigData, err := base64.StdEncoding.DecodeString(cluster.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode kubeconfig: %w", err)
+		}
+
+		kubeconfig, err := clientcmd.Load(kubeconfigData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
+		}
+		if err := checkCredentialPlugin(kubeconfig); err != nil {
+			return nil, err
+		}
+
+		// Build config from kubeconfig
+		config, err := clientcmd.NewDefaultClientConfig(*kubeconfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
+		}
+		return config, nil
+
+	default:
+		return nil, fmt.Errorf("unknown credential source %q", cluster.Source)
+	}
+}
+
+// InClusterAvailable reports whether the manager runs in a pod with a ServiceAccount
+func InClusterAvailable() bool {
+	_, err := rest.InClusterConfig()
+	return err == nil
+}
+
 // TestConnection tests the connection to a cluster
 func (m *Manager) TestConnection(ctx context.Context, cluster *models.Cluster) error {
-	client, err := m.GetClient(cluster)
+	_, err := m.ServerVersion(ctx, cluster)
+	return err
+}
+
+// TestCredentials tests the credentials of a cluster with a new client,
+// leaving the cached client of the cluster untouched
+func (m *Manager) TestCredentials(ctx context.Context, cluster *models.Cluster) error {
+	client, err := m.createClient(cluster)
 	if err != nil {
 		return err
 	}
+	_, err = serverVersion(ctx, client)
+	return err
+}
 
-	// Try to get server version as a connectivity test
-	_, err = client.Discovery().ServerVersion()
+// ServerVersion returns the API server version of a cluster. Unlike
+// Discovery().ServerVersion() it honours the context.
+func (m *Manager) ServerVersion(ctx context.Context, cluster *models.Cluster) (*version.Info, error) {
+	client, err := m.GetClient(cluster)
 	if err != nil {
-		// Remove cached client on failure
-		m.RemoveClient(cluster.ID)
-		return fmt.Errorf("failed to connect to cluster: %w", err)
+		return nil, err
+	}
+
+	info, err := serverVersion(ctx, client)
+	if err != nil {
+		// Rebuild the REST client on the next use, but keep the informers:
+		// they reconnect on their own and their cache stays readable
+		m.dropClient(cluster.ID)
+		return nil, err
 	}
+	return info, nil
+}
 
-	return nil
+// dropClient removes the cached REST client of a cluster only. The client
+// used by its informers is not affected.
+func (m *Manager) dropClient(clusterID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.clients, clusterID)
+}
+
+func serverVersion(ctx context.Context, client kubernetes.Interface) (*version.Info, error) {
+	body, err := client.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cluster: %w", err)
+	}
+
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse server version: %w", err)
+	}
+	return &info, nil
 }
@@ -2,12 +2,19 @@ package k8s
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"coredns-multi-configuration/pkg/models"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -22,11 +29,44 @@ const (
 // CoreDNSHandler handles CoreDNS configuration operations
 type CoreDNSHandler struct {
 	manager *Manager
+	rollout RolloutOptions
+	backups BackupOptions
+
+	mu         sync.RWMutex
+	changes    map[string][]*models.CorefileChange // tracked rollouts by cluster ID
+	onRollback func(clusterID, corefile string)
+
+	queryLogs     map[string][]*models.QueryLogSession // debug sessions by cluster ID
+	queryLogStops map[string]context.CancelFunc        // active sessions by session ID
+	queryLogTimer map[string]*time.Timer               // scheduled directive cleanup by cluster ID
+}
+
+// RolloutOptions controls how Corefile changes are verified
+type RolloutOptions struct {
+	Window       time.Duration // 0 derives it from the reload plugin interval
+	AutoRollback bool          // restore the previous Corefile when CoreDNS breaks
 }
 
 // NewCoreDNSHandler creates a new CoreDNS handler
-func NewCoreDNSHandler(manager *Manager) *CoreDNSHandler {
-	return &CoreDNSHandler{manager: manager}
+func NewCoreDNSHandler(manager *Manager, rollout RolloutOptions, backups BackupOptions) *CoreDNSHandler {
+	return &CoreDNSHandler{
+		manager: manager,
+		rollout: rollout,
+		backups: backups,
+		changes: make(map[string][]*models.CorefileChange),
+
+		queryLogs:     make(map[string][]*models.QueryLogSession),
+		queryLogStops: make(map[string]context.CancelFunc),
+		queryLogTimer: make(map[string]*time.Timer),
+	}
+}
+
+// OnRollback registers a function called after a failed change was rolled
+// back, with the Corefile that was restored
+func (h *CoreDNSHandler) OnRollback(fn func(clusterID, corefile string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onRollback = fn
 }
 
 // CoreDNSInfo contains CoreDNS configuration and service information
@@ -36,27 +76,50 @@ type CoreDNSInfo struct {
 	Corefile     string               `json:"corefile"`
 	ServiceIP    string               `json:"service_ip"`
 	ForwardRules []models.ForwardRule `json:"forward_rules"`
+	Reload       bool                 `json:"reload"` // the reload plugin is enabled
 }
 
-// GetCoreDNSInfo retrieves CoreDNS configuration and service info from a cluster
+// GetCoreDNSInfo retrieves CoreDNS configuration and service info from a
+// cluster, served from the informer cache
 func (h *CoreDNSHandler) GetCoreDNSInfo(ctx context.Context, cluster *models.Cluster) (*CoreDNSInfo, error) {
+	// Get CoreDNS ConfigMap
+	configMap, err := h.manager.GetCachedConfigMap(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coredns configmap: %w", err)
+	}
+
+	// Get kube-dns Service
+	service, err := h.manager.GetCachedService(ctx, cluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kube-dns service: %w", err)
+	}
+
+	return newCoreDNSInfo(configMap, service), nil
+}
+
+// freshCoreDNSInfo reads CoreDNS info from the API server, bypassing the
+// cache, for read-modify-write operations
+func (h *CoreDNSHandler) freshCoreDNSInfo(ctx context.Context, cluster *models.Cluster) (*CoreDNSInfo, error) {
 	client, err := h.manager.GetClient(cluster)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get CoreDNS ConfigMap
 	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, CoreDNSConfigMapName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get coredns configmap: %w", err)
 	}
 
-	// Get kube-dns Service
 	service, err := client.CoreV1().Services(CoreDNSNamespace).Get(ctx, KubeDNSServiceName, metav1.GetOptions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kube-dns service: %w", err)
 	}
 
+	return newCoreDNSInfo(configMap, service), nil
+}
+
+// newCoreDNSInfo builds CoreDNSInfo and parses the forward rules of the Corefile
+func newCoreDNSInfo(configMap *corev1.ConfigMap, service *corev1.Service) *CoreDNSInfo {
 	info := &CoreDNSInfo{
 		ConfigMap: configMap,
 		Service:   service,
@@ -65,83 +128,177 @@ func (h *CoreDNSHandler) GetCoreDNSInfo(ctx context.Context, cluster *models.Clu
 	}
 
 	// Parse existing forward rules from Corefile
-	info.ForwardRules = parseForwardRules(info.Corefile)
+	info.ForwardRules = ParseForwardRules(info.Corefile)
+	info.Reload, _, _ = ParseReloadSettings(info.Corefile)
 
-	return info, nil
+	return info
 }
 
-// UpdateCorefile updates the CoreDNS Corefile configuration
-func (h *CoreDNSHandler) UpdateCorefile(ctx context.Context, cluster *models.Cluster, corefile string) error {
+// ErrCorefileChanged is returned when the Corefile was modified after the
+// caller read it
+var ErrCorefileChanged = errors.New("corefile changed since it was read")
+
+// CorefileFingerprint returns a stable hash of a Corefile
+func CorefileFingerprint(corefile string) string {
+	sum := sha256.Sum256([]byte(corefile))
+	return hex.EncodeToString(sum[:])
+}
+
+// UpdateCorefile updates the CoreDNS Corefile configuration and returns the
+// tracked change
+func (h *CoreDNSHandler) UpdateCorefile(ctx context.Context, cluster *models.Cluster, corefile string) (*models.CorefileChange, error) {
 	client, err := h.manager.GetClient(cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Get current ConfigMap
 	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, CoreDNSConfigMapName, metav1.GetOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to get coredns configmap: %w", err)
+		return nil, fmt.Errorf("failed to get coredns configmap: %w", err)
+	}
+
+	return h.writeCorefile(ctx, cluster, client, configMap, corefile)
+}
+
+// writeCorefile replaces the Corefile of a ConfigMap read from the API server.
+// The update carries its resourceVersion, so it fails with ErrCorefileChanged
+// if the ConfigMap was modified in the meantime.
+func (h *CoreDNSHandler) writeCorefile(ctx context.Context, cluster *models.Cluster, client kubernetes.Interface, configMap *corev1.ConfigMap, corefile string) (*models.CorefileChange, error) {
+	// Record restart counts before the write so the rollout can detect crashes
+	baseline := h.restartBaseline(ctx, client)
+	previous := configMap.Data[CorefileName]
+
+	// Keep a copy in the cluster in case the manager and its data are lost.
+	// Credentials that may write the Corefile but not the backup ConfigMaps
+	// still can, the backup is skipped with a warning.
+	user, reason := UserFrom(ctx), reasonFrom(ctx)
+	if cluster.Capabilities != nil && !cluster.Capabilities.Backup {
+		log.Printf("Skipping Corefile backup of cluster %s: credentials can't create backup ConfigMaps", cluster.Name)
+	} else if err := h.backupCorefile(ctx, client, previous, user, reason); err != nil {
+		if !apierrors.IsForbidden(err) && !errors.Is(err, ErrNoBackupSlot) {
+			return nil, fmt.Errorf("failed to back up Corefile: %w", err)
+		}
+		log.Printf("Skipping Corefile backup of cluster %s: %v", cluster.Name, err)
 	}
 
 	// Update Corefile
 	configMap.Data[CorefileName] = corefile
+	revision := annotateChange(configMap, user, reason)
 
 	// Apply update
-	_, err = client.CoreV1().ConfigMaps(CoreDNSNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	updated, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return nil, fmt.Errorf("failed to update coredns configmap: %w", ErrCorefileChanged)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update coredns configmap: %w", err)
+		return nil, fmt.Errorf("failed to update coredns configmap: %w", err)
+	}
+	recordChangeEvent(ctx, client, updated, revision, user, reason)
+
+	// Verify in the background that CoreDNS picks up the change
+	change := h.trackRollout(cluster, client, previous, corefile, baseline)
+
+	if cluster.MirrorNodeLocal {
+		h.mirrorNodeLocal(ctx, cluster, corefile)
 	}
 
-	return nil
+	return change, nil
 }
 
 // AddForwardRule adds a forward rule to the CoreDNS configuration
-func (h *CoreDNSHandler) AddForwardRule(ctx context.Context, cluster *models.Cluster, rule models.ForwardRule) error {
-	info, err := h.GetCoreDNSInfo(ctx, cluster)
+func (h *CoreDNSHandler) AddForwardRule(ctx context.Context, cluster *models.Cluster, rule models.ForwardRule) (*models.CorefileChange, error) {
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check if rule already exists (compare full name: service.namespace or just namespace)
 	for _, r := range info.ForwardRules {
 		if r.GetFullName() == rule.GetFullName() {
-			return fmt.Errorf("forward rule for %s already exists", rule.GetFullName())
+			return nil, fmt.Errorf("forward rule for %s already exists", rule.GetFullName())
 		}
 	}
 
 	// Append new rule to Corefile
 	newCorefile := info.Corefile + "\n" + rule.ToCorefile() + "\n"
 
+	ctx = withDefaultReason(ctx, "add forward rule "+rule.GetFullName())
 	return h.UpdateCorefile(ctx, cluster, newCorefile)
 }
 
 // DeleteForwardRule removes a forward rule from the CoreDNS configuration
 // The name parameter can be "namespace" or "service.namespace"
 // isFullFQDN indicates whether the rule uses FQDN format (*.svc.cluster.local:53)
-func (h *CoreDNSHandler) DeleteForwardRule(ctx context.Context, cluster *models.Cluster, name string, isFullFQDN bool) error {
-	info, err := h.GetCoreDNSInfo(ctx, cluster)
+func (h *CoreDNSHandler) DeleteForwardRule(ctx context.Context, cluster *models.Cluster, name string, isFullFQDN bool) (*models.CorefileChange, error) {
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Parse input and build the rule block pattern
 	serviceName, namespace, _ := models.ParseNameInput(name)
-	var fullName string
-	if serviceName != "" {
-		fullName = serviceName + "." + namespace
-	} else {
-		fullName = namespace
+	rule := models.ForwardRule{
+		Namespace:   namespace,
+		ServiceName: serviceName,
+		IsFullFQDN:  isFullFQDN,
 	}
 
-	// Build domain block pattern based on format type
-	var ruleBlock string
-	if isFullFQDN {
-		ruleBlock = fmt.Sprintf("%s.svc.cluster.local:53", fullName)
-	} else {
-		ruleBlock = fmt.Sprintf("%s:53", fullName)
+	newCorefile := removeRuleBlock(info.Corefile, rule)
+	ctx = withDefaultReason(ctx, "delete forward rule "+rule.GetFullName())
+	return h.UpdateCorefile(ctx, cluster, newCorefile)
+}
+
+// ApplyRuleChanges removes and adds forward rules in a single Corefile update.
+// Rules in add replace any existing block with the same domain. The returned
+// change is nil if the Corefile already matched.
+func (h *CoreDNSHandler) ApplyRuleChanges(ctx context.Context, cluster *models.Cluster, remove, add []models.ForwardRule) (*models.CorefileChange, error) {
+	return h.applyRuleChanges(ctx, cluster, "", remove, add)
+}
+
+// ApplyPlannedRuleChanges is ApplyRuleChanges for changes planned against a
+// Corefile with the given fingerprint. It fails with ErrCorefileChanged if the
+// live Corefile no longer matches, including changes that land while writing.
+func (h *CoreDNSHandler) ApplyPlannedRuleChanges(ctx context.Context, cluster *models.Cluster, fingerprint string, remove, add []models.ForwardRule) (*models.CorefileChange, error) {
+	return h.applyRuleChanges(ctx, cluster, fingerprint, remove, add)
+}
+
+func (h *CoreDNSHandler) applyRuleChanges(ctx context.Context, cluster *models.Cluster, fingerprint string, remove, add []models.ForwardRule) (*models.CorefileChange, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != "" && CorefileFingerprint(info.Corefile) != fingerprint {
+		return nil, ErrCorefileChanged
 	}
 
-	lines := strings.Split(info.Corefile, "\n")
+	corefile := info.Corefile
+	for _, rule := range remove {
+		corefile = removeRuleBlock(corefile, rule)
+	}
+	for _, rule := range add {
+		corefile = removeRuleBlock(corefile, rule)
+		corefile = strings.TrimRight(corefile, "\n") + "\n\n" + rule.ToCorefile() + "\n"
+	}
+
+	if corefile == info.Corefile {
+		return nil, nil
+	}
+	ctx = withDefaultReason(ctx, fmt.Sprintf("apply rule changes (%d removed, %d added)", len(remove), len(add)))
+	return h.writeCorefile(ctx, cluster, client, info.ConfigMap, corefile)
+}
+
+// removeRuleBlock removes the server block of a forward rule from a Corefile
+// Domain block is "service.namespace:53" / "namespace:53", or the
+// *.svc.cluster.local:53 form when the rule uses FQDN format
+func removeRuleBlock(corefile string, rule models.ForwardRule) string {
+	ruleBlock := rule.GetDomainBlock()
+
+	lines := strings.Split(corefile, "\n")
 	var newLines []string
 	skipBlock := false
 	braceCount := 0
@@ -167,17 +324,16 @@ func (h *CoreDNSHandler) DeleteForwardRule(ctx context.Context, cluster *models.
 		newLines = append(newLines, line)
 	}
 
-	newCorefile := strings.Join(newLines, "\n")
-	return h.UpdateCorefile(ctx, cluster, newCorefile)
+	return strings.Join(newLines, "\n")
 }
 
-// parseForwardRules extracts forward rules from a Corefile
+// ParseForwardRules extracts forward rules from a Corefile
 // Supports 4 domain formats:
 // 1. namespace:53 (short format, namespace only)
 // 2. service.namespace:53 (short format, service.namespace)
 // 3. namespace.svc.cluster.local:53 (FQDN format)
 // 4. service.namespace.svc.cluster.local:53 (FQDN format)
-func parseForwardRules(corefile string) []models.ForwardRule {
+func ParseForwardRules(corefile string) []models.ForwardRule {
 	var rules []models.ForwardRule
 	lines := strings.Split(corefile, "\n")
 
@@ -252,9 +408,9 @@ func parseForwardRules(corefile string) []models.ForwardRule {
 }
 
 // GetDeployment retrieves the CoreDNS deployment info
-func (h *CoreDNSHandler) GetDeployment(ctx context.Context, client *kubernetes.Clientset) (*corev1.PodList, error) {
+func (h *CoreDNSHandler) GetDeployment(ctx context.Context, client kubernetes.Interface) (*corev1.PodList, error) {
 	pods, err := client.CoreV1().Pods(CoreDNSNamespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "k8s-app=kube-dns",
+		LabelSelector: CoreDNSLabelSelector,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list coredns pods: %w", err)
@@ -0,0 +1,143 @@
+package k8s
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// KubeContext describes a context of a kubeconfig
+type KubeContext struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster"`
+	Server    string `json:"server"`
+	User      string `json:"user"`
+	Namespace string `json:"namespace,omitempty"`
+	Current   bool   `json:"current"`
+
+	// CredentialPlugin names the exec or auth-provider plugin the user relies
+	// on. Such contexts cannot be used by the manager.
+	CredentialPlugin string `json:"credential_plugin,omitempty"`
+}
+
+// ErrCredentialPlugin is returned for kubeconfigs whose user authenticates
+// through an exec or auth-provider plugin
+var ErrCredentialPlugin = errors.New("credential plugins are not supported")
+
+// ListContexts returns the contexts of a kubeconfig sorted by name
+func ListContexts(kubeconfig []byte) ([]KubeContext, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+
+	contexts := make([]KubeContext, 0, len(config.Contexts))
+	for name, ctx := range config.Contexts {
+		kc := KubeContext{
+			Name:      name,
+			Cluster:   ctx.Cluster,
+			User:      ctx.AuthInfo,
+			Namespace: ctx.Namespace,
+			Current:   name == config.CurrentContext,
+		}
+		if cluster, ok := config.Clusters[ctx.Cluster]; ok {
+			kc.Server = cluster.Server
+		}
+		if user, ok := config.AuthInfos[ctx.AuthInfo]; ok {
+			kc.CredentialPlugin = credentialPlugin(user)
+		}
+		contexts = append(contexts, kc)
+	}
+
+	sort.Slice(contexts, func(i, j int) bool { return contexts[i].Name < contexts[j].Name })
+	return contexts, nil
+}
+
+// ExtractContext returns a minimal kubeconfig holding only one context with
+// its cluster and user, and that context set as current
+func ExtractContext(kubeconfig []byte, contextName string) ([]byte, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse kubeconfig: %w", err)
+	}
+	if _, ok := config.Contexts[contextName]; !ok {
+		return nil, fmt.Errorf("context %s not found in kubeconfig", contextName)
+	}
+
+	config.CurrentContext = contextName
+	if err := clientcmdapi.MinifyConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to extract context %s: %w", contextName, err)
+	}
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	return data, nil
+}
+
+// BuildTokenKubeconfig returns a kubeconfig authenticating to server with a
+// bearer token. caData is the PEM encoded CA certificate of the API server;
+// if empty the system roots are used.
+func BuildTokenKubeconfig(name, server, token string, caData []byte) ([]byte, error) {
+	if len(caData) > 0 {
+		if block, _ := pem.Decode(caData); block == nil || block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("invalid CA certificate: expected a PEM encoded certificate")
+		}
+	}
+
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[name] = &clientcmdapi.AuthInfo{Token: token}
+	config.Contexts[name] = &clientcmdapi.Context{Cluster: name, AuthInfo: name}
+	config.CurrentContext = name
+
+	if err := clientcmd.Validate(*config); err != nil {
+		return nil, fmt.Errorf("invalid cluster credentials: %w", err)
+	}
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write kubeconfig: %w", err)
+	}
+	return data, nil
+}
+
+// checkCredentialPlugin rejects kubeconfigs whose current user needs a
+// credential plugin. The plugin binaries (aws, gke-gcloud-auth-plugin,
+// kubelogin, ...) are not available in the manager's container.
+func checkCredentialPlugin(config *clientcmdapi.Config) error {
+	ctx, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return nil // reported by the client config
+	}
+	user, ok := config.AuthInfos[ctx.AuthInfo]
+	if !ok {
+		return nil
+	}
+	if plugin := credentialPlugin(user); plugin != "" {
+		return fmt.Errorf("%w: user %q authenticates with the %s credential plugin; create a ServiceAccount token "+
+			"in the cluster and register it with the API server URL, bearer token and CA certificate instead",
+			ErrCredentialPlugin, ctx.AuthInfo, plugin)
+	}
+	return nil
+}
+
+// credentialPlugin describes the credential plugin of a kubeconfig user, if any
+func credentialPlugin(user *clientcmdapi.AuthInfo) string {
+	switch {
+	case user.Exec != nil:
+		return fmt.Sprintf("exec (%s)", user.Exec.Command)
+	case user.AuthProvider != nil:
+		return fmt.Sprintf("auth-provider (%s)", user.AuthProvider.Name)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// testKubeconfig returns a kubeconfig with contexts prod (current) and dev,
+// whose users are defined by prodUser and devUser
+func testKubeconfig(prodUser, devUser string) string {
+	return `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod
+  cluster:
+    server: https://prod.example.com:6443
+contexts:
+- name: prod
+  context:
+    cluster: prod
+    user: prod-user
+- name: dev
+  context:
+    cluster: prod
+    user: dev-user
+users:
+- name: prod-user
+  user:
+` + prodUser + `
+- name: dev-user
+  user:
+` + devUser + "\n"
+}
+
+const (
+	tokenUser = `    token: abc123`
+	certUser  = `    client-certificate-data: Y2VydA==
+    client-key-data: a2V5`
+	execUser = `    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args: ["eks", "get-token", "--cluster-name", "prod"]`
+	authProviderUser = `    auth-provider:
+      name: oidc
+      config:
+        idp-issuer-url: https://issuer.example.com`
+)
+
+func TestCheckCredentialPlugin(t *testing.T) {
+	tests := []struct {
+		name       string
+		kubeconfig string
+		wantPlugin string // part of the error, empty if the kubeconfig is accepted
+	}{
+		{name: "bearer token", kubeconfig: testKubeconfig(tokenUser, execUser)},
+		{name: "client certificate", kubeconfig: testKubeconfig(certUser, tokenUser)},
+		{name: "exec plugin", kubeconfig: testKubeconfig(execUser, tokenUser), wantPlugin: "exec (aws)"},
+		{name: "auth-provider", kubeconfig: testKubeconfig(authProviderUser, tokenUser), wantPlugin: "auth-provider (oidc)"},
+		{
+			name:       "missing current context",
+			kubeconfig: strings.Replace(testKubeconfig(execUser, execUser), "current-context: prod", "current-context: staging", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := clientcmd.Load([]byte(tt.kubeconfig))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			err = checkCredentialPlugin(config)
+			if tt.wantPlugin == "" {
+				if err != nil {
+					t.Errorf("checkCredentialPlugin() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrCredentialPlugin) {
+				t.Fatalf("checkCredentialPlugin() error = %v, want ErrCredentialPlugin", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantPlugin) || !strings.Contains(err.Error(), `"prod-user"`) {
+				t.Errorf("checkCredentialPlugin() error = %q, want the user and %s", err, tt.wantPlugin)
+			}
+		})
+	}
+}
+
+// A context extracted on import is checked like any other kubeconfig
+func TestRestConfigRejectsExtractedPluginContext(t *testing.T) {
+	kubeconfig := []byte(testKubeconfig(tokenUser, execUser))
+
+	for contextName, wantErr := range map[string]bool{"prod": false, "dev": true} {
+		minimal, err := ExtractContext(kubeconfig, contextName)
+		if err != nil {
+			t.Fatalf("ExtractContext(%s): %v", contextName, err)
+		}
+		cluster := &models.Cluster{
+			Source:     models.SourceKubeconfig,
+			Kubeconfig: base64.StdEncoding.EncodeToString(minimal),
+		}
+
+		_, err = restConfig(cluster)
+		if got := errors.Is(err, ErrCredentialPlugin); got != wantErr {
+			t.Errorf("restConfig() of context %s error = %v, want credential plugin error %v", contextName, err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Log streaming intervals
+const (
+	logPodsRefreshInterval = 10 * time.Second // how often new CoreDNS pods are looked for
+	logRetryMaxDelay       = 5 * time.Minute  // longest wait before retrying a failing pod
+)
+
+// logRetryDelay returns how long to wait before opening the log of a pod
+// again after it failed the given number of times in a row
+func logRetryDelay(failures int) time.Duration {
+	delay := logPodsRefreshInterval
+	for i := 1; i < failures && delay < logRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, logRetryMaxDelay)
+}
+
+// StreamLogs follows the logs of every CoreDNS pod of a cluster and sends
+// the lines containing filter to out until ctx is done. Pods started while
+// streaming, e.g. by a restart, are followed too.
+func (h *CoreDNSHandler) StreamLogs(ctx context.Context, cluster *models.Cluster, filter string, tailLines int64, out chan<- models.LogLine) error {
+	return h.streamLogs(ctx, cluster, func(line string) bool {
+		return filter == "" || strings.Contains(line, filter)
+	}, tailLines, out)
+}
+
+// streamLogs follows the logs of every CoreDNS pod and sends the lines
+// accepted by match to out until ctx is done. A pod whose log can't be opened
+// is retried with a growing delay and its error is sent once per failure
+// streak. If reading logs is forbidden, streaming stops with that error.
+func (h *CoreDNSHandler) streamLogs(ctx context.Context, cluster *models.Cluster, match func(string) bool, tailLines int64, out chan<- models.LogLine) error {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	following := make(map[string]bool)
+	stopped := make(map[string]time.Time) // pods whose stream ended, and when
+	failures := make(map[string]int)      // consecutive failures to open the log of a pod
+	retryAt := make(map[string]time.Time) // pods not to retry before then
+	forbidden := make(chan error, 1)
+
+	ticker := time.NewTicker(logPodsRefreshInterval)
+	defer ticker.Stop()
+
+	first := true
+	for {
+		pods, err := h.GetDeployment(ctx, client)
+		if err != nil && first {
+			return err
+		}
+		first = false
+
+		if err == nil {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != corev1.PodRunning {
+					continue
+				}
+
+				mu.Lock()
+				if following[pod.Name] || time.Now().Before(retryAt[pod.Name]) {
+					mu.Unlock()
+					continue
+				}
+				following[pod.Name] = true
+				opts := &corev1.PodLogOptions{Follow: true}
+				if c := coreDNSContainer(pod); c != nil {
+					opts.Container = c.Name
+				}
+				if since, ok := stopped[pod.Name]; ok {
+					// Resume without repeating the lines already sent
+					opts.SinceTime = &metav1.Time{Time: since}
+				} else {
+					opts.TailLines = &tailLines
+				}
+				mu.Unlock()
+
+				go func(name string) {
+					err := followPodLogs(ctx, client, name, opts, match, out)
+
+					mu.Lock()
+					delete(following, name)
+					if err == nil {
+						stopped[name] = time.Now()
+						delete(failures, name)
+						delete(retryAt, name)
+						mu.Unlock()
+						return
+					}
+					failures[name]++
+					retryAt[name] = time.Now().Add(logRetryDelay(failures[name]))
+					firstFailure := failures[name] == 1
+					mu.Unlock()
+
+					if apierrors.IsForbidden(err) {
+						select {
+						case forbidden <- fmt.Errorf("failed to stream logs of pod %s: %w", name, err):
+						default:
+						}
+						return
+					}
+					if firstFailure {
+						select {
+						case out <- models.LogLine{Pod: name, Line: fmt.Sprintf("failed to stream logs: %v", err)}:
+						case <-ctx.Done():
+						}
+					}
+				}(pod.Name)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-forbidden:
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// followPodLogs streams the log of one pod until it ends or ctx is done. It
+// returns an error if the log can't be opened.
+func followPodLogs(ctx context.Context, client kubernetes.Interface, podName string, opts *corev1.PodLogOptions,
+	match func(string) bool, out chan<- models.LogLine) error {
+	stream, err := client.CoreV1().Pods(CoreDNSNamespace).GetLogs(podName, opts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !match(line) {
+			continue
+		}
+		select {
+		case out <- models.LogLine{Pod: podName, Line: line}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
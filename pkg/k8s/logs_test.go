@@ -0,0 +1,22 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogRetryDelay(t *testing.T) {
+	want := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		5:  160 * time.Second,
+		6:  logRetryMaxDelay,
+		50: logRetryMaxDelay,
+	}
+	for failures, delay := range want {
+		if got := logRetryDelay(failures); got != delay {
+			t.Errorf("logRetryDelay(%d) = %v, want %v", failures, got, delay)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/coredns/corefile-migration/migration"
+	"github.com/coredns/corefile-migration/migration/corefile"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ErrInvalidTargetVersion is returned for a target version the Corefile can't
+// be migrated to from the running CoreDNS version
+var ErrInvalidTargetVersion = errors.New("invalid target version")
+
+// PlanMigration detects the CoreDNS version of a cluster from its pods, checks
+// the Corefile against the compatibility table of the corefile-migration
+// library (as used by kubeadm) and previews its migration to target. An empty
+// target selects the newest known version. Nothing is written.
+func (h *CoreDNSHandler) PlanMigration(ctx context.Context, cluster *models.Cluster, target string) (*models.MigrationReport, error) {
+	info, err := h.GetCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	current, podVersions, err := h.detectVersion(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.MigrationReport{
+		CurrentVersion: current,
+		PodVersions:    podVersions,
+		Versions:       []string{},
+		Notices:        []models.MigrationNotice{},
+		Corefile:       info.Corefile,
+	}
+
+	known := migration.ValidVersions()
+	if current == "" {
+		report.Message = "CoreDNS version could not be detected from the pod images"
+		return report, nil
+	}
+	if versionIndex(known, current) < 0 {
+		report.Message = fmt.Sprintf("CoreDNS %s is not in the compatibility table (known versions %s to %s)",
+			current, known[0], known[len(known)-1])
+		return report, nil
+	}
+	report.Supported = true
+	report.Versions = known[versionIndex(known, current):]
+
+	if target == "" {
+		target = known[len(known)-1]
+	}
+	target = strings.TrimPrefix(target, "v")
+	if err := migration.ValidUpMigration(current, target); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTargetVersion, err)
+	}
+	report.TargetVersion = target
+
+	if target == current {
+		report.Migrated = info.Corefile
+		report.Message = "the Corefile already targets the running version"
+		return report, nil
+	}
+
+	deprecated, err := migration.Deprecated(current, target, info.Corefile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check Corefile: %w", err)
+	}
+	unsupported, err := migration.Unsupported(current, target, info.Corefile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check Corefile: %w", err)
+	}
+	report.Notices = migrationNotices(append(deprecated, unsupported...))
+
+	migrated, err := migration.Migrate(current, target, info.Corefile, true)
+	if err != nil {
+		report.Message = "automatic migration is not possible: " + err.Error()
+		return report, nil
+	}
+	report.Migrated = migrated
+
+	// Migrate re-serializes the Corefile, so compare it with the original in
+	// the same format to tell whether anything was rewritten
+	if original, err := corefile.New(info.Corefile); err == nil {
+		report.Changed = original.ToString() != migrated
+	} else {
+		report.Changed = true
+	}
+
+	return report, nil
+}
+
+// detectVersion returns the lowest CoreDNS version running in a cluster and
+// all distinct versions seen, e.g. during an upgrade
+func (h *CoreDNSHandler) detectVersion(ctx context.Context, cluster *models.Cluster) (string, []string, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pods, err := h.GetDeployment(ctx, client)
+	if err != nil {
+		return "", nil, err
+	}
+
+	lowest, versions := podVersions(pods.Items)
+	return lowest, versions, nil
+}
+
+// podVersions returns the lowest CoreDNS version of pods and all distinct
+// versions in the order seen. Untagged images are looked up by digest.
+func podVersions(pods []corev1.Pod) (string, []string) {
+	versions := make([]string, 0, 1)
+	seen := make(map[string]bool)
+	for i := range pods {
+		pod := &pods[i]
+		c := coreDNSContainer(pod)
+		if c == nil {
+			continue
+		}
+
+		version := ImageVersion(c.Image)
+		if version == "" {
+			// Untagged image: look the digest up in the table
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name != c.Name {
+					continue
+				}
+				if i := strings.Index(status.ImageID, "sha256:"); i >= 0 {
+					version, _ = migration.VersionFromSHA(status.ImageID[i+len("sha256:"):])
+				}
+			}
+		}
+		if version != "" && !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+
+	lowest := ""
+	for _, v := range versions {
+		if lowest == "" || versionLess(v, lowest) {
+			lowest = v
+		}
+	}
+	return lowest, versions
+}
+
+// versionIndex returns the position of a version in the sorted list of known versions
+func versionIndex(known []string, version string) int {
+	for i, v := range known {
+		if v == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// versionLess orders dotted versions by their numeric components, so 1.9.3
+// is before 1.10.0. Missing components count as 0, components that are not
+// numbers are compared as strings.
+func versionLess(a, b string) bool {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		ca, cb := "0", "0"
+		if i < len(pa) {
+			ca = pa[i]
+		}
+		if i < len(pb) {
+			cb = pb[i]
+		}
+		na, errA := strconv.Atoi(ca)
+		nb, errB := strconv.Atoi(cb)
+		switch {
+		case errA == nil && errB == nil && na != nb:
+			return na < nb
+		case (errA != nil || errB != nil) && ca != cb:
+			return ca < cb
+		}
+	}
+	return false
+}
+
+// migrationNotices converts the library notices, which repeat a status for
+// every version it applies to, keeping only the first version of each status
+func migrationNotices(notices []migration.Notice) []models.MigrationNotice {
+	result := make([]models.MigrationNotice, 0, len(notices))
+	seen := make(map[string]bool, len(notices))
+	for _, n := range notices {
+		key := n.Plugin + "/" + n.Option + "/" + n.Severity
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, models.MigrationNotice{
+			Plugin:     n.Plugin,
+			Option:     n.Option,
+			Severity:   n.Severity,
+			ReplacedBy: n.ReplacedBy,
+			Version:    n.Version,
+			Message:    n.ToString(),
+		})
+	}
+	return result
+}
@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coredns/corefile-migration/migration"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.9.3", "1.10.0", true},
+		{"1.10.0", "1.9.3", false},
+		{"1.9.99", "1.10.0", true}, // not in the compatibility table
+		{"1.11.1", "1.11.3", true},
+		{"1.11.3", "1.11.3", false},
+		{"1.11", "1.11.0", false},
+		{"1.11", "1.11.1", true},
+		{"2.0.0", "1.12.1", false},
+		{"1.11.1-rc1", "1.11.1-rc2", true},
+	}
+
+	for _, tt := range tests {
+		if got := versionLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("versionLess(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// versionPod returns a CoreDNS pod running image, with the image ID reported by the kubelet
+func versionPod(image, imageID string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "coredns-" + image},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "coredns", Image: image}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "coredns", Image: image, ImageID: imageID},
+		}},
+	}
+}
+
+func TestPodVersions(t *testing.T) {
+	const sha1101 = "a0ead06651cf580044aeb0a0feba63591858fb2e43ade8c9dea45a6a89ae7e5e" // 1.10.1 in the table
+
+	tests := []struct {
+		name         string
+		pods         []corev1.Pod
+		wantLowest   string
+		wantVersions []string
+	}{
+		{name: "no pods", wantVersions: []string{}},
+		{
+			name:         "one version",
+			pods:         []corev1.Pod{versionPod("registry.k8s.io/coredns/coredns:v1.11.1", ""), versionPod("registry.k8s.io/coredns/coredns:v1.11.1", "")},
+			wantLowest:   "1.11.1",
+			wantVersions: []string{"1.11.1"},
+		},
+		{
+			name:         "lowest during an upgrade",
+			pods:         []corev1.Pod{versionPod("registry.k8s.io/coredns/coredns:v1.10.1", ""), versionPod("registry.k8s.io/coredns/coredns:v1.9.3", "")},
+			wantLowest:   "1.9.3",
+			wantVersions: []string{"1.10.1", "1.9.3"},
+		},
+		{
+			name:         "lowest among versions missing from the table",
+			pods:         []corev1.Pod{versionPod("example.com/coredns:1.10.0", ""), versionPod("example.com/coredns:1.9.99", "")},
+			wantLowest:   "1.9.99",
+			wantVersions: []string{"1.10.0", "1.9.99"},
+		},
+		{
+			name:         "untagged image by digest",
+			pods:         []corev1.Pod{versionPod("registry.local:5000/coredns", "docker-pullable://registry.local:5000/coredns@sha256:"+sha1101)},
+			wantLowest:   "1.10.1",
+			wantVersions: []string{"1.10.1"},
+		},
+		{
+			name:         "unknown digest",
+			pods:         []corev1.Pod{versionPod("registry.local:5000/coredns", "sha256:0000")},
+			wantVersions: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lowest, versions := podVersions(tt.pods)
+			if lowest != tt.wantLowest || !reflect.DeepEqual(versions, tt.wantVersions) {
+				t.Errorf("podVersions() = %q, %v; want %q, %v", lowest, versions, tt.wantLowest, tt.wantVersions)
+			}
+		})
+	}
+}
+
+func TestMigrationNotices(t *testing.T) {
+	// The library repeats a notice for every version between current and target
+	notices := []migration.Notice{
+		{Plugin: "proxy", Severity: migration.SevRemoved, ReplacedBy: "forward", Version: "1.7.0"},
+		{Plugin: "proxy", Severity: migration.SevRemoved, ReplacedBy: "forward", Version: "1.8.0"},
+		{Plugin: "kubernetes", Option: "upstream", Severity: migration.SevDeprecated, Version: "1.7.0"},
+		{Plugin: "kubernetes", Option: "upstream", Severity: migration.SevRemoved, Version: "1.8.0"},
+		{Plugin: "kubernetes", Option: "upstream", Severity: migration.SevRemoved, Version: "1.9.0"},
+		{Plugin: "kubernetes", Option: "resyncperiod", Severity: migration.SevDeprecated, Version: "1.7.0"},
+	}
+
+	got := migrationNotices(notices)
+
+	type key struct{ plugin, option, severity, version string }
+	want := []key{
+		{"proxy", "", migration.SevRemoved, "1.7.0"},
+		{"kubernetes", "upstream", migration.SevDeprecated, "1.7.0"},
+		{"kubernetes", "upstream", migration.SevRemoved, "1.8.0"},
+		{"kubernetes", "resyncperiod", migration.SevDeprecated, "1.7.0"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("migrationNotices() = %+v, want %d notices", got, len(want))
+	}
+	for i, n := range got {
+		if k := (key{n.Plugin, n.Option, n.Severity, n.Version}); k != want[i] {
+			t.Errorf("notice %d = %+v, want %+v", i, k, want[i])
+		}
+	}
+	if got[0].Message != `Plugin "proxy" is removed in 1.7.0. It is replaced by "forward".` {
+		t.Errorf("Message = %q", got[0].Message)
+	}
+}
@@ -0,0 +1,248 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"coredns-multi-configuration/pkg/models"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	NodeLocalDNSName             = "node-local-dns"    // ConfigMap and DaemonSet
+	NodeLocalUpstreamServiceName = "kube-dns-upstream" // CoreDNS service used by node-local-dns
+
+	// Comment line marking server blocks mirrored by the manager
+	nodeLocalMirrorMarker = "# coredns-manager: mirrored forward rule"
+)
+
+// Placeholders of the NodeLocal DNSCache manifests. The first three are
+// substituted when deploying, the last two by node-cache at runtime.
+const (
+	PillarLocalDNS        = "__PILLAR__LOCAL__DNS__"
+	PillarDNSServer       = "__PILLAR__DNS__SERVER__"
+	PillarDNSDomain       = "__PILLAR__DNS__DOMAIN__"
+	PillarClusterDNS      = "__PILLAR__CLUSTER__DNS__"
+	PillarUpstreamServers = "__PILLAR__UPSTREAM__SERVERS__"
+)
+
+// NodeLocalInfo describes the NodeLocal DNSCache of a cluster
+type NodeLocalInfo struct {
+	Detected      bool                 `json:"detected"`
+	Corefile      string               `json:"corefile,omitempty"`
+	Rendered      string               `json:"rendered,omitempty"`  // Corefile with known placeholders substituted
+	Variables     map[string]string    `json:"variables,omitempty"` // placeholder values found in the cluster
+	MirroredRules []models.ForwardRule `json:"mirrored_rules"`
+}
+
+// GetNodeLocalInfo detects NodeLocal DNSCache and returns its Corefile
+func (h *CoreDNSHandler) GetNodeLocalInfo(ctx context.Context, cluster *models.Cluster) (*NodeLocalInfo, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &NodeLocalInfo{MirroredRules: make([]models.ForwardRule, 0)}
+
+	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, NodeLocalDNSName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node-local-dns configmap: %w", err)
+	}
+
+	info.Detected = true
+	info.Corefile = configMap.Data[CorefileName]
+	info.Variables = nodeLocalVariables(ctx, client)
+	info.Rendered = renderPillars(info.Corefile, info.Variables)
+	info.MirroredRules = ParseForwardRules(mirroredBlocks(info.Corefile))
+
+	return info, nil
+}
+
+// SyncNodeLocal mirrors forward rules into the node-local-dns Corefile so
+// queries for them reach CoreDNS instead of the node's upstream resolvers.
+// Blocks mirrored earlier are replaced. It returns false if the cluster does
+// not run NodeLocal DNSCache.
+func (h *CoreDNSHandler) SyncNodeLocal(ctx context.Context, cluster *models.Cluster, rules []models.ForwardRule) (bool, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return false, err
+	}
+
+	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, NodeLocalDNSName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get node-local-dns configmap: %w", err)
+	}
+
+	current := configMap.Data[CorefileName]
+	corefile := mirrorRules(current, rules)
+	if corefile == current {
+		return true, nil
+	}
+
+	configMap.Data[CorefileName] = corefile
+	if _, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Update(ctx, configMap, metav1.UpdateOptions{}); err != nil {
+		return true, fmt.Errorf("failed to update node-local-dns configmap: %w", err)
+	}
+	return true, nil
+}
+
+// mirrorNodeLocal mirrors the forward rules of a Corefile written to CoreDNS.
+// Failures are logged since the CoreDNS change itself succeeded.
+func (h *CoreDNSHandler) mirrorNodeLocal(ctx context.Context, cluster *models.Cluster, corefile string) {
+	if _, err := h.SyncNodeLocal(ctx, cluster, ParseForwardRules(corefile)); err != nil {
+		log.Printf("Failed to mirror forward rules to node-local-dns on cluster %s: %v", cluster.Name, err)
+	}
+}
+
+// nodeLocalVariables looks up the values of the __PILLAR__ placeholders
+func nodeLocalVariables(ctx context.Context, client kubernetes.Interface) map[string]string {
+	vars := map[string]string{
+		PillarDNSDomain:       "cluster.local",
+		PillarUpstreamServers: "/etc/resolv.conf",
+	}
+
+	if svc, err := client.CoreV1().Services(CoreDNSNamespace).Get(ctx, KubeDNSServiceName, metav1.GetOptions{}); err == nil {
+		vars[PillarDNSServer] = svc.Spec.ClusterIP
+	}
+	if svc, err := client.CoreV1().Services(CoreDNSNamespace).Get(ctx, NodeLocalUpstreamServiceName, metav1.GetOptions{}); err == nil {
+		vars[PillarClusterDNS] = svc.Spec.ClusterIP
+	}
+
+	// The link-local address is passed to node-cache as -localip
+	if ds, err := client.AppsV1().DaemonSets(CoreDNSNamespace).Get(ctx, NodeLocalDNSName, metav1.GetOptions{}); err == nil {
+		for _, c := range ds.Spec.Template.Spec.Containers {
+			for i, arg := range c.Args {
+				value := ""
+				if strings.HasPrefix(arg, "-localip=") {
+					value = strings.TrimPrefix(arg, "-localip=")
+				} else if arg == "-localip" && i+1 < len(c.Args) {
+					value = c.Args[i+1]
+				}
+				if value != "" {
+					vars[PillarLocalDNS] = strings.Split(value, ",")[0]
+				}
+			}
+		}
+	}
+
+	return vars
+}
+
+// renderPillars substitutes the known placeholders in a Corefile
+func renderPillars(corefile string, vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(vars)*2)
+	for _, k := range keys {
+		pairs = append(pairs, k, vars[k])
+	}
+	return strings.NewReplacer(pairs...).Replace(corefile)
+}
+
+// mirrorRules replaces the mirrored blocks of a node-local-dns Corefile with
+// blocks forwarding each rule's zone to CoreDNS. The bind and forward settings
+// are copied from the cluster.local block so placeholders stay consistent.
+func mirrorRules(corefile string, rules []models.ForwardRule) string {
+	bind := "bind " + PillarLocalDNS + " " + PillarDNSServer
+	upstream := PillarClusterDNS
+
+	lines := strings.Split(corefile, "\n")
+	for _, header := range []string{"cluster.local:53", PillarDNSDomain + ":53"} {
+		start, end := blockRange(lines, header)
+		if start < 0 {
+			continue
+		}
+		for _, line := range lines[start+1 : end] {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 && fields[0] == "bind" {
+				bind = strings.Join(fields, " ")
+			}
+			if len(fields) >= 3 && fields[0] == "forward" && fields[1] == "." {
+				upstream = fields[2]
+			}
+		}
+		break
+	}
+
+	result := strings.TrimRight(removeMirroredBlocks(corefile), "\n")
+	for _, rule := range rules {
+		result += fmt.Sprintf(`
+
+%s
+%s {
+    errors
+    cache 30
+    reload
+    %s
+    forward . %s {
+        force_tcp
+    }
+}`, nodeLocalMirrorMarker, rule.GetDomainBlock(), bind, upstream)
+	}
+	return result + "\n"
+}
+
+// removeMirroredBlocks removes the server blocks preceded by the mirror marker
+func removeMirroredBlocks(corefile string) string {
+	return strings.Join(splitMirroredBlocks(corefile, false), "\n")
+}
+
+// mirroredBlocks returns only the server blocks mirrored by the manager
+func mirroredBlocks(corefile string) string {
+	return strings.Join(splitMirroredBlocks(corefile, true), "\n")
+}
+
+// splitMirroredBlocks returns the lines of the mirrored blocks if mirrored is
+// true, and all other lines otherwise
+func splitMirroredBlocks(corefile string, mirrored bool) []string {
+	var result []string
+	inBlock, marked := false, false
+	depth := 0
+
+	for _, line := range strings.Split(corefile, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if !inBlock && trimmed == nodeLocalMirrorMarker {
+			marked = true
+			continue
+		}
+		if marked && !inBlock && strings.HasSuffix(trimmed, "{") {
+			inBlock = true
+		}
+
+		if inBlock {
+			depth += strings.Count(line, "{") - strings.Count(line, "}")
+			if mirrored {
+				result = append(result, line)
+			}
+			if depth <= 0 {
+				inBlock, marked = false, false
+				depth = 0
+			}
+			continue
+		}
+
+		marked = false
+		if !mirrored {
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,165 @@
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+const testNodeLocalCorefile = `cluster.local:53 {
+    errors
+    cache {
+        success 9984 30
+        denial 9984 5
+    }
+    reload
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+}
+.:53 {
+    errors
+    cache 30
+    reload
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__UPSTREAM__SERVERS__
+}
+`
+
+// mirroredBlock is the block mirrorRules writes for a zone with the bind and
+// upstream of testNodeLocalCorefile
+func mirroredBlock(zone string) string {
+	return `
+` + nodeLocalMirrorMarker + `
+` + zone + ` {
+    errors
+    cache 30
+    reload
+    bind 169.254.20.10 10.96.0.10
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+}`
+}
+
+func TestMirrorRules(t *testing.T) {
+	payments := models.ForwardRule{Namespace: "payments", TargetIP: "10.1.0.10"}
+	mysql := models.ForwardRule{Namespace: "payments", ServiceName: "mysql", TargetIP: "10.2.0.10", IsFullFQDN: true}
+	base := strings.TrimRight(testNodeLocalCorefile, "\n")
+
+	tests := []struct {
+		name     string
+		corefile string
+		rules    []models.ForwardRule
+		want     string
+	}{
+		{
+			name:     "no rules",
+			corefile: testNodeLocalCorefile,
+			want:     testNodeLocalCorefile,
+		},
+		{
+			name:     "mirror rules",
+			corefile: testNodeLocalCorefile,
+			rules:    []models.ForwardRule{payments, mysql},
+			want:     base + "\n" + mirroredBlock("payments:53") + "\n" + mirroredBlock("mysql.payments.svc.cluster.local:53") + "\n",
+		},
+		{
+			name:     "re-mirror replaces the mirrored blocks",
+			corefile: base + "\n" + mirroredBlock("payments:53") + "\n" + mirroredBlock("orders:53") + "\n",
+			rules:    []models.ForwardRule{mysql},
+			want:     base + "\n" + mirroredBlock("mysql.payments.svc.cluster.local:53") + "\n",
+		},
+		{
+			name:     "re-mirror without rules removes the mirrored blocks",
+			corefile: base + "\n" + mirroredBlock("payments:53") + "\n",
+			want:     testNodeLocalCorefile,
+		},
+		{
+			name:     "no cluster.local block uses the placeholders",
+			corefile: ".:53 {\n    forward . 8.8.8.8\n}\n",
+			rules:    []models.ForwardRule{payments},
+			want: ".:53 {\n    forward . 8.8.8.8\n}\n\n" + nodeLocalMirrorMarker + `
+payments:53 {
+    errors
+    cache 30
+    reload
+    bind __PILLAR__LOCAL__DNS__ __PILLAR__DNS__SERVER__
+    forward . __PILLAR__CLUSTER__DNS__ {
+        force_tcp
+    }
+}
+`,
+		},
+		{
+			name:     "domain placeholder block",
+			corefile: strings.Replace(testNodeLocalCorefile, "cluster.local:53", PillarDNSDomain+":53", 1),
+			rules:    []models.ForwardRule{payments},
+			want:     strings.Replace(base, "cluster.local:53", PillarDNSDomain+":53", 1) + "\n" + mirroredBlock("payments:53") + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mirrorRules(tt.corefile, tt.rules)
+			if got != tt.want {
+				t.Errorf("mirrorRules() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if again := mirrorRules(got, tt.rules); again != got {
+				t.Errorf("mirrorRules() is not idempotent, second run =\n%s", again)
+			}
+		})
+	}
+}
+
+func TestSplitMirroredBlocks(t *testing.T) {
+	mirrored := mirrorRules(testNodeLocalCorefile, []models.ForwardRule{{Namespace: "payments"}})
+	block := strings.Split(strings.TrimPrefix(mirroredBlock("payments:53"), "\n"+nodeLocalMirrorMarker+"\n"), "\n")
+
+	tests := []struct {
+		name     string
+		corefile string
+		mirrored bool
+		want     []string
+	}{
+		{
+			name:     "mirrored lines without the marker",
+			corefile: mirrored,
+			mirrored: true,
+			want:     block,
+		},
+		{
+			name:     "other lines",
+			corefile: mirrored,
+			want:     append(strings.Split(strings.TrimRight(testNodeLocalCorefile, "\n"), "\n"), "", ""),
+		},
+		{
+			name:     "nothing mirrored",
+			corefile: testNodeLocalCorefile,
+			mirrored: true,
+		},
+		{
+			name:     "unmarked blocks are not mirrored",
+			corefile: testNodeLocalCorefile,
+			want:     strings.Split(testNodeLocalCorefile, "\n"),
+		},
+		{
+			name:     "marker only applies to the next block",
+			corefile: nodeLocalMirrorMarker + "\na:53 {\n    forward . 1.1.1.1\n}\nb:53 {\n    forward . 2.2.2.2\n}",
+			mirrored: true,
+			want:     []string{"a:53 {", "    forward . 1.1.1.1", "}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMirroredBlocks(tt.corefile, tt.mirrored)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMirroredBlocks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package k8s
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultServiceAccountName is the ServiceAccount name of the onboarding bundle
+const DefaultServiceAccountName = "coredns-manager"
+
+// onboardingObject is one manifest of the onboarding bundle with the comment
+// written above it
+type onboardingObject struct {
+	comment string
+	object  interface{}
+}
+
+// OnboardingManifests returns the manifests granting the manager the least
+// privileges it needs in a cluster: a ServiceAccount, a Role and RoleBinding
+// scoped to the CoreDNS objects in the DNS namespace, a ClusterRole for
+// shadowing detection and a long-lived token Secret. backups is the number of
+// backup ConfigMaps the manager may rotate through.
+func OnboardingManifests(name string, backups int) ([]byte, error) {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid service account name %q: %v", name, errs)
+	}
+
+	meta := metav1.ObjectMeta{Name: name, Namespace: CoreDNSNamespace}
+	rules := []rbacv1.PolicyRule{
+		{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{CoreDNSConfigMapName, NodeLocalDNSName},
+			Verbs:         []string{"get", "list", "watch", "update"},
+		},
+		{
+			APIGroups:     []string{""},
+			Resources:     []string{"services"},
+			ResourceNames: []string{KubeDNSServiceName, NodeLocalUpstreamServiceName},
+			Verbs:         []string{"get", "list", "watch"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods", "events"},
+			Verbs:     []string{"get", "list"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"pods/log"},
+			Verbs:     []string{"get"},
+		},
+		{
+			APIGroups: []string{""},
+			Resources: []string{"events"},
+			Verbs:     []string{"create"},
+		},
+		{
+			APIGroups: []string{"apps"},
+			Resources: []string{"deployments", "daemonsets"},
+			Verbs:     []string{"get", "list"},
+		},
+		{
+			APIGroups:     []string{"apps"},
+			Resources:     []string{"deployments"},
+			ResourceNames: []string{CoreDNSDeploymentName},
+			Verbs:         []string{"patch"},
+		},
+	}
+	if backups > 0 {
+		// create can't be limited to names; backups are read by slot name
+		names := make([]string, backups)
+		for i := range names {
+			names[i] = BackupConfigMapPrefix + strconv.Itoa(i)
+		}
+		rules = append(rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"create"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups:     []string{""},
+				Resources:     []string{"configmaps"},
+				ResourceNames: names,
+				Verbs:         []string{"get", "update"},
+			},
+		)
+	}
+
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: CoreDNSNamespace}}
+
+	objects := []onboardingObject{
+		{
+			comment: "Identity of the CoreDNS manager",
+			object: &corev1.ServiceAccount{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+				ObjectMeta: meta,
+			},
+		},
+		{
+			comment: "CoreDNS objects the manager reads and writes",
+			object: &rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: meta,
+				Rules:      rules,
+			},
+		},
+		{
+			object: &rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: meta,
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+			},
+		},
+		{
+			comment: "Read-only lookups of forwarded namespaces and services for shadowing\n" +
+				"detection. Omit this ClusterRole and its binding to disable the check.",
+			object: &rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Rules: []rbacv1.PolicyRule{{
+					APIGroups: []string{""},
+					Resources: []string{"namespaces", "services"},
+					Verbs:     []string{"get"},
+				}},
+			},
+		},
+		{
+			object: &rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Subjects:   subjects,
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			},
+		},
+		{
+			comment: "Long-lived token of the ServiceAccount, filled in by the token controller",
+			object: &corev1.Secret{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        TokenSecretName(name),
+					Namespace:   CoreDNSNamespace,
+					Annotations: map[string]string{corev1.ServiceAccountNameKey: name},
+				},
+				Type: corev1.SecretTypeServiceAccountToken,
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	for i, o := range objects {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		if o.comment != "" {
+			for _, line := range bytes.Split([]byte(o.comment), []byte("\n")) {
+				buf.WriteString("# ")
+				buf.Write(line)
+				buf.WriteString("\n")
+			}
+		}
+		data, err := yaml.Marshal(o.object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render manifests: %w", err)
+		}
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+// TokenSecretName returns the name of the token Secret of the onboarding bundle
+func TokenSecretName(serviceAccount string) string {
+	return serviceAccount + "-token"
+}
+
+// ParseTokenSecret extracts the bearer token and CA certificate from a
+// ServiceAccount token Secret in YAML or JSON, e.g. the output of
+// kubectl get secret -o yaml
+func ParseTokenSecret(data []byte) (string, []byte, error) {
+	var secret corev1.Secret
+	if err := yaml.Unmarshal(data, &secret); err != nil {
+		return "", nil, fmt.Errorf("failed to parse secret: %w", err)
+	}
+	if secret.Type != "" && secret.Type != corev1.SecretTypeServiceAccountToken {
+		return "", nil, fmt.Errorf("secret %s is of type %s, expected %s", secret.Name, secret.Type, corev1.SecretTypeServiceAccountToken)
+	}
+
+	token := secret.Data[corev1.ServiceAccountTokenKey]
+	if len(token) == 0 {
+		return "", nil, fmt.Errorf("secret %s has no token yet, wait for the token controller to fill it in", secret.Name)
+	}
+	return string(token), secret.Data[corev1.ServiceAccountRootCAKey], nil
+}
@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseTokenSecret(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	const ca = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+	tests := []struct {
+		name      string
+		secret    string
+		wantToken string
+		wantCA    string
+		wantErr   string
+	}{
+		{
+			name: "kubectl YAML",
+			secret: `apiVersion: v1
+kind: Secret
+type: kubernetes.io/service-account-token
+metadata:
+  name: coredns-manager-token
+  namespace: kube-system
+  annotations:
+    kubernetes.io/service-account.name: coredns-manager
+data:
+  ca.crt: ` + b64(ca) + `
+  namespace: ` + b64("kube-system") + `
+  token: ` + b64("eyJhbGciOi.token") + `
+`,
+			wantToken: "eyJhbGciOi.token",
+			wantCA:    ca,
+		},
+		{
+			name:      "JSON without CA",
+			secret:    `{"kind":"Secret","metadata":{"name":"t"},"type":"kubernetes.io/service-account-token","data":{"token":"` + b64("abc") + `"}}`,
+			wantToken: "abc",
+		},
+		{
+			name:      "type omitted",
+			secret:    "metadata:\n  name: t\ndata:\n  token: " + b64("abc") + "\n",
+			wantToken: "abc",
+		},
+		{
+			name:    "token not filled in yet",
+			secret:  "type: kubernetes.io/service-account-token\nmetadata:\n  name: coredns-manager-token\n",
+			wantErr: "secret coredns-manager-token has no token yet",
+		},
+		{
+			name:    "other secret type",
+			secret:  "type: Opaque\nmetadata:\n  name: db\ndata:\n  token: " + b64("abc") + "\n",
+			wantErr: "secret db is of type Opaque",
+		},
+		{
+			name:    "data not base64",
+			secret:  "metadata:\n  name: t\ndata:\n  token: not base64!\n",
+			wantErr: "failed to parse secret",
+		},
+		{
+			name:    "not a secret",
+			secret:  "- a\n- b\n",
+			wantErr: "failed to parse secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, caData, err := ParseTokenSecret([]byte(tt.secret))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ParseTokenSecret() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTokenSecret() error = %v", err)
+			}
+			if token != tt.wantToken || string(caData) != tt.wantCA {
+				t.Errorf("ParseTokenSecret() = %q, %q; want %q, %q", token, caData, tt.wantToken, tt.wantCA)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxQueryLogDuration limits how long query logging stays enabled
+	MaxQueryLogDuration = time.Hour
+
+	maxQueryLogLines    = 1000
+	maxQueryLogSessions = 20
+	logDirective        = "log"
+
+	// queryLogRetryInterval is how long to wait before removing a log
+	// directive again after it could not be removed
+	queryLogRetryInterval = time.Minute
+
+	// queryLogMarker tags log directives added by a session with their expiry,
+	// so they are removed even if the manager restarts during the session:
+	//   log # coredns-manager:query-log expires=2024-05-01T12:00:00Z
+	queryLogMarker = "# coredns-manager:query-log expires="
+)
+
+var (
+	ErrRuleNotFound      = errors.New("forward rule not found")
+	ErrQueryLogEnabled   = errors.New("log plugin is already enabled for this rule")
+	ErrQueryLogNotActive = errors.New("query log session is not active")
+)
+
+// StartQueryLog enables the log plugin in the server block of a forward rule
+// for the given duration and captures the queries for it from the CoreDNS
+// pods. The log directive is removed again when the session ends.
+func (h *CoreDNSHandler) StartQueryLog(ctx context.Context, cluster *models.Cluster, name string, isFullFQDN bool, duration time.Duration) (*models.QueryLogSession, error) {
+	if duration <= 0 || duration > MaxQueryLogDuration {
+		return nil, fmt.Errorf("duration must be between 1s and %s", MaxQueryLogDuration)
+	}
+
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName, namespace, _ := models.ParseNameInput(name)
+	rule := models.ForwardRule{
+		Namespace:   namespace,
+		ServiceName: serviceName,
+		IsFullFQDN:  isFullFQDN,
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(duration)
+	corefile, err := setBlockLog(info.Corefile, rule, true, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := h.UpdateCorefile(withDefaultReason(ctx, "enable query log for "+rule.GetFullName()), cluster, corefile)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.QueryLogSession{
+		ID:        uuid.New().String(),
+		ClusterID: cluster.ID,
+		Rule:      rule.GetDomainBlock(),
+		State:     models.QueryLogActive,
+		StartedAt: now,
+		ExpiresAt: expiresAt,
+		ChangeID:  change.ID,
+		Lines:     make([]models.LogLine, 0),
+	}
+
+	sessionCtx, cancel := context.WithDeadline(context.Background(), session.ExpiresAt)
+
+	h.mu.Lock()
+	sessions := append(h.queryLogs[cluster.ID], session)
+	if len(sessions) > maxQueryLogSessions {
+		sessions = sessions[len(sessions)-maxQueryLogSessions:]
+	}
+	h.queryLogs[cluster.ID] = sessions
+	h.queryLogStops[session.ID] = cancel
+	result := *session
+	h.mu.Unlock()
+
+	go h.captureQueryLog(sessionCtx, cluster, rule, session)
+
+	return &result, nil
+}
+
+// StopQueryLog ends an active query log session before it expires
+func (h *CoreDNSHandler) StopQueryLog(clusterID, sessionID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.queryLogs[clusterID] {
+		if s.ID == sessionID {
+			cancel, ok := h.queryLogStops[sessionID]
+			if !ok {
+				return ErrQueryLogNotActive
+			}
+			cancel()
+			return nil
+		}
+	}
+	return ErrQueryLogNotActive
+}
+
+// QueryLogs returns the query log sessions of a cluster, newest first
+func (h *CoreDNSHandler) QueryLogs(clusterID string) []models.QueryLogSession {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	sessions := h.queryLogs[clusterID]
+	result := make([]models.QueryLogSession, 0, len(sessions))
+	for i := len(sessions) - 1; i >= 0; i-- {
+		s := *sessions[i]
+		s.Lines = append([]models.LogLine(nil), sessions[i].Lines...)
+		result = append(result, s)
+	}
+	return result
+}
+
+// GetQueryLog returns a query log session by ID
+func (h *CoreDNSHandler) GetQueryLog(clusterID, sessionID string) (*models.QueryLogSession, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, s := range h.queryLogs[clusterID] {
+		if s.ID == sessionID {
+			result := *s
+			result.Lines = append([]models.LogLine(nil), s.Lines...)
+			return &result, true
+		}
+	}
+	return nil, false
+}
+
+// ForgetQueryLogs ends and drops the query log sessions of a removed cluster
+func (h *CoreDNSHandler) ForgetQueryLogs(clusterID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, s := range h.queryLogs[clusterID] {
+		if cancel, ok := h.queryLogStops[s.ID]; ok {
+			cancel()
+		}
+	}
+	delete(h.queryLogs, clusterID)
+	if timer, ok := h.queryLogTimer[clusterID]; ok {
+		timer.Stop()
+		delete(h.queryLogTimer, clusterID)
+	}
+}
+
+// captureQueryLog collects the log lines for a rule until the session ends,
+// then removes the log directive
+func (h *CoreDNSHandler) captureQueryLog(ctx context.Context, cluster *models.Cluster, rule models.ForwardRule, session *models.QueryLogSession) {
+	lines := make(chan models.LogLine, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- h.streamLogs(ctx, cluster, queryLogMatcher(rule), 0, lines)
+	}()
+
+	var streamErr error
+loop:
+	for {
+		select {
+		case line := <-lines:
+			h.mu.Lock()
+			session.Lines = append(session.Lines, line)
+			if len(session.Lines) > maxQueryLogLines {
+				session.Lines = session.Lines[len(session.Lines)-maxQueryLogLines:]
+				session.Truncated = true
+			}
+			h.mu.Unlock()
+		case streamErr = <-done:
+			if streamErr != nil {
+				// Still revert below, the directive must not stay enabled
+				<-ctx.Done()
+			}
+			break loop
+		}
+	}
+
+	revertErr := h.disableQueryLog(cluster, rule)
+	if revertErr != nil {
+		// The directive is marked with its expiry, keep trying to remove it
+		h.scheduleQueryLogCleanup(cluster, queryLogRetryInterval)
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.queryLogStops, session.ID)
+	session.EndedAt = &now
+	session.State = models.QueryLogFinished
+	switch {
+	case revertErr != nil:
+		session.State = models.QueryLogFailed
+		session.Error = fmt.Sprintf("failed to remove the log directive: %v", revertErr)
+		log.Printf("Query log session %s on cluster %s: %s", session.ID, cluster.Name, session.Error)
+	case streamErr != nil:
+		session.State = models.QueryLogFailed
+		session.Error = fmt.Sprintf("failed to capture logs: %v", streamErr)
+	}
+}
+
+// disableQueryLog removes the log directive from a rule's server block
+func (h *CoreDNSHandler) disableQueryLog(cluster *models.Cluster, rule models.ForwardRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return err
+	}
+
+	corefile, err := setBlockLog(info.Corefile, rule, false, time.Time{})
+	if errors.Is(err, ErrRuleNotFound) {
+		return nil // the rule was deleted meanwhile
+	}
+	if err != nil {
+		return err
+	}
+	if corefile == info.Corefile {
+		return nil
+	}
+	_, err = h.UpdateCorefile(WithReason(ctx, "disable query log for "+rule.GetFullName()), cluster, corefile)
+	return err
+}
+
+// ResumeQueryLogs removes the expired log directives left by query log
+// sessions of a previous run and schedules the removal of the others at their
+// expiry. It is called once per cluster at startup; if the Corefile can't be
+// read or written, it is tried again after queryLogRetryInterval.
+func (h *CoreDNSHandler) ResumeQueryLogs(ctx context.Context, cluster *models.Cluster) error {
+	next, err := h.RevertExpiredQueryLogs(ctx, cluster)
+	switch {
+	case err != nil:
+		h.scheduleQueryLogCleanup(cluster, queryLogRetryInterval)
+	case !next.IsZero():
+		h.scheduleQueryLogCleanup(cluster, time.Until(next))
+	}
+	return err
+}
+
+// scheduleQueryLogCleanup runs ResumeQueryLogs for a cluster after d,
+// replacing the cleanup scheduled before
+func (h *CoreDNSHandler) scheduleQueryLogCleanup(cluster *models.Cluster, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if timer, ok := h.queryLogTimer[cluster.ID]; ok {
+		timer.Stop()
+	}
+	h.queryLogTimer[cluster.ID] = time.AfterFunc(d, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := h.ResumeQueryLogs(ctx, cluster); err != nil {
+			log.Printf("Failed to remove expired query logs on cluster %s: %v", cluster.Name, err)
+		}
+	})
+}
+
+// RevertExpiredQueryLogs removes the log directives of query log sessions that
+// have expired. It returns the earliest expiry of the remaining directives, or
+// the zero time if there are none.
+func (h *CoreDNSHandler) RevertExpiredQueryLogs(ctx context.Context, cluster *models.Cluster) (time.Time, error) {
+	info, err := h.freshCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	corefile, next := removeExpiredQueryLogs(info.Corefile, time.Now())
+	if corefile == info.Corefile {
+		return next, nil
+	}
+	if _, err := h.UpdateCorefile(WithReason(ctx, "remove expired query log"), cluster, corefile); err != nil {
+		return time.Time{}, err
+	}
+	return next, nil
+}
+
+// removeExpiredQueryLogs drops the marked log directives that expired before
+// now and returns the earliest expiry of those that remain
+func removeExpiredQueryLogs(corefile string, now time.Time) (string, time.Time) {
+	lines := strings.Split(corefile, "\n")
+	result := make([]string, 0, len(lines))
+	var next time.Time
+	for _, line := range lines {
+		expires, ok := queryLogExpiry(line)
+		if !ok {
+			result = append(result, line)
+			continue
+		}
+		if !expires.After(now) {
+			continue
+		}
+		result = append(result, line)
+		if next.IsZero() || expires.Before(next) {
+			next = expires
+		}
+	}
+	return strings.Join(result, "\n"), next
+}
+
+// queryLogExpiry returns the expiry of a log directive added by a query log
+// session. ok is false for any other line, including log directives added by
+// hand.
+func queryLogExpiry(line string) (time.Time, bool) {
+	directive, marker, found := strings.Cut(strings.TrimSpace(line), " "+queryLogMarker)
+	if !found || strings.TrimSpace(directive) != logDirective {
+		return time.Time{}, false
+	}
+	expires, err := time.Parse(time.RFC3339, strings.TrimSpace(marker))
+	if err != nil {
+		// Unparsable expiry: treat as expired rather than logging forever
+		return time.Time{}, true
+	}
+	return expires, true
+}
+
+// setBlockLog adds or removes the log directive in a rule's server block.
+// An added directive is marked with its expiry. Adding fails if the block
+// already logs, and only a marked directive is removed, so logging configured
+// by hand is left alone.
+func setBlockLog(corefile string, rule models.ForwardRule, enable bool, expires time.Time) (string, error) {
+	lines := strings.Split(corefile, "\n")
+	start, end := blockRange(lines, rule.GetDomainBlock())
+	if start < 0 {
+		return "", ErrRuleNotFound
+	}
+
+	logLine, marked := -1, false
+	for i := start + 1; i < end; i++ {
+		fields := strings.Fields(lines[i])
+		if len(fields) > 0 && fields[0] == logDirective {
+			logLine = i
+			_, marked = queryLogExpiry(lines[i])
+			break
+		}
+	}
+
+	result := make([]string, 0, len(lines)+1)
+	switch {
+	case enable && logLine >= 0:
+		return "", ErrQueryLogEnabled
+	case enable:
+		result = append(result, lines[:start+1]...)
+		result = append(result, "    "+logDirective+" "+queryLogMarker+expires.UTC().Format(time.RFC3339))
+		result = append(result, lines[start+1:]...)
+	case logLine >= 0 && marked:
+		result = append(result, lines[:logLine]...)
+		result = append(result, lines[logLine+1:]...)
+	default:
+		return corefile, nil
+	}
+	return strings.Join(result, "\n"), nil
+}
+
+// blockRange returns the line indexes of the header and closing brace of the
+// server block for a zone such as payments:53, or -1 if there is none
+func blockRange(lines []string, header string) (int, int) {
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, header) || strings.TrimSpace(strings.TrimPrefix(trimmed, header)) != "{" {
+			continue
+		}
+
+		depth := 0
+		for j := i; j < len(lines); j++ {
+			depth += strings.Count(lines[j], "{")
+			depth -= strings.Count(lines[j], "}")
+			if depth <= 0 {
+				return i, j
+			}
+		}
+		return -1, -1
+	}
+	return -1, -1
+}
+
+// queryLogMatcher matches log plugin lines of queries handled by a rule.
+// Query names are logged as received, before the rewrite plugin.
+func queryLogMatcher(rule models.ForwardRule) func(string) bool {
+	zone := rule.GetFullName()
+	if rule.IsFullFQDN {
+		zone = rule.GetFQDN()
+	}
+	exact := " " + zone + ". "
+	sub := "." + zone + ". "
+
+	return func(line string) bool {
+		return strings.Contains(line, exact) || strings.Contains(line, sub)
+	}
+}
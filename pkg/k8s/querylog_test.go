@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+const testCorefile = `.:53 {
+    errors
+    log
+    kubernetes cluster.local in-addr.arpa ip6.arpa
+    forward . /etc/resolv.conf
+}
+payments:53 {
+    rewrite stop {
+        name regex (.*)\.payments\.svc\.cluster\.local\. {1}.payments.svc.cluster.local.
+    }
+    forward . 10.1.0.10
+}
+mysql.payments.svc.cluster.local:53 {
+    forward . 10.2.0.10
+}`
+
+// withLine inserts a line after the header of the block starting with header
+func withLine(corefile, header, line string) string {
+	return strings.Replace(corefile, header+" {\n", header+" {\n"+line+"\n", 1)
+}
+
+func TestBlockRange(t *testing.T) {
+	lines := strings.Split(testCorefile, "\n")
+
+	tests := []struct {
+		name      string
+		header    string
+		wantStart int
+		wantEnd   int
+	}{
+		{name: "root block", header: ".:53", wantStart: 0, wantEnd: 5},
+		{name: "nested braces", header: "payments:53", wantStart: 6, wantEnd: 11},
+		{name: "FQDN block", header: "mysql.payments.svc.cluster.local:53", wantStart: 12, wantEnd: 14},
+		{name: "missing block", header: "orders:53", wantStart: -1, wantEnd: -1},
+		{name: "prefix of another header", header: "payments", wantStart: -1, wantEnd: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := blockRange(lines, tt.header)
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("blockRange() = %d, %d; want %d, %d", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+
+	if start, end := blockRange([]string{"payments:53 {", "    forward . 10.1.0.10"}, "payments:53"); start != -1 || end != -1 {
+		t.Errorf("blockRange() of an unclosed block = %d, %d; want -1, -1", start, end)
+	}
+}
+
+func TestSetBlockLog(t *testing.T) {
+	expires := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	marked := "    log " + queryLogMarker + "2024-05-01T12:00:00Z"
+	payments := models.ForwardRule{Namespace: "payments"}
+	mysql := models.ForwardRule{Namespace: "payments", ServiceName: "mysql", IsFullFQDN: true}
+
+	tests := []struct {
+		name     string
+		corefile string
+		rule     models.ForwardRule
+		enable   bool
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "enable adds a marked directive",
+			corefile: testCorefile,
+			rule:     payments,
+			enable:   true,
+			want:     withLine(testCorefile, "payments:53", marked),
+		},
+		{
+			name:     "enable in an FQDN block",
+			corefile: testCorefile,
+			rule:     mysql,
+			enable:   true,
+			want:     withLine(testCorefile, "mysql.payments.svc.cluster.local:53", marked),
+		},
+		{
+			name:     "enable when a session already logs",
+			corefile: withLine(testCorefile, "payments:53", marked),
+			rule:     payments,
+			enable:   true,
+			wantErr:  ErrQueryLogEnabled,
+		},
+		{
+			name:     "enable when the block logs by hand",
+			corefile: withLine(testCorefile, "payments:53", "    log"),
+			rule:     payments,
+			enable:   true,
+			wantErr:  ErrQueryLogEnabled,
+		},
+		{
+			name:     "enable for a missing rule",
+			corefile: testCorefile,
+			rule:     models.ForwardRule{Namespace: "orders"},
+			enable:   true,
+			wantErr:  ErrRuleNotFound,
+		},
+		{
+			name:     "disable removes the marked directive",
+			corefile: withLine(testCorefile, "payments:53", marked),
+			rule:     payments,
+			want:     testCorefile,
+		},
+		{
+			name:     "disable keeps a directive added by hand",
+			corefile: withLine(testCorefile, "payments:53", "    log"),
+			rule:     payments,
+			want:     withLine(testCorefile, "payments:53", "    log"),
+		},
+		{
+			name:     "disable without a directive",
+			corefile: testCorefile,
+			rule:     payments,
+			want:     testCorefile,
+		},
+		{
+			name:     "disable leaves other blocks alone",
+			corefile: withLine(testCorefile, "mysql.payments.svc.cluster.local:53", marked),
+			rule:     payments,
+			want:     withLine(testCorefile, "mysql.payments.svc.cluster.local:53", marked),
+		},
+		{
+			name:     "disable for a missing rule",
+			corefile: testCorefile,
+			rule:     models.ForwardRule{Namespace: "orders"},
+			wantErr:  ErrRuleNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setBlockLog(tt.corefile, tt.rule, tt.enable, expires)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("setBlockLog() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("setBlockLog() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetBlockLogRoundTrip(t *testing.T) {
+	rule := models.ForwardRule{Namespace: "payments"}
+	enabled, err := setBlockLog(testCorefile, rule, true, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	disabled, err := setBlockLog(enabled, rule, false, time.Time{})
+	if err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if disabled != testCorefile {
+		t.Errorf("enable then disable =\n%s\nwant the original Corefile", disabled)
+	}
+}
+
+func TestRemoveExpiredQueryLogs(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	marked := func(expires string) string { return "    log " + queryLogMarker + expires }
+
+	expired := withLine(testCorefile, "payments:53", marked("2024-05-01T11:59:00Z"))
+	active := withLine(testCorefile, "mysql.payments.svc.cluster.local:53", marked("2024-05-01T12:30:00Z"))
+	both := withLine(expired, "mysql.payments.svc.cluster.local:53", marked("2024-05-01T12:30:00Z"))
+
+	tests := []struct {
+		name     string
+		corefile string
+		want     string
+		wantNext time.Time
+	}{
+		{name: "nothing marked", corefile: testCorefile, want: testCorefile},
+		{name: "expired directive", corefile: expired, want: testCorefile},
+		{
+			name:     "active directive",
+			corefile: active,
+			want:     active,
+			wantNext: now.Add(30 * time.Minute),
+		},
+		{
+			name:     "expired and active",
+			corefile: both,
+			want:     active,
+			wantNext: now.Add(30 * time.Minute),
+		},
+		{
+			name:     "unparsable expiry counts as expired",
+			corefile: withLine(testCorefile, "payments:53", marked("soon")),
+			want:     testCorefile,
+		},
+		{
+			name:     "unmarked directive is kept",
+			corefile: withLine(testCorefile, "payments:53", "    log"),
+			want:     withLine(testCorefile, "payments:53", "    log"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := removeExpiredQueryLogs(tt.corefile, now)
+			if got != tt.want {
+				t.Errorf("removeExpiredQueryLogs() =\n%s\nwant\n%s", got, tt.want)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("removeExpiredQueryLogs() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	CoreDNSDeploymentName = "coredns"
+
+	// Pod template annotation set by kubectl rollout restart
+	restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+)
+
+// RestartCoreDNS triggers a rolling restart of the CoreDNS Deployment, the
+// same way kubectl rollout restart does
+func (h *CoreDNSHandler) RestartCoreDNS(ctx context.Context, cluster *models.Cluster) (*models.RestartStatus, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := restartDeployment(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return restartStatus(deployment), nil
+}
+
+// GetRestartStatus returns the rollout progress of the CoreDNS Deployment
+func (h *CoreDNSHandler) GetRestartStatus(ctx context.Context, cluster *models.Cluster) (*models.RestartStatus, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := client.AppsV1().Deployments(CoreDNSNamespace).Get(ctx, CoreDNSDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coredns deployment: %w", err)
+	}
+	return restartStatus(deployment), nil
+}
+
+// restartDeployment sets the restartedAt annotation on the CoreDNS pod template
+func restartDeployment(ctx context.Context, client kubernetes.Interface) (*appsv1.Deployment, error) {
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`,
+		restartedAtAnnotation, time.Now().Format(time.RFC3339))
+
+	deployment, err := client.AppsV1().Deployments(CoreDNSNamespace).Patch(ctx, CoreDNSDeploymentName,
+		types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restart coredns deployment: %w", err)
+	}
+	return deployment, nil
+}
+
+// restartStatus reports the rollout progress of a Deployment, following the
+// checks of kubectl rollout status
+func restartStatus(deployment *appsv1.Deployment) *models.RestartStatus {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	status := &models.RestartStatus{
+		Deployment:  deployment.Name,
+		RestartedAt: deployment.Spec.Template.Annotations[restartedAtAnnotation],
+		Desired:     desired,
+		Updated:     deployment.Status.UpdatedReplicas,
+		Ready:       deployment.Status.ReadyReplicas,
+		Available:   deployment.Status.AvailableReplicas,
+	}
+
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			status.Failed = true
+			status.Message = fmt.Sprintf("rollout exceeded its progress deadline: %s", cond.Message)
+			return status
+		}
+	}
+
+	switch {
+	case deployment.Generation > deployment.Status.ObservedGeneration:
+		status.Message = "waiting for the deployment update to be observed"
+	case status.Updated < desired:
+		status.Message = fmt.Sprintf("%d of %d new replicas have been updated", status.Updated, desired)
+	case deployment.Status.Replicas > status.Updated:
+		status.Message = fmt.Sprintf("%d old replicas are pending termination", deployment.Status.Replicas-status.Updated)
+	case status.Available < status.Updated:
+		status.Message = fmt.Sprintf("%d of %d updated replicas are available", status.Available, status.Updated)
+	default:
+		status.Done = true
+		status.Message = "rollout complete"
+	}
+	return status
+}
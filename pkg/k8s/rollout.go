@@ -0,0 +1,518 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// CoreDNSLabelSelector selects the CoreDNS pods
+	CoreDNSLabelSelector = "k8s-app=kube-dns"
+
+	// Defaults of the reload plugin when no interval is given
+	defaultReloadInterval = 30 * time.Second
+	defaultReloadJitter   = 15 * time.Second
+
+	// configMapPropagationDelay is how long the kubelet may take to update
+	// a mounted ConfigMap (sync period plus cache TTL)
+	configMapPropagationDelay = 90 * time.Second
+
+	rolloutPollInterval = 5 * time.Second
+	maxTrackedChanges   = 20
+	logExcerptLines     = 30
+
+	// Log lines written by the reload plugin
+	reloadCompleteLog = "Reloading complete"
+	reloadFailedLog   = "Restart failed"
+)
+
+// trackRollout records a Corefile write and starts verifying it in the background.
+// baseline holds the container restart counts per pod before the write; previous
+// is the Corefile that is restored if the change breaks CoreDNS.
+func (h *CoreDNSHandler) trackRollout(cluster *models.Cluster, client kubernetes.Interface, previous, corefile string, baseline map[string]int32) *models.CorefileChange {
+	reload, interval, jitter := ParseReloadSettings(corefile)
+
+	window := h.rollout.Window
+	if window <= 0 {
+		window = configMapPropagationDelay + interval + jitter
+	}
+
+	now := time.Now()
+	change := &models.CorefileChange{
+		ID:        uuid.New().String(),
+		ClusterID: cluster.ID,
+		State:     models.ChangePending,
+		Reload:    reload,
+		StartedAt: now,
+		Deadline:  now.Add(window),
+		Pods:      make([]models.PodHealth, 0),
+	}
+	if !reload {
+		change.Message = "reload plugin is not enabled, the change takes effect after CoreDNS restarts"
+		if cluster.AutoRestart {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if _, err := restartDeployment(ctx, client); err != nil {
+				change.Message = fmt.Sprintf("reload plugin is not enabled and the automatic restart failed: %v", err)
+			} else {
+				change.Restarted = true
+				change.Message = "reload plugin is not enabled, CoreDNS is restarting to apply the change"
+			}
+			cancel()
+		}
+	}
+
+	h.mu.Lock()
+	changes := append(h.changes[cluster.ID], change)
+	if len(changes) > maxTrackedChanges {
+		changes = changes[len(changes)-maxTrackedChanges:]
+	}
+	h.changes[cluster.ID] = changes
+	result := *change
+	h.mu.Unlock()
+
+	go h.watchRollout(*cluster, client, change, previous, corefile, baseline)
+
+	return &result
+}
+
+// watchRollout polls the CoreDNS pods until the change is healthy, failed
+// or its verification window has passed. Failed changes are rolled back
+// when auto rollback is enabled.
+func (h *CoreDNSHandler) watchRollout(cluster models.Cluster, client kubernetes.Interface, change *models.CorefileChange, previous, corefile string, baseline map[string]int32) {
+	ctx, cancel := context.WithDeadline(context.Background(), change.Deadline.Add(time.Minute))
+	defer cancel()
+
+	defer func() {
+		if change.State == models.ChangeFailed && h.rollout.AutoRollback {
+			h.rollbackChange(&cluster, client, change, previous, corefile)
+		}
+	}()
+
+	ticker := time.NewTicker(rolloutPollInterval)
+	defer ticker.Stop()
+
+	reloaded := make(map[string]bool)
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.finishChange(change, models.ChangeFailed, "verification timed out")
+			return
+		case <-ticker.C:
+		}
+
+		pods, failure, failedPod := h.observePods(ctx, client, change, baseline, reloaded)
+
+		h.mu.Lock()
+		change.Pods = pods
+		change.Reloaded = len(reloaded)
+		h.mu.Unlock()
+
+		if failure != "" {
+			h.recordFailedPod(ctx, client, change, failedPod)
+			h.finishChange(change, models.ChangeFailed, failure)
+			return
+		}
+
+		allReady := len(pods) > 0
+		for _, p := range pods {
+			allReady = allReady && p.Ready
+		}
+
+		if allReady && change.Reload && len(reloaded) >= len(pods) {
+			h.finishChange(change, models.ChangeHealthy, "all CoreDNS pods reloaded the Corefile")
+			return
+		}
+
+		if allReady && change.Restarted {
+			deployment, err := client.AppsV1().Deployments(CoreDNSNamespace).Get(ctx, CoreDNSDeploymentName, metav1.GetOptions{})
+			if err == nil && restartStatus(deployment).Done {
+				h.finishChange(change, models.ChangeHealthy, "CoreDNS restarted with the new Corefile")
+				return
+			}
+		}
+
+		if time.Now().After(change.Deadline) {
+			switch {
+			case len(pods) == 0:
+				h.finishChange(change, models.ChangeFailed, "no CoreDNS pods found")
+			case !allReady:
+				for _, p := range pods {
+					if !p.Ready {
+						h.recordFailedPod(ctx, client, change, p.Name)
+						break
+					}
+				}
+				h.finishChange(change, models.ChangeFailed, "CoreDNS pods are not ready")
+			case change.Reload:
+				h.finishChange(change, models.ChangeHealthy,
+					fmt.Sprintf("pods are ready, %d of %d confirmed a reload (an unchanged Corefile is not reloaded)", len(reloaded), len(pods)))
+			default:
+				h.finishChange(change, models.ChangeHealthy, change.Message)
+			}
+			return
+		}
+	}
+}
+
+// observePods returns the health of every CoreDNS pod, and a failure
+// description and the failing pod if any pod crashed, restarted or rejected
+// the new Corefile
+func (h *CoreDNSHandler) observePods(ctx context.Context, client kubernetes.Interface, change *models.CorefileChange,
+	baseline map[string]int32, reloaded map[string]bool) ([]models.PodHealth, string, string) {
+	podList, err := h.GetDeployment(ctx, client)
+	if err != nil {
+		// Transient API errors are retried on the next poll
+		return change.Pods, "", ""
+	}
+
+	pods := make([]models.PodHealth, 0, len(podList.Items))
+	failure, failedPod := "", ""
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		health := podHealth(pod)
+
+		if reason := podFailure(health, baseline[pod.Name]); reason != "" && failure == "" {
+			failure, failedPod = reason, pod.Name
+		}
+
+		if change.Reload && !reloaded[pod.Name] && pod.Status.Phase == corev1.PodRunning {
+			switch scanReloadLogs(ctx, client, pod.Name, change.StartedAt) {
+			case reloadCompleteLog:
+				reloaded[pod.Name] = true
+			case reloadFailedLog:
+				if failure == "" {
+					failure, failedPod = fmt.Sprintf("pod %s rejected the new Corefile and kept the previous one", pod.Name), pod.Name
+				}
+			}
+		}
+		health.Reloaded = reloaded[pod.Name]
+
+		pods = append(pods, health)
+	}
+
+	return pods, failure, failedPod
+}
+
+// podFailure describes why a pod failed after a change, or returns "" if it
+// didn't. baseline is its container restart count before the change.
+func podFailure(health models.PodHealth, baseline int32) string {
+	switch {
+	case health.Restarts > baseline:
+		return fmt.Sprintf("pod %s restarted after the change", health.Name)
+	case health.Reason == "CrashLoopBackOff":
+		return fmt.Sprintf("pod %s is in CrashLoopBackOff", health.Name)
+	}
+	return ""
+}
+
+// recordFailedPod stores the failing pod and an excerpt of its logs in the change.
+// Logs of the previous container are used if it crashed.
+func (h *CoreDNSHandler) recordFailedPod(ctx context.Context, client kubernetes.Interface, change *models.CorefileChange, podName string) {
+	if podName == "" {
+		return
+	}
+
+	excerpt := podLogExcerpt(ctx, client, podName, true)
+	if excerpt == "" {
+		excerpt = podLogExcerpt(ctx, client, podName, false)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	change.FailedPod = podName
+	change.LogExcerpt = excerpt
+}
+
+// rollbackChange restores the Corefile that was live before a failed change.
+// Nothing is restored if the Corefile was changed again in the meantime.
+func (h *CoreDNSHandler) rollbackChange(cluster *models.Cluster, client kubernetes.Interface, change *models.CorefileChange, previous, failed string) {
+	setResult := func(rolledBack bool, rollbackErr string) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		change.RolledBack = rolledBack
+		change.RollbackError = rollbackErr
+	}
+
+	if previous == "" {
+		setResult(false, "no previous Corefile to restore")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	configMap, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(ctx, CoreDNSConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		setResult(false, fmt.Sprintf("failed to get coredns configmap: %v", err))
+		return
+	}
+	if configMap.Data[CorefileName] != failed {
+		setResult(false, "Corefile was changed again after this change, not rolled back")
+		return
+	}
+
+	// Keep the failed Corefile for inspection; restoring matters more than the backup
+	if err := h.backupCorefile(ctx, client, failed, SystemUser, "rollback"); err != nil {
+		log.Printf("Failed to back up the failed Corefile of cluster %s: %v", cluster.Name, err)
+	}
+
+	reason := "roll back failed change " + change.ID
+	configMap.Data[CorefileName] = previous
+	revision := annotateChange(configMap, SystemUser, reason)
+	updated, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	if err != nil {
+		setResult(false, fmt.Sprintf("failed to update coredns configmap: %v", err))
+		return
+	}
+	recordChangeEvent(ctx, client, updated, revision, SystemUser, reason)
+
+	// Without the reload plugin the restored Corefile also needs a restart
+	if change.Restarted {
+		if _, err := restartDeployment(ctx, client); err != nil {
+			setResult(true, fmt.Sprintf("previous Corefile restored but CoreDNS restart failed: %v", err))
+			return
+		}
+	}
+
+	setResult(true, "")
+	log.Printf("Corefile change %s on cluster %s rolled back", change.ID, cluster.Name)
+
+	if cluster.MirrorNodeLocal {
+		h.mirrorNodeLocal(ctx, cluster, previous)
+	}
+
+	h.mu.RLock()
+	onRollback := h.onRollback
+	h.mu.RUnlock()
+	if onRollback != nil {
+		onRollback(change.ClusterID, previous)
+	}
+}
+
+// finishChange sets the final state of a change
+func (h *CoreDNSHandler) finishChange(change *models.CorefileChange, state, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	change.State = state
+	change.Message = message
+	change.FinishedAt = &now
+
+	if state == models.ChangeFailed {
+		log.Printf("Corefile change %s on cluster %s failed: %s", change.ID, change.ClusterID, message)
+	}
+}
+
+// restartBaseline returns the current container restart count of every CoreDNS pod
+func (h *CoreDNSHandler) restartBaseline(ctx context.Context, client kubernetes.Interface) map[string]int32 {
+	baseline := make(map[string]int32)
+	pods, err := h.GetDeployment(ctx, client)
+	if err != nil {
+		return baseline
+	}
+	for i := range pods.Items {
+		baseline[pods.Items[i].Name] = podHealth(&pods.Items[i]).Restarts
+	}
+	return baseline
+}
+
+// Changes returns the tracked Corefile changes of a cluster, newest first
+func (h *CoreDNSHandler) Changes(clusterID string) []models.CorefileChange {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	changes := h.changes[clusterID]
+	result := make([]models.CorefileChange, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		result = append(result, *changes[i])
+	}
+	return result
+}
+
+// GetChange returns a tracked Corefile change by ID
+func (h *CoreDNSHandler) GetChange(clusterID, changeID string) (*models.CorefileChange, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, change := range h.changes[clusterID] {
+		if change.ID == changeID {
+			result := *change
+			return &result, true
+		}
+	}
+	return nil, false
+}
+
+// ForgetChanges drops the tracked changes of a deleted cluster
+func (h *CoreDNSHandler) ForgetChanges(clusterID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.changes, clusterID)
+}
+
+// podHealth summarizes the status of a CoreDNS pod
+func podHealth(pod *corev1.Pod) models.PodHealth {
+	health := models.PodHealth{
+		Name:  pod.Name,
+		Node:  pod.Spec.NodeName,
+		Phase: string(pod.Status.Phase),
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			health.Ready = cond.Status == corev1.ConditionTrue
+		}
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		health.Restarts += cs.RestartCount
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			health.Reason = cs.State.Waiting.Reason
+		} else if cs.State.Terminated != nil && cs.State.Terminated.Reason != "" {
+			health.Reason = cs.State.Terminated.Reason
+		}
+	}
+
+	return health
+}
+
+// scanReloadLogs looks for reload plugin messages in a pod's logs since a
+// point in time and returns reloadCompleteLog, reloadFailedLog or ""
+func scanReloadLogs(ctx context.Context, client kubernetes.Interface, podName string, since time.Time) string {
+	sinceTime := metav1.NewTime(since)
+	limitBytes := int64(256 * 1024)
+
+	stream, err := client.CoreV1().Pods(CoreDNSNamespace).GetLogs(podName, &corev1.PodLogOptions{
+		SinceTime:  &sinceTime,
+		LimitBytes: &limitBytes,
+	}).Stream(ctx)
+	if err != nil {
+		return ""
+	}
+	defer stream.Close()
+
+	return reloadMarker(stream)
+}
+
+// reloadMarker returns the last reload plugin message in a log, reloadCompleteLog,
+// reloadFailedLog or ""
+func reloadMarker(logs io.Reader) string {
+	result := ""
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, reloadFailedLog) {
+			result = reloadFailedLog
+		} else if strings.Contains(line, reloadCompleteLog) {
+			result = reloadCompleteLog
+		}
+	}
+	return result
+}
+
+// podLogExcerpt returns the last lines of a CoreDNS pod's logs, of the
+// previous container instance if previous is true
+func podLogExcerpt(ctx context.Context, client kubernetes.Interface, podName string, previous bool) string {
+	tailLines := int64(logExcerptLines)
+	data, err := client.CoreV1().Pods(CoreDNSNamespace).GetLogs(podName, &corev1.PodLogOptions{
+		TailLines: &tailLines,
+		Previous:  previous,
+	}).DoRaw(ctx)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// ParseReloadSettings reports whether the root server block of a Corefile
+// enables the reload plugin, and its interval and jitter
+func ParseReloadSettings(corefile string) (enabled bool, interval, jitter time.Duration) {
+	interval, jitter = defaultReloadInterval, defaultReloadJitter
+
+	for _, line := range rootBlockLines(corefile) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "reload" {
+			continue
+		}
+
+		enabled = true
+		if len(fields) > 1 {
+			if d, err := time.ParseDuration(fields[1]); err == nil {
+				interval = d
+				jitter = d / 2 // the plugin caps jitter at half the interval
+			}
+		}
+		if len(fields) > 2 {
+			if d, err := time.ParseDuration(fields[2]); err == nil {
+				jitter = d
+			}
+		}
+		break
+	}
+
+	return enabled, interval, jitter
+}
+
+// rootBlockLines returns the top-level directive lines of the root server
+// block (".:53" or "."), without lines of nested blocks
+func rootBlockLines(corefile string) []string {
+	var result []string
+	inRoot := false
+	depth := 0
+
+	for _, line := range strings.Split(corefile, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if idx := strings.Index(trimmed, "#"); idx >= 0 {
+			trimmed = strings.TrimSpace(trimmed[:idx])
+		}
+
+		if !inRoot {
+			if depth == 0 && isRootBlockHeader(trimmed) {
+				inRoot = true
+				depth = 1
+				continue
+			}
+			depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+			continue
+		}
+
+		if depth == 1 && trimmed != "" && trimmed != "}" {
+			result = append(result, trimmed)
+		}
+		depth += strings.Count(trimmed, "{") - strings.Count(trimmed, "}")
+		if depth <= 0 {
+			break
+		}
+	}
+
+	return result
+}
+
+// isRootBlockHeader reports whether a line opens the root server block
+func isRootBlockHeader(trimmed string) bool {
+	if !strings.HasSuffix(trimmed, "{") {
+		return false
+	}
+	keys := strings.Fields(strings.TrimSuffix(trimmed, "{"))
+	for _, key := range keys {
+		if key == "." || key == ".:53" || key == "dns://.:53" || key == "dns://." {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseReloadSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		corefile     string
+		wantEnabled  bool
+		wantInterval time.Duration
+		wantJitter   time.Duration
+	}{
+		{
+			name:         "no reload",
+			corefile:     ".:53 {\n    errors\n    forward . /etc/resolv.conf\n}",
+			wantInterval: defaultReloadInterval,
+			wantJitter:   defaultReloadJitter,
+		},
+		{
+			name:         "defaults",
+			corefile:     ".:53 {\n    errors\n    reload\n}",
+			wantEnabled:  true,
+			wantInterval: defaultReloadInterval,
+			wantJitter:   defaultReloadJitter,
+		},
+		{
+			name:         "custom interval caps the jitter",
+			corefile:     ".:53 {\n    reload 10s\n}",
+			wantEnabled:  true,
+			wantInterval: 10 * time.Second,
+			wantJitter:   5 * time.Second,
+		},
+		{
+			name:         "custom interval and jitter",
+			corefile:     ".:53 {\n    reload 1m 20s\n}",
+			wantEnabled:  true,
+			wantInterval: time.Minute,
+			wantJitter:   20 * time.Second,
+		},
+		{
+			name:         "invalid interval keeps the defaults",
+			corefile:     ".:53 {\n    reload soon\n}",
+			wantEnabled:  true,
+			wantInterval: defaultReloadInterval,
+			wantJitter:   defaultReloadJitter,
+		},
+		{
+			name:         "only in a non-root block",
+			corefile:     ".:53 {\n    errors\n}\npayments:53 {\n    reload 5s\n    forward . 10.1.0.10\n}",
+			wantInterval: defaultReloadInterval,
+			wantJitter:   defaultReloadJitter,
+		},
+		{
+			name:         "commented out",
+			corefile:     ".:53 {\n    # reload\n}",
+			wantInterval: defaultReloadInterval,
+			wantJitter:   defaultReloadJitter,
+		},
+		{
+			name:         "root block after another block",
+			corefile:     "payments:53 {\n    forward . 10.1.0.10\n}\n. {\n    reload 2m\n}",
+			wantEnabled:  true,
+			wantInterval: 2 * time.Minute,
+			wantJitter:   time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled, interval, jitter := ParseReloadSettings(tt.corefile)
+			if enabled != tt.wantEnabled || interval != tt.wantInterval || jitter != tt.wantJitter {
+				t.Errorf("ParseReloadSettings() = %v, %v, %v; want %v, %v, %v",
+					enabled, interval, jitter, tt.wantEnabled, tt.wantInterval, tt.wantJitter)
+			}
+		})
+	}
+}
+
+func TestRootBlockLines(t *testing.T) {
+	corefile := `payments:53 {
+    reload
+}
+.:53 {
+    errors # log errors
+    kubernetes cluster.local in-addr.arpa ip6.arpa {
+        pods insecure
+        reload
+    }
+
+    cache 30
+}
+orders:53 {
+    reload
+}`
+
+	got := rootBlockLines(corefile)
+	want := []string{"errors", "kubernetes cluster.local in-addr.arpa ip6.arpa {", "cache 30"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rootBlockLines() = %q, want %q", got, want)
+	}
+
+	if got := rootBlockLines("payments:53 {\n    reload\n}"); len(got) != 0 {
+		t.Errorf("rootBlockLines() without a root block = %q, want none", got)
+	}
+}
+
+func TestReloadMarker(t *testing.T) {
+	tests := []struct {
+		logs string
+		want string
+	}{
+		{logs: "", want: ""},
+		{logs: "[INFO] plugin/reload: Running configuration SHA512 = 1a2b\n", want: ""},
+		{logs: "[INFO] Reloading\n[INFO] plugin/reload: Reloading complete\n", want: reloadCompleteLog},
+		{logs: "[ERROR] Restart failed: plugin/forward: not an IP address or file\n", want: reloadFailedLog},
+		// The last message wins when a pod reloaded more than once
+		{logs: "[ERROR] Restart failed: x\n[INFO] Reloading complete\n", want: reloadCompleteLog},
+		{logs: "[INFO] Reloading complete\n[ERROR] Restart failed: x\n", want: reloadFailedLog},
+	}
+
+	for _, tt := range tests {
+		if got := reloadMarker(strings.NewReader(tt.logs)); got != tt.want {
+			t.Errorf("reloadMarker(%q) = %q, want %q", tt.logs, got, tt.want)
+		}
+	}
+}
+
+// testPod returns a CoreDNS pod with the given restart count and waiting reason
+func testPod(name string, restarts int32, waiting string) *corev1.Pod {
+	status := corev1.ContainerStatus{Name: "coredns", RestartCount: restarts}
+	if waiting != "" {
+		status.State.Waiting = &corev1.ContainerStateWaiting{Reason: waiting}
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: CoreDNSNamespace,
+			Labels:    map[string]string{"k8s-app": "kube-dns"},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{status},
+		},
+	}
+}
+
+func TestObservePods(t *testing.T) {
+	tests := []struct {
+		name          string
+		pods          []*corev1.Pod
+		baseline      map[string]int32
+		wantFailure   string
+		wantFailedPod string
+	}{
+		{
+			name:     "healthy",
+			pods:     []*corev1.Pod{testPod("coredns-1", 2, ""), testPod("coredns-2", 0, "")},
+			baseline: map[string]int32{"coredns-1": 2, "coredns-2": 0},
+		},
+		{
+			name:          "restarted after the change",
+			pods:          []*corev1.Pod{testPod("coredns-1", 2, ""), testPod("coredns-2", 1, "")},
+			baseline:      map[string]int32{"coredns-1": 2, "coredns-2": 0},
+			wantFailure:   "pod coredns-2 restarted after the change",
+			wantFailedPod: "coredns-2",
+		},
+		{
+			name:          "new pod in CrashLoopBackOff",
+			pods:          []*corev1.Pod{testPod("coredns-3", 0, "CrashLoopBackOff")},
+			baseline:      map[string]int32{},
+			wantFailure:   "pod coredns-3 is in CrashLoopBackOff",
+			wantFailedPod: "coredns-3",
+		},
+		{
+			name:     "earlier restarts are not counted",
+			pods:     []*corev1.Pod{testPod("coredns-1", 5, "ContainerCreating")},
+			baseline: map[string]int32{"coredns-1": 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset()
+			for _, pod := range tt.pods {
+				if _, err := client.CoreV1().Pods(CoreDNSNamespace).Create(context.Background(), pod, metav1.CreateOptions{}); err != nil {
+					t.Fatalf("create pod: %v", err)
+				}
+			}
+
+			h := NewCoreDNSHandler(NewManager(), RolloutOptions{}, BackupOptions{})
+			change := &models.CorefileChange{StartedAt: time.Now()}
+			pods, failure, failedPod := h.observePods(context.Background(), client, change, tt.baseline, map[string]bool{})
+
+			if len(pods) != len(tt.pods) {
+				t.Errorf("observePods() returned %d pods, want %d", len(pods), len(tt.pods))
+			}
+			if failure != tt.wantFailure || failedPod != tt.wantFailedPod {
+				t.Errorf("observePods() failure = %q, %q; want %q, %q", failure, failedPod, tt.wantFailure, tt.wantFailedPod)
+			}
+		})
+	}
+}
+
+func TestRollbackChange(t *testing.T) {
+	const previous, failed = ".:53 {\n    errors\n}", ".:53 {\n    errors\n    forward . bad\n}"
+
+	newClient := func(live string) *fake.Clientset {
+		return fake.NewClientset(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: CoreDNSConfigMapName, Namespace: CoreDNSNamespace},
+			Data:       map[string]string{CorefileName: live},
+		})
+	}
+	liveCorefile := func(t *testing.T, client *fake.Clientset) string {
+		t.Helper()
+		cm, err := client.CoreV1().ConfigMaps(CoreDNSNamespace).Get(context.Background(), CoreDNSConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("get configmap: %v", err)
+		}
+		return cm.Data[CorefileName]
+	}
+	cluster := &models.Cluster{ID: "a", Name: "prod-a"}
+
+	t.Run("restores the previous Corefile", func(t *testing.T) {
+		h := NewCoreDNSHandler(NewManager(), RolloutOptions{AutoRollback: true}, BackupOptions{})
+		client := newClient(failed)
+		change := &models.CorefileChange{ID: "c1", ClusterID: cluster.ID}
+
+		h.rollbackChange(cluster, client, change, previous, failed)
+
+		if !change.RolledBack || change.RollbackError != "" {
+			t.Fatalf("RolledBack = %v, RollbackError = %q; want a rollback", change.RolledBack, change.RollbackError)
+		}
+		if got := liveCorefile(t, client); got != previous {
+			t.Errorf("live Corefile = %q, want the previous one", got)
+		}
+	})
+
+	t.Run("refuses when the Corefile changed again", func(t *testing.T) {
+		h := NewCoreDNSHandler(NewManager(), RolloutOptions{AutoRollback: true}, BackupOptions{})
+		const newer = ".:53 {\n    errors\n    forward . 10.0.0.1\n}"
+		client := newClient(newer)
+		change := &models.CorefileChange{ID: "c1", ClusterID: cluster.ID}
+
+		h.rollbackChange(cluster, client, change, previous, failed)
+
+		if change.RolledBack {
+			t.Error("RolledBack = true, want the newer Corefile kept")
+		}
+		if !strings.Contains(change.RollbackError, "changed again") {
+			t.Errorf("RollbackError = %q, want the Corefile changed again", change.RollbackError)
+		}
+		if got := liveCorefile(t, client); got != newer {
+			t.Errorf("live Corefile = %q, want the newer one kept", got)
+		}
+	})
+
+	t.Run("nothing to restore", func(t *testing.T) {
+		h := NewCoreDNSHandler(NewManager(), RolloutOptions{AutoRollback: true}, BackupOptions{})
+		client := newClient(failed)
+		change := &models.CorefileChange{ID: "c1", ClusterID: cluster.ID}
+
+		h.rollbackChange(cluster, client, change, "", failed)
+
+		if change.RolledBack || change.RollbackError == "" {
+			t.Errorf("RolledBack = %v, RollbackError = %q; want an error", change.RolledBack, change.RollbackError)
+		}
+		if got := liveCorefile(t, client); got != failed {
+			t.Errorf("live Corefile = %q, want it untouched", got)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceAddresses returns the cluster IPs, external IPs and load balancer
+// ingress IPs of a service
+func ServiceAddresses(service *corev1.Service) []string {
+	var addresses []string
+	if service.Spec.ClusterIP != "" && service.Spec.ClusterIP != corev1.ClusterIPNone {
+		addresses = append(addresses, service.Spec.ClusterIP)
+	}
+	for _, ip := range service.Spec.ClusterIPs {
+		if ip != service.Spec.ClusterIP {
+			addresses = append(addresses, ip)
+		}
+	}
+	addresses = append(addresses, service.Spec.ExternalIPs...)
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+	}
+	return addresses
+}
+
+// CachedDNSAddresses returns the DNS addresses of a cluster from its informer
+// cache, or nil if the cache is not running. It never connects to the cluster.
+func (h *CoreDNSHandler) CachedDNSAddresses(clusterID string) []string {
+	service, ok := h.manager.PeekCachedService(clusterID)
+	if !ok {
+		return nil
+	}
+	return ServiceAddresses(service)
+}
+
+// FindClusterByDNSIP returns the registered cluster whose DNS service answers on ip.
+// The cluster with excludeID is skipped. Only clusters whose informer cache is
+// already running are considered: resolving an address never connects to
+// the other clusters. Their caches are started by the drift and shadow
+// monitors and by the dashboard.
+func (h *CoreDNSHandler) FindClusterByDNSIP(clusters []models.Cluster, ip, excludeID string) *models.Cluster {
+	for i := range clusters {
+		if clusters[i].ID == excludeID {
+			continue
+		}
+		for _, addr := range h.CachedDNSAddresses(clusters[i].ID) {
+			if addr == ip {
+				return &clusters[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CheckShadowing reports rules that forward a namespace or service which also
+// exists in the local cluster. For each finding the target cluster (resolved
+// by its DNS address among clusters) is checked as well.
+func (h *CoreDNSHandler) CheckShadowing(ctx context.Context, local *models.Cluster, rules []models.ForwardRule, clusters []models.Cluster) ([]models.ShadowFinding, error) {
+	findings := make([]models.ShadowFinding, 0)
+	if len(rules) == 0 {
+		return findings, nil
+	}
+
+	client, err := h.manager.GetClient(local)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve each distinct target IP only once
+	targets := make(map[string]*models.Cluster)
+
+	for _, rule := range rules {
+		exists, err := objectExists(ctx, client, rule)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		finding := models.ShadowFinding{
+			Rule: rule,
+			Kind: models.ShadowKindNamespace,
+			Name: rule.GetFullName(),
+		}
+		if rule.ServiceName != "" {
+			finding.Kind = models.ShadowKindService
+		}
+
+		target, resolved := targets[rule.TargetIP]
+		if !resolved {
+			target = h.FindClusterByDNSIP(clusters, rule.TargetIP, local.ID)
+			targets[rule.TargetIP] = target
+		}
+
+		if target == nil {
+			finding.Message = fmt.Sprintf("%s %s exists locally but is forwarded to %s (unknown cluster)",
+				finding.Kind, finding.Name, rule.TargetIP)
+			findings = append(findings, finding)
+			continue
+		}
+
+		finding.TargetClusterID = target.ID
+		finding.TargetClusterName = target.Name
+		finding.Message = fmt.Sprintf("%s %s exists locally but is forwarded to cluster %s",
+			finding.Kind, finding.Name, target.Name)
+
+		if targetClient, err := h.manager.GetClient(target); err == nil {
+			if targetExists, err := objectExists(ctx, targetClient, rule); err == nil {
+				finding.TargetExists = &targetExists
+				if !targetExists {
+					finding.Message += ", where it does not exist"
+				}
+			}
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings, nil
+}
+
+// objectExists checks whether the namespace or service a rule forwards exists in a cluster
+func objectExists(ctx context.Context, client kubernetes.Interface, rule models.ForwardRule) (bool, error) {
+	var err error
+	if rule.ServiceName != "" {
+		_, err = client.CoreV1().Services(rule.Namespace).Get(ctx, rule.ServiceName, metav1.GetOptions{})
+	} else {
+		_, err = client.CoreV1().Namespaces().Get(ctx, rule.Namespace, metav1.GetOptions{})
+	}
+
+	if err == nil {
+		return true, nil
+	}
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to look up %s: %w", rule.GetFullName(), err)
+}
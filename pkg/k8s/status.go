@@ -0,0 +1,163 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"coredns-multi-configuration/pkg/models"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxStatusEvents limits the events returned with the CoreDNS status
+const maxStatusEvents = 20
+
+// GetCoreDNSStatus returns the CoreDNS Deployments and DaemonSets, their pods
+// and the recent events of those pods
+func (h *CoreDNSHandler) GetCoreDNSStatus(ctx context.Context, cluster *models.Cluster) (*models.CoreDNSStatus, error) {
+	client, err := h.manager.GetClient(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.CoreDNSStatus{
+		Workloads: make([]models.WorkloadStatus, 0),
+		Pods:      make([]models.PodStatus, 0),
+		Events:    make([]models.PodEvent, 0),
+	}
+
+	opts := metav1.ListOptions{LabelSelector: CoreDNSLabelSelector}
+
+	deployments, err := client.AppsV1().Deployments(CoreDNSNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list coredns deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		status.Workloads = append(status.Workloads, models.WorkloadStatus{
+			Kind:      "Deployment",
+			Name:      d.Name,
+			Desired:   desired,
+			Ready:     d.Status.ReadyReplicas,
+			Updated:   d.Status.UpdatedReplicas,
+			Available: d.Status.AvailableReplicas,
+		})
+	}
+
+	daemonSets, err := client.AppsV1().DaemonSets(CoreDNSNamespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list coredns daemonsets: %w", err)
+	}
+	for _, ds := range daemonSets.Items {
+		status.Workloads = append(status.Workloads, models.WorkloadStatus{
+			Kind:      "DaemonSet",
+			Name:      ds.Name,
+			Desired:   ds.Status.DesiredNumberScheduled,
+			Ready:     ds.Status.NumberReady,
+			Updated:   ds.Status.UpdatedNumberScheduled,
+			Available: ds.Status.NumberAvailable,
+		})
+	}
+
+	pods, err := h.GetDeployment(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	podNames := make(map[string]bool, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		podNames[pod.Name] = true
+
+		ps := models.PodStatus{PodHealth: podHealth(pod)}
+		if c := coreDNSContainer(pod); c != nil {
+			ps.Image = c.Image
+			ps.Version = ImageVersion(c.Image)
+		}
+		status.Pods = append(status.Pods, ps)
+	}
+	sort.Slice(status.Pods, func(i, j int) bool { return status.Pods[i].Name < status.Pods[j].Name })
+
+	events, err := podEvents(ctx, client, podNames)
+	if err != nil {
+		return nil, err
+	}
+	status.Events = events
+
+	return status, nil
+}
+
+// podEvents returns the most recent events about the given pods, newest first
+func podEvents(ctx context.Context, client kubernetes.Interface, podNames map[string]bool) ([]models.PodEvent, error) {
+	list, err := client.CoreV1().Events(CoreDNSNamespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "involvedObject.kind=Pod",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list coredns events: %w", err)
+	}
+
+	events := make([]models.PodEvent, 0)
+	for _, e := range list.Items {
+		if !podNames[e.InvolvedObject.Name] {
+			continue
+		}
+
+		lastSeen := e.LastTimestamp.Time
+		if lastSeen.IsZero() {
+			lastSeen = e.EventTime.Time
+		}
+		count := e.Count
+		if count == 0 && e.Series != nil {
+			count = e.Series.Count
+		}
+
+		events = append(events, models.PodEvent{
+			Pod:      e.InvolvedObject.Name,
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    count,
+			LastSeen: lastSeen,
+		})
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].LastSeen.After(events[j].LastSeen) })
+	if len(events) > maxStatusEvents {
+		events = events[:maxStatusEvents]
+	}
+	return events, nil
+}
+
+// coreDNSContainer returns the CoreDNS container of a pod, falling back to
+// the first container
+func coreDNSContainer(pod *corev1.Pod) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == "coredns" {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return &pod.Spec.Containers[0]
+	}
+	return nil
+}
+
+// ImageVersion extracts the version from an image tag, e.g. 1.11.1 from
+// registry.k8s.io/coredns/coredns:v1.11.1. It returns "" for untagged images.
+func ImageVersion(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i+1:], "/") {
+		return "" // no tag, the colon belongs to a registry port
+	}
+	return strings.TrimPrefix(image[i+1:], "v")
+}
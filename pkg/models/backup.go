@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// CorefileBackup is a copy of the Corefile saved in the cluster before a change
+type CorefileBackup struct {
+	Name      string    `json:"name"` // ConfigMap name, e.g. coredns-backup-3
+	CreatedAt time.Time `json:"created_at"`
+	User      string    `json:"user"`             // who made the change that replaced this Corefile
+	Reason    string    `json:"reason,omitempty"` // e.g. rollback
+	Corefile  string    `json:"corefile"`
+}
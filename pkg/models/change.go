@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Corefile change states
+const (
+	ChangePending = "pending"
+	ChangeHealthy = "healthy"
+	ChangeFailed  = "failed"
+)
+
+// CorefileChange tracks the rollout of one Corefile write to the CoreDNS pods
+type CorefileChange struct {
+	ID         string      `json:"id"`
+	ClusterID  string      `json:"cluster_id"`
+	State      string      `json:"state"` // pending, healthy or failed
+	Message    string      `json:"message,omitempty"`
+	Reload     bool        `json:"reload"`    // the reload plugin is enabled
+	Reloaded   int         `json:"reloaded"`  // pods that logged a completed reload
+	Restarted  bool        `json:"restarted"` // CoreDNS was restarted to apply the change
+	Deadline   time.Time   `json:"deadline"`  // end of the verification window
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Pods       []PodHealth `json:"pods"`
+
+	// Failure details
+	FailedPod     string `json:"failed_pod,omitempty"`
+	LogExcerpt    string `json:"log_excerpt,omitempty"`
+	RolledBack    bool   `json:"rolled_back"` // the previous Corefile was restored
+	RollbackError string `json:"rollback_error,omitempty"`
+}
+
+// PodHealth is the observed state of a CoreDNS pod during a rollout
+type PodHealth struct {
+	Name     string `json:"name"`
+	Node     string `json:"node,omitempty"`
+	Phase    string `json:"phase"`
+	Ready    bool   `json:"ready"`
+	Restarts int32  `json:"restarts"`
+	Reason   string `json:"reason,omitempty"` // e.g., CrashLoopBackOff
+	Reloaded bool   `json:"reloaded"`
+}
+
+// RestartStatus is the progress of a rolling restart of the CoreDNS Deployment
+type RestartStatus struct {
+	Deployment  string `json:"deployment"`
+	RestartedAt string `json:"restarted_at,omitempty"` // time of the last restart request
+	Desired     int32  `json:"desired"`
+	Updated     int32  `json:"updated"`
+	Ready       int32  `json:"ready"`
+	Available   int32  `json:"available"`
+	Done        bool   `json:"done"`
+	Failed      bool   `json:"failed"`
+	Message     string `json:"message"`
+}
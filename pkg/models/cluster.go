@@ -4,14 +4,97 @@ import "time"
 
 // Cluster represents a Kubernetes cluster configuration
 type Cluster struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	Kubeconfig string    `json:"kubeconfig"` // base64 encoded
-	CreatedAt  time.Time `json:"created_at"`
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Group      string            `json:"group,omitempty"`  // e.g., "staging"
+	Labels     map[string]string `json:"labels,omitempty"` // e.g., env=staging, region=eu-west
+	Kubeconfig string            `json:"kubeconfig"`       // base64 encoded
+	Source     string            `json:"source,omitempty"` // credential source, empty for kubeconfig
+	CreatedAt  time.Time         `json:"created_at"`
+
+	// Restart CoreDNS after each Corefile write when the reload plugin is not enabled
+	AutoRestart bool `json:"auto_restart"`
+	// Mirror managed forward rules into the NodeLocal DNSCache Corefile
+	MirrorNodeLocal bool `json:"mirror_node_local"`
+	// RBAC preflight result, nil if the credentials were never checked
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+// Cluster credential sources
+const (
+	SourceKubeconfig = ""           // base64 kubeconfig stored with the cluster
+	SourceInCluster  = "in-cluster" // ServiceAccount of the manager's own pod
+)
+
+// LocalClusterID is the ID of the built-in cluster registered in in-cluster mode
+const LocalClusterID = "local"
+
+// GroupLabel is the selector key that matches a cluster's group
+const GroupLabel = "group"
+
+// SelectorLabels returns the labels used for selector matching:
+// the cluster labels plus its group under the "group" key
+func (c *Cluster) SelectorLabels() map[string]string {
+	result := make(map[string]string, len(c.Labels)+1)
+	for k, v := range c.Labels {
+		result[k] = v
+	}
+	if c.Group != "" {
+		if _, ok := result[GroupLabel]; !ok {
+			result[GroupLabel] = c.Group
+		}
+	}
+	return result
 }
 
 // ClusterStatus represents the connection status of a cluster
 type ClusterStatus struct {
-	Connected bool   `json:"connected"`
-	Error     string `json:"error,omitempty"`
+	Connected   bool       `json:"connected"`
+	Error       string     `json:"error,omitempty"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"` // nil until the first check
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   *time.Time `json:"last_error,omitempty"`
+	LatencyMs   int64      `json:"latency_ms"`
+	Version     string     `json:"version,omitempty"` // API server version, e.g. v1.30.2
+}
+
+// Capabilities is the result of the RBAC preflight of a cluster's credentials:
+// the CoreDNS operations they are allowed to perform
+type Capabilities struct {
+	ReadCorefile   bool      `json:"read_corefile"`   // get, list and watch the CoreDNS ConfigMap
+	UpdateCorefile bool      `json:"update_corefile"` // update the CoreDNS ConfigMap
+	ReadService    bool      `json:"read_service"`    // get, list and watch the DNS Service
+	ListPods       bool      `json:"list_pods"`       // list pods in kube-system
+	ReadLogs       bool      `json:"read_logs"`       // list pods and get pods/log in kube-system
+	Restart        bool      `json:"restart"`         // get and patch the CoreDNS Deployment
+	ReadStatus     bool      `json:"read_status"`     // list deployments, daemonsets, pods and events
+	NodeLocal      bool      `json:"node_local"`      // get and update the node-local-dns ConfigMap
+	RecordEvents   bool      `json:"record_events"`   // create events for Corefile changes
+	Backup         bool      `json:"backup"`          // create, get and update backup ConfigMaps
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
+// Missing returns the names of the operations that are not allowed
+func (c *Capabilities) Missing() []string {
+	var missing []string
+	for _, check := range []struct {
+		name    string
+		allowed bool
+	}{
+		{"read_corefile", c.ReadCorefile},
+		{"update_corefile", c.UpdateCorefile},
+		{"read_service", c.ReadService},
+		{"list_pods", c.ListPods},
+		{"read_logs", c.ReadLogs},
+		{"restart", c.Restart},
+		{"read_status", c.ReadStatus},
+		{"node_local", c.NodeLocal},
+		{"record_events", c.RecordEvents},
+		{"backup", c.Backup},
+	} {
+		if !check.allowed {
+			missing = append(missing, check.name)
+		}
+	}
+	return missing
 }
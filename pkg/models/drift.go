@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Drift item types
+const (
+	DriftMissing   = "missing"   // desired rule is absent from the live Corefile
+	DriftChanged   = "changed"   // live rule forwards to a different target
+	DriftUnmanaged = "unmanaged" // live rule is not part of the desired state
+)
+
+// DriftItem describes a single difference between desired and live rules
+type DriftItem struct {
+	Type    string       `json:"type"`
+	Desired *ForwardRule `json:"desired,omitempty"`
+	Live    *ForwardRule `json:"live,omitempty"`
+	Message string       `json:"message"`
+}
+
+// DriftReport is the result of comparing a cluster's desired rules with its live Corefile
+type DriftReport struct {
+	ClusterID   string      `json:"cluster_id"`
+	ClusterName string      `json:"cluster_name"`
+	CheckedAt   time.Time   `json:"checked_at"`
+	InSync      bool        `json:"in_sync"`
+	Items       []DriftItem `json:"items"`
+	Adopted     bool        `json:"adopted,omitempty"`    // desired state was initialized from live rules
+	Remediated  bool        `json:"remediated,omitempty"` // missing and changed rules were restored
+	Error       string      `json:"error,omitempty"`
+}
+
+// DiffRules compares desired rules with live rules. Rules are matched by
+// their domain block, so "payments:53" and "payments.svc.cluster.local:53"
+// are different rules.
+func DiffRules(desired, live []ForwardRule) []DriftItem {
+	items := make([]DriftItem, 0)
+
+	liveByBlock := make(map[string]ForwardRule, len(live))
+	for _, r := range live {
+		liveByBlock[r.GetDomainBlock()] = r
+	}
+
+	desiredBlocks := make(map[string]bool, len(desired))
+	for i := range desired {
+		d := desired[i]
+		block := d.GetDomainBlock()
+		desiredBlocks[block] = true
+
+		l, ok := liveByBlock[block]
+		if !ok {
+			items = append(items, DriftItem{
+				Type:    DriftMissing,
+				Desired: &d,
+				Message: fmt.Sprintf("%s -> %s is missing from the Corefile", block, d.TargetIP),
+			})
+			continue
+		}
+		if l.TargetIP != d.TargetIP {
+			items = append(items, DriftItem{
+				Type:    DriftChanged,
+				Desired: &d,
+				Live:    &l,
+				Message: fmt.Sprintf("%s forwards to %s, expected %s", block, l.TargetIP, d.TargetIP),
+			})
+		}
+	}
+
+	for i := range live {
+		l := live[i]
+		if !desiredBlocks[l.GetDomainBlock()] {
+			items = append(items, DriftItem{
+				Type:    DriftUnmanaged,
+				Live:    &l,
+				Message: fmt.Sprintf("%s -> %s is not in the desired state", l.GetDomainBlock(), l.TargetIP),
+			})
+		}
+	}
+
+	return items
+}
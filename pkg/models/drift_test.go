@@ -0,0 +1,123 @@
+package models
+
+import "testing"
+
+func TestDiffRules(t *testing.T) {
+	payments := ForwardRule{Namespace: "payments", TargetIP: "10.0.0.10"}
+	paymentsMoved := ForwardRule{Namespace: "payments", TargetIP: "10.1.0.10"}
+	paymentsFQDN := ForwardRule{Namespace: "payments", TargetIP: "10.0.0.10", IsFullFQDN: true}
+	mysql := ForwardRule{Namespace: "payments", ServiceName: "mysql", TargetIP: "10.0.0.10"}
+	orders := ForwardRule{Namespace: "orders", TargetIP: "10.2.0.10"}
+
+	// item is the type and domain block of a drift item
+	type item struct {
+		typ   string
+		block string
+	}
+
+	tests := []struct {
+		name    string
+		desired []ForwardRule
+		live    []ForwardRule
+		want    []item
+	}{
+		{
+			name: "both empty",
+		},
+		{
+			name:    "in sync",
+			desired: []ForwardRule{payments, orders},
+			live:    []ForwardRule{orders, payments},
+		},
+		{
+			name:    "missing",
+			desired: []ForwardRule{payments, orders},
+			live:    []ForwardRule{payments},
+			want:    []item{{DriftMissing, "orders:53"}},
+		},
+		{
+			name:    "changed target",
+			desired: []ForwardRule{payments},
+			live:    []ForwardRule{paymentsMoved},
+			want:    []item{{DriftChanged, "payments:53"}},
+		},
+		{
+			name:    "unmanaged",
+			desired: []ForwardRule{payments},
+			live:    []ForwardRule{payments, orders},
+			want:    []item{{DriftUnmanaged, "orders:53"}},
+		},
+		{
+			name:    "short and FQDN rules are different rules",
+			desired: []ForwardRule{payments},
+			live:    []ForwardRule{paymentsFQDN},
+			want: []item{
+				{DriftMissing, "payments:53"},
+				{DriftUnmanaged, "payments.svc.cluster.local:53"},
+			},
+		},
+		{
+			name:    "service and namespace rules are different rules",
+			desired: []ForwardRule{mysql},
+			live:    []ForwardRule{payments},
+			want: []item{
+				{DriftMissing, "mysql.payments:53"},
+				{DriftUnmanaged, "payments:53"},
+			},
+		},
+		{
+			name: "desired empty",
+			live: []ForwardRule{payments},
+			want: []item{{DriftUnmanaged, "payments:53"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffRules(tt.desired, tt.live)
+			if got == nil {
+				t.Fatal("DiffRules() returned nil, want an empty slice")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("DiffRules() = %+v, want %d items", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Type != w.typ {
+					t.Errorf("item %d type = %s, want %s", i, g.Type, w.typ)
+				}
+
+				rule := g.Desired
+				if w.typ == DriftUnmanaged {
+					rule = g.Live
+				}
+				if rule == nil || rule.GetDomainBlock() != w.block {
+					t.Errorf("item %d = %+v, want block %s", i, g, w.block)
+				}
+				if (g.Desired != nil) != (w.typ != DriftUnmanaged) {
+					t.Errorf("item %d desired = %+v, unexpected for %s", i, g.Desired, w.typ)
+				}
+				if (g.Live != nil) != (w.typ != DriftMissing) {
+					t.Errorf("item %d live = %+v, unexpected for %s", i, g.Live, w.typ)
+				}
+				if g.Message == "" {
+					t.Errorf("item %d has no message", i)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffRulesChangedKeepsBothTargets(t *testing.T) {
+	desired := []ForwardRule{{Namespace: "payments", TargetIP: "10.0.0.10"}}
+	live := []ForwardRule{{Namespace: "payments", TargetIP: "10.1.0.10"}}
+
+	got := DiffRules(desired, live)
+	if len(got) != 1 {
+		t.Fatalf("DiffRules() = %+v, want one item", got)
+	}
+	if got[0].Desired.TargetIP != "10.0.0.10" || got[0].Live.TargetIP != "10.1.0.10" {
+		t.Errorf("DiffRules() = desired %s, live %s; want 10.0.0.10, 10.1.0.10",
+			got[0].Desired.TargetIP, got[0].Live.TargetIP)
+	}
+}
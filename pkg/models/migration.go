@@ -0,0 +1,26 @@
+package models
+
+// MigrationReport checks a cluster's Corefile against the CoreDNS version
+// compatibility table and previews its migration to a target version
+type MigrationReport struct {
+	CurrentVersion string            `json:"current_version"`          // lowest CoreDNS version running
+	PodVersions    []string          `json:"pod_versions"`             // distinct versions seen across the pods
+	TargetVersion  string            `json:"target_version,omitempty"` // defaults to the newest known version
+	Versions       []string          `json:"versions"`                 // versions the Corefile can be migrated to
+	Supported      bool              `json:"supported"`                // the current version is in the table
+	Notices        []MigrationNotice `json:"notices"`
+	Corefile       string            `json:"corefile"`
+	Migrated       string            `json:"migrated,omitempty"` // preview of the migrated Corefile
+	Changed        bool              `json:"changed"`            // the migration rewrites plugins or options
+	Message        string            `json:"message,omitempty"`
+}
+
+// MigrationNotice is a deprecated, removed or unsupported plugin or option
+type MigrationNotice struct {
+	Plugin     string `json:"plugin"`
+	Option     string `json:"option,omitempty"`
+	Severity   string `json:"severity"` // deprecated, ignored, removed, newdefault or unsupported
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	Version    string `json:"version"` // version in which the status applies
+	Message    string `json:"message"`
+}
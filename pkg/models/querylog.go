@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Query log session states
+const (
+	QueryLogActive   = "active"
+	QueryLogFinished = "finished"
+	QueryLogFailed   = "failed"
+)
+
+// QueryLogSession is a temporary debug session that enables the log plugin
+// in one forward rule's server block and captures the matching log lines
+type QueryLogSession struct {
+	ID        string     `json:"id"`
+	ClusterID string     `json:"cluster_id"`
+	Rule      string     `json:"rule"` // domain block, e.g. payments:53
+	State     string     `json:"state"`
+	StartedAt time.Time  `json:"started_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	ChangeID  string     `json:"change_id"` // Corefile change that enabled the log directive
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Lines     []LogLine  `json:"lines"`
+	Truncated bool       `json:"truncated,omitempty"` // older lines were dropped
+	Error     string     `json:"error,omitempty"`
+}
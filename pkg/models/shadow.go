@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Shadow finding kinds
+const (
+	ShadowKindNamespace = "namespace"
+	ShadowKindService   = "service"
+)
+
+// ShadowFinding describes a forward rule that hides a namespace or service
+// which also exists in the local cluster
+type ShadowFinding struct {
+	Rule              ForwardRule `json:"rule"`
+	Kind              string      `json:"kind"` // "namespace" or "service"
+	Name              string      `json:"name"` // e.g., "payments" or "mysql.payments"
+	TargetClusterID   string      `json:"target_cluster_id,omitempty"`
+	TargetClusterName string      `json:"target_cluster_name,omitempty"`
+	TargetExists      *bool       `json:"target_exists,omitempty"` // nil if the target cluster is unknown
+	Message           string      `json:"message"`
+}
+
+// ShadowReport is the result of a shadowing audit for one cluster
+type ShadowReport struct {
+	ClusterID   string          `json:"cluster_id"`
+	ClusterName string          `json:"cluster_name"`
+	CheckedAt   time.Time       `json:"checked_at"`
+	Findings    []ShadowFinding `json:"findings"`
+	Error       string          `json:"error,omitempty"`
+}
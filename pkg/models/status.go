@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// CoreDNSStatus is the observed state of the CoreDNS workloads of a cluster
+type CoreDNSStatus struct {
+	Workloads []WorkloadStatus `json:"workloads"`
+	Pods      []PodStatus      `json:"pods"`
+	Events    []PodEvent       `json:"events"`
+}
+
+// WorkloadStatus holds the replica counts of a CoreDNS Deployment or DaemonSet
+type WorkloadStatus struct {
+	Kind      string `json:"kind"` // Deployment or DaemonSet
+	Name      string `json:"name"`
+	Desired   int32  `json:"desired"`
+	Ready     int32  `json:"ready"`
+	Updated   int32  `json:"updated"`
+	Available int32  `json:"available"`
+}
+
+// PodStatus is the state of a CoreDNS pod with its image and version
+type PodStatus struct {
+	PodHealth
+	Image   string `json:"image"`
+	Version string `json:"version,omitempty"` // CoreDNS version from the image tag
+}
+
+// PodEvent is a Kubernetes Event about a CoreDNS pod
+type PodEvent struct {
+	Pod      string    `json:"pod"`
+	Type     string    `json:"type"` // Normal or Warning
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+	Count    int32     `json:"count"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// LogLine is a line of a CoreDNS pod's log
+type LogLine struct {
+	Pod  string `json:"pod"`
+	Line string `json:"line"`
+}
@@ -0,0 +1,198 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+// DriftDetector periodically compares each cluster's desired forward rules
+// with the rules parsed from its live Corefile
+type DriftDetector struct {
+	store         store.Store
+	coreDNS       *k8s.CoreDNSHandler
+	interval      time.Duration
+	autoRemediate bool
+
+	mu      sync.RWMutex
+	reports map[string]models.DriftReport
+	pending map[string]*time.Timer // event-triggered checks by cluster ID
+}
+
+// driftEventDelay coalesces bursts of ConfigMap events into one check
+const driftEventDelay = 5 * time.Second
+
+// NewDriftDetector creates a new drift detector
+func NewDriftDetector(store store.Store, coreDNS *k8s.CoreDNSHandler, interval time.Duration, autoRemediate bool) *DriftDetector {
+	return &DriftDetector{
+		store:         store,
+		coreDNS:       coreDNS,
+		interval:      interval,
+		autoRemediate: autoRemediate,
+		reports:       make(map[string]models.DriftReport),
+		pending:       make(map[string]*time.Timer),
+	}
+}
+
+// Start runs the periodic drift check until ctx is cancelled
+func (d *DriftDetector) Start(ctx context.Context) {
+	if d.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		for {
+			d.CheckAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Watch re-checks a cluster shortly after its CoreDNS ConfigMap changes, so
+// edits made outside the manager are reported without waiting for the next
+// interval. Event-triggered checks never remediate.
+func (d *DriftDetector) Watch(ctx context.Context, manager *k8s.Manager) {
+	unsubscribe := manager.Subscribe(func(event k8s.CacheEvent) {
+		if event.Kind != k8s.KindConfigMap {
+			return
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if timer, ok := d.pending[event.ClusterID]; ok {
+			timer.Reset(driftEventDelay)
+			return
+		}
+		d.pending[event.ClusterID] = time.AfterFunc(driftEventDelay, func() {
+			d.mu.Lock()
+			delete(d.pending, event.ClusterID)
+			d.mu.Unlock()
+
+			if cluster, found := d.store.GetCluster(event.ClusterID); found {
+				d.Check(ctx, cluster, false)
+			}
+		})
+	})
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+}
+
+// CheckAll checks every registered cluster
+func (d *DriftDetector) CheckAll(ctx context.Context) {
+	for _, cluster := range d.store.GetClusters() {
+		d.Check(ctx, &cluster, d.autoRemediate)
+	}
+}
+
+// Check compares desired and live rules of a cluster and caches the report.
+// When remediate is true, missing and changed rules are written back;
+// unmanaged rules are only reported.
+func (d *DriftDetector) Check(ctx context.Context, cluster *models.Cluster, remediate bool) models.DriftReport {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	report := models.DriftReport{
+		ClusterID:   cluster.ID,
+		ClusterName: cluster.Name,
+		CheckedAt:   time.Now(),
+		Items:       make([]models.DriftItem, 0),
+	}
+	defer func() {
+		d.mu.Lock()
+		d.reports[cluster.ID] = report
+		d.mu.Unlock()
+	}()
+
+	info, err := d.coreDNS.GetCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	desired, ok := d.store.GetDesiredRules(cluster.ID)
+	if !ok {
+		// No intent recorded yet: adopt the live rules as the desired state
+		if err := d.store.SetDesiredRules(cluster.ID, info.ForwardRules); err != nil {
+			report.Error = "failed to save desired rules: " + err.Error()
+			return report
+		}
+		report.Adopted = true
+		report.InSync = true
+		return report
+	}
+
+	report.Items = models.DiffRules(desired, info.ForwardRules)
+	report.InSync = len(report.Items) == 0
+	if report.InSync {
+		return report
+	}
+
+	log.Printf("Drift detected on cluster %s: %d differences", cluster.Name, len(report.Items))
+
+	if remediate {
+		var remove, add []models.ForwardRule
+		for _, item := range report.Items {
+			switch item.Type {
+			case models.DriftMissing:
+				add = append(add, *item.Desired)
+			case models.DriftChanged:
+				remove = append(remove, *item.Live)
+				add = append(add, *item.Desired)
+			}
+		}
+		if len(add) == 0 {
+			return report
+		}
+
+		if _, err := d.coreDNS.ApplyRuleChanges(k8s.WithReason(ctx, "remediate drift"), cluster, remove, add); err != nil {
+			report.Error = "remediation failed: " + err.Error()
+			return report
+		}
+		report.Remediated = true
+		log.Printf("Drift remediated on cluster %s: restored %d rules", cluster.Name, len(add))
+	}
+
+	return report
+}
+
+// Reports returns the cached reports of all clusters that still exist
+func (d *DriftDetector) Reports() []models.DriftReport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	clusters := d.store.GetClusters()
+	result := make([]models.DriftReport, 0, len(clusters))
+	for _, cluster := range clusters {
+		if report, ok := d.reports[cluster.ID]; ok {
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+// Forget drops the cached report of a deleted cluster
+func (d *DriftDetector) Forget(clusterID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.reports, clusterID)
+	if timer, ok := d.pending[clusterID]; ok {
+		timer.Stop()
+		delete(d.pending, clusterID)
+	}
+}
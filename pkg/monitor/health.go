@@ -0,0 +1,131 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+// HealthMonitor periodically checks the connectivity of every cluster
+// concurrently and caches the results
+type HealthMonitor struct {
+	store    store.Store
+	manager  *k8s.Manager
+	interval time.Duration
+	timeout  time.Duration
+
+	mu       sync.RWMutex
+	statuses map[string]models.ClusterStatus
+}
+
+// NewHealthMonitor creates a new cluster health monitor
+func NewHealthMonitor(store store.Store, manager *k8s.Manager, interval, timeout time.Duration) *HealthMonitor {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &HealthMonitor{
+		store:    store,
+		manager:  manager,
+		interval: interval,
+		timeout:  timeout,
+		statuses: make(map[string]models.ClusterStatus),
+	}
+}
+
+// Start runs the periodic health check until ctx is cancelled. With polling
+// disabled, the stored clusters are still checked once.
+func (m *HealthMonitor) Start(ctx context.Context) {
+	if m.interval <= 0 {
+		go m.CheckAll(ctx)
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			m.CheckAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckAll checks every registered cluster concurrently
+func (m *HealthMonitor) CheckAll(ctx context.Context) {
+	clusters := m.store.GetClusters()
+
+	var wg sync.WaitGroup
+	for i := range clusters {
+		wg.Add(1)
+		go func(cluster *models.Cluster) {
+			defer wg.Done()
+			m.Check(ctx, cluster)
+		}(&clusters[i])
+	}
+	wg.Wait()
+}
+
+// Schedule checks a cluster in the background right away, e.g. after it was
+// added or its credentials changed, instead of waiting for the next poll
+func (m *HealthMonitor) Schedule(cluster models.Cluster) {
+	go m.Check(context.Background(), &cluster)
+}
+
+// Check tests the connection to a cluster and caches the status
+func (m *HealthMonitor) Check(ctx context.Context, cluster *models.Cluster) models.ClusterStatus {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	start := time.Now()
+	info, err := m.manager.ServerVersion(ctx, cluster)
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.statuses[cluster.ID]
+	status.CheckedAt = &now
+	status.LatencyMs = now.Sub(start).Milliseconds()
+	if err != nil {
+		status.Connected = false
+		status.Error = err.Error()
+		status.LastError = &now
+	} else {
+		status.Connected = true
+		status.Error = ""
+		status.LastSuccess = &now
+		status.Version = info.GitVersion
+	}
+
+	// The cluster may have been removed while it was checked
+	if _, found := m.store.GetCluster(cluster.ID); found {
+		m.statuses[cluster.ID] = status
+	}
+	return status
+}
+
+// Status returns the cached status of a cluster. The second return value
+// is false if the cluster has not been checked yet.
+func (m *HealthMonitor) Status(clusterID string) (models.ClusterStatus, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	status, ok := m.statuses[clusterID]
+	return status, ok
+}
+
+// Forget drops the cached status of a removed cluster
+func (m *HealthMonitor) Forget(clusterID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.statuses, clusterID)
+}
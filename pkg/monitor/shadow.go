@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+// ShadowAuditor periodically checks every cluster's forward rules for
+// namespaces and services that also exist locally
+type ShadowAuditor struct {
+	store    store.Store
+	coreDNS  *k8s.CoreDNSHandler
+	interval time.Duration
+
+	mu      sync.RWMutex
+	reports map[string]models.ShadowReport
+}
+
+// NewShadowAuditor creates a new shadowing auditor
+func NewShadowAuditor(store store.Store, coreDNS *k8s.CoreDNSHandler, interval time.Duration) *ShadowAuditor {
+	return &ShadowAuditor{
+		store:    store,
+		coreDNS:  coreDNS,
+		interval: interval,
+		reports:  make(map[string]models.ShadowReport),
+	}
+}
+
+// Start runs the periodic audit until ctx is cancelled
+func (a *ShadowAuditor) Start(ctx context.Context) {
+	if a.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		for {
+			a.AuditAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// AuditAll audits every registered cluster
+func (a *ShadowAuditor) AuditAll(ctx context.Context) {
+	for _, cluster := range a.store.GetClusters() {
+		report := a.AuditCluster(ctx, &cluster)
+		if len(report.Findings) > 0 {
+			log.Printf("Shadow audit: cluster %s has %d shadowed names", cluster.Name, len(report.Findings))
+		}
+	}
+}
+
+// AuditCluster audits a single cluster and caches the report
+func (a *ShadowAuditor) AuditCluster(ctx context.Context, cluster *models.Cluster) models.ShadowReport {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	report := models.ShadowReport{
+		ClusterID:   cluster.ID,
+		ClusterName: cluster.Name,
+		CheckedAt:   time.Now(),
+		Findings:    make([]models.ShadowFinding, 0),
+	}
+
+	info, err := a.coreDNS.GetCoreDNSInfo(ctx, cluster)
+	if err != nil {
+		report.Error = err.Error()
+	} else {
+		findings, err := a.coreDNS.CheckShadowing(ctx, cluster, info.ForwardRules, a.store.GetClusters())
+		if err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Findings = findings
+		}
+	}
+
+	a.mu.Lock()
+	a.reports[cluster.ID] = report
+	a.mu.Unlock()
+
+	return report
+}
+
+// Reports returns the cached reports of all clusters that still exist
+func (a *ShadowAuditor) Reports() []models.ShadowReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	clusters := a.store.GetClusters()
+	result := make([]models.ShadowReport, 0, len(clusters))
+	for _, cluster := range clusters {
+		if report, ok := a.reports[cluster.ID]; ok {
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+// Forget drops the cached report of a deleted cluster
+func (a *ShadowAuditor) Forget(clusterID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.reports, clusterID)
+}
@@ -0,0 +1,388 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltFile is the database file of the bolt backend in the data directory
+const BoltFile = "coredns-manager.db"
+
+// Buckets of the bolt backend, both keyed by cluster ID
+var (
+	clustersBucket = []byte("clusters") // JSON encoded storedCluster
+	rulesBucket    = []byte("rules")    // JSON encoded desired forward rules
+)
+
+// BoltStore keeps data in an embedded bbolt database, writing each change
+// in its own transaction
+type BoltStore struct {
+	db   *bolt.DB
+	keys *Keyring // encrypts kubeconfigs at rest, nil stores them in plaintext
+}
+
+// NewBoltStore opens the bolt database in dataDir. An empty database imports
+// clusters.json and rules.json of the JSON backend, which are then deleted.
+func NewBoltStore(dataDir string, keys *Keyring) (*BoltStore, error) {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(dataDir, BoltFile), 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{clustersBucket, rulesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	s := &BoltStore{db: db, keys: keys}
+	if err := s.importJSON(dataDir); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.reencrypt(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// importJSON copies the data of the JSON backend into an empty database. The
+// files are deleted afterwards: clusters.json may hold plaintext kubeconfigs,
+// and a copy left on disk would defeat encryption in the database.
+func (s *BoltStore) importJSON(dataDir string) error {
+	clustersFile := filepath.Join(dataDir, "clusters.json")
+	rulesFile := filepath.Join(dataDir, "rules.json")
+
+	var stored []storedCluster
+	if err := readJSON(clustersFile, &stored); err != nil {
+		return fmt.Errorf("failed to read %s: %w", clustersFile, err)
+	}
+	rules := make(map[string][]models.ForwardRule)
+	if err := readJSON(rulesFile, &rules); err != nil {
+		return fmt.Errorf("failed to read %s: %w", rulesFile, err)
+	}
+	if len(stored) == 0 && len(rules) == 0 {
+		return nil
+	}
+
+	imported := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		clusters, ruleSets := tx.Bucket(clustersBucket), tx.Bucket(rulesBucket)
+		if k, _ := clusters.Cursor().First(); k != nil {
+			return nil
+		}
+		if k, _ := ruleSets.Cursor().First(); k != nil {
+			return nil
+		}
+
+		for _, sc := range stored {
+			cluster, _, err := s.keys.decryptCluster(sc)
+			if err != nil {
+				return err
+			}
+			if err := s.putCluster(tx, cluster); err != nil {
+				return err
+			}
+		}
+		for id, r := range rules {
+			if err := putJSON(ruleSets, id, r); err != nil {
+				return err
+			}
+		}
+		imported = true
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to import JSON data: %w", err)
+	}
+	if !imported {
+		log.Printf("Database is not empty, ignoring %s and %s", clustersFile, rulesFile)
+		return nil
+	}
+
+	for _, path := range []string{clustersFile, rulesFile} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+	log.Printf("Imported %d clusters and the desired rules of %d clusters from JSON files", len(stored), len(rules))
+	return nil
+}
+
+// reencrypt rewrites clusters stored in plaintext or sealed with a previous master key
+func (s *BoltStore) reencrypt() error {
+	rewrite := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var stale []models.Cluster
+		err := tx.Bucket(clustersBucket).ForEach(func(_, v []byte) error {
+			cluster, ok, err := s.decode(v)
+			if err != nil {
+				return err
+			}
+			if ok {
+				stale = append(stale, cluster)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, cluster := range stale {
+			if err := s.putCluster(tx, cluster); err != nil {
+				return err
+			}
+		}
+		rewrite = len(stale)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to re-encrypt clusters: %w", err)
+	}
+	if rewrite > 0 {
+		log.Printf("Encrypted the credentials of %d stored clusters with the primary key", rewrite)
+	}
+	return nil
+}
+
+// decode decrypts a stored cluster, reporting whether it should be rewritten
+func (s *BoltStore) decode(data []byte) (models.Cluster, bool, error) {
+	var sc storedCluster
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return models.Cluster{}, false, fmt.Errorf("failed to decode cluster: %w", err)
+	}
+	return s.keys.decryptCluster(sc)
+}
+
+// putCluster writes a cluster in its on-disk form
+func (s *BoltStore) putCluster(tx *bolt.Tx, cluster models.Cluster) error {
+	sc, err := s.keys.encryptCluster(cluster)
+	if err != nil {
+		return err
+	}
+	return putJSON(tx.Bucket(clustersBucket), cluster.ID, sc)
+}
+
+// putJSON stores v as JSON under key
+func putJSON(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// getRules returns the desired rules of a cluster within a transaction
+func getRules(tx *bolt.Tx, clusterID string) ([]models.ForwardRule, bool, error) {
+	data := tx.Bucket(rulesBucket).Get([]byte(clusterID))
+	if data == nil {
+		return nil, false, nil
+	}
+	var rules []models.ForwardRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, false, fmt.Errorf("failed to decode rules of cluster %s: %w", clusterID, err)
+	}
+	return rules, true, nil
+}
+
+// GetClusters returns all clusters ordered by creation time
+func (s *BoltStore) GetClusters() []models.Cluster {
+	result := make([]models.Cluster, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(clustersBucket).ForEach(func(k, v []byte) error {
+			cluster, _, err := s.decode(v)
+			if err != nil {
+				log.Printf("Skipping stored cluster %s: %v", k, err)
+				return nil
+			}
+			result = append(result, cluster)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to read clusters: %v", err)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// GetCluster returns a cluster by ID
+func (s *BoltStore) GetCluster(id string) (*models.Cluster, bool) {
+	var cluster *models.Cluster
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(clustersBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		c, _, err := s.decode(data)
+		if err != nil {
+			return err
+		}
+		cluster = &c
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to read cluster %s: %v", id, err)
+		return nil, false
+	}
+	return cluster, cluster != nil
+}
+
+// SelectClusters returns the clusters matching a label selector
+func (s *BoltStore) SelectClusters(selector string) ([]models.Cluster, error) {
+	return selectClusters(s.GetClusters(), selector)
+}
+
+// GetClusterByName returns the cluster with the given name
+func (s *BoltStore) GetClusterByName(name string) (*models.Cluster, error) {
+	return clusterByName(s.GetClusters(), name)
+}
+
+// AddCluster adds a new cluster
+func (s *BoltStore) AddCluster(cluster models.Cluster) error {
+	if cluster.ID == "" {
+		cluster.ID = uuid.New().String()
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var clusters []models.Cluster
+		err := tx.Bucket(clustersBucket).ForEach(func(k, v []byte) error {
+			c, _, err := s.decode(v)
+			if err != nil {
+				return nil // unreadable entries are skipped like in GetClusters
+			}
+			clusters = append(clusters, c)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := checkNameFree(clusters, cluster.ID, cluster.Name); err != nil {
+			return err
+		}
+		return s.putCluster(tx, cluster)
+	})
+}
+
+// EnsureCluster adds a cluster unless one with the same ID exists
+func (s *BoltStore) EnsureCluster(cluster models.Cluster) (bool, error) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(clustersBucket).Get([]byte(cluster.ID)) != nil {
+			return nil
+		}
+		added = true
+		return s.putCluster(tx, cluster)
+	})
+	return added, err
+}
+
+// DeleteCluster deletes a cluster and its desired rules by ID
+func (s *BoltStore) DeleteCluster(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(clustersBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(rulesBucket).Delete([]byte(id))
+	})
+}
+
+// UpdateCluster updates an existing cluster
+func (s *BoltStore) UpdateCluster(cluster models.Cluster) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(clustersBucket).Get([]byte(cluster.ID)) == nil {
+			return nil
+		}
+		return s.putCluster(tx, cluster)
+	})
+}
+
+// GetDesiredRules returns the intended forward rules of a cluster
+func (s *BoltStore) GetDesiredRules(clusterID string) ([]models.ForwardRule, bool) {
+	var rules []models.ForwardRule
+	var ok bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rules, ok, err = getRules(tx, clusterID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to read desired rules: %v", err)
+		return nil, false
+	}
+	return rules, ok
+}
+
+// SetDesiredRules replaces the intended forward rules of a cluster
+func (s *BoltStore) SetDesiredRules(clusterID string, rules []models.ForwardRule) error {
+	if rules == nil {
+		rules = []models.ForwardRule{}
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putJSON(tx.Bucket(rulesBucket), clusterID, rules)
+	})
+}
+
+// AddDesiredRule adds or replaces an intended forward rule of a cluster
+func (s *BoltStore) AddDesiredRule(clusterID string, rule models.ForwardRule) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rules, _, err := getRules(tx, clusterID)
+		if err != nil {
+			return err
+		}
+		replaced := false
+		for i, r := range rules {
+			if r.GetDomainBlock() == rule.GetDomainBlock() {
+				rules[i] = rule
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			rules = append(rules, rule)
+		}
+		return putJSON(tx.Bucket(rulesBucket), clusterID, rules)
+	})
+}
+
+// DeleteDesiredRule removes an intended forward rule identified by its domain block
+func (s *BoltStore) DeleteDesiredRule(clusterID, domainBlock string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rules, ok, err := getRules(tx, clusterID)
+		if err != nil || !ok {
+			return err
+		}
+		for i, r := range rules {
+			if r.GetDomainBlock() == domainBlock {
+				return putJSON(tx.Bucket(rulesBucket), clusterID, append(rules[:i], rules[i+1:]...))
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+func TestBoltStoreImportsJSON(t *testing.T) {
+	dir := t.TempDir()
+	const secret = "c2VjcmV0LWt1YmVjb25maWc="
+	rule := models.ForwardRule{Namespace: "payments", TargetIP: "10.0.0.10"}
+
+	// Written by the JSON backend without encryption
+	js, err := NewJSONStore(dir, nil)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if err := js.AddCluster(models.Cluster{ID: "a", Name: "prod-a", Kubeconfig: secret}); err != nil {
+		t.Fatalf("AddCluster: %v", err)
+	}
+	if err := js.SetDesiredRules("a", []models.ForwardRule{rule}); err != nil {
+		t.Fatalf("SetDesiredRules: %v", err)
+	}
+
+	s, err := NewBoltStore(dir, testKeyring(t, testKey(t, 1)))
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	cluster, ok := s.GetCluster("a")
+	if !ok || cluster.Kubeconfig != secret {
+		t.Fatalf("GetCluster() = %+v, %v; want the imported cluster", cluster, ok)
+	}
+	rules, ok := s.GetDesiredRules("a")
+	if !ok || len(rules) != 1 || rules[0].Namespace != rule.Namespace {
+		t.Errorf("GetDesiredRules() = %+v, %v; want the imported rules", rules, ok)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != BoltFile {
+			t.Errorf("%s left in the data directory after import", entry.Name())
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, BoltFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("database contains the plaintext kubeconfig")
+	}
+}
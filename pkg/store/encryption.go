@@ -0,0 +1,198 @@
+package store
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+// KeySize is the size of a master key in bytes (AES-256)
+const KeySize = 32
+
+// Keyring holds the master keys that encrypt stored credentials. New secrets are
+// sealed with the primary key, previous keys are only used to open secrets that
+// weren't re-encrypted yet.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a keyring from the primary master key and any previous ones
+func NewKeyring(primary []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+	for i, key := range append([][]byte{primary}, previous...) {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+		}
+		id := keyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+	return k, nil
+}
+
+// GenerateKey returns a new random master key encoded as base64
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseKey decodes a base64 encoded master key
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace([]byte(s))))
+	if err != nil {
+		return nil, fmt.Errorf("master key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// ReadKeyFile reads a base64 encoded master key from a file
+func ReadKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := ParseKey(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// keyID identifies a master key without revealing it
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// sealedSecret is a value encrypted with a random data key, which in turn is
+// encrypted with a master key
+type sealedSecret struct {
+	KeyID      string `json:"key_id"`     // master key that sealed the data key
+	DataKey    []byte `json:"data_key"`   // nonce + sealed data key
+	Ciphertext []byte `json:"ciphertext"` // nonce + sealed value
+}
+
+// seal encrypts plaintext with a new data key under the primary master key.
+// The ciphertext is bound to aad, so it can't be moved to another record.
+func (k *Keyring) seal(plaintext, aad []byte) (*sealedSecret, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	ciphertext, err := gcmSeal(dataKey, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := gcmSeal(k.keys[k.primary], dataKey, []byte(k.primary))
+	if err != nil {
+		return nil, err
+	}
+	return &sealedSecret{KeyID: k.primary, DataKey: wrapped, Ciphertext: ciphertext}, nil
+}
+
+// open decrypts a sealed secret with whichever master key sealed it
+func (k *Keyring) open(s *sealedSecret, aad []byte) ([]byte, error) {
+	master, ok := k.keys[s.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %s is not configured", s.KeyID)
+	}
+	dataKey, err := gcmOpen(master, s.DataKey, []byte(s.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	plaintext, err := gcmOpen(dataKey, s.Ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
+
+func gcmSeal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, data, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// storedCluster is the on-disk form of a cluster, with the kubeconfig moved
+// into EncryptedKubeconfig when encryption is enabled
+type storedCluster struct {
+	models.Cluster
+	EncryptedKubeconfig *sealedSecret `json:"encrypted_kubeconfig,omitempty"`
+}
+
+// encryptCluster returns the on-disk form of a cluster, leaving it in
+// plaintext if the keyring is nil
+func (k *Keyring) encryptCluster(c models.Cluster) (storedCluster, error) {
+	if k == nil || c.Kubeconfig == "" {
+		return storedCluster{Cluster: c}, nil
+	}
+	sealed, err := k.seal([]byte(c.Kubeconfig), []byte(c.ID))
+	if err != nil {
+		return storedCluster{}, fmt.Errorf("failed to encrypt kubeconfig of cluster %s: %w", c.Name, err)
+	}
+	c.Kubeconfig = ""
+	return storedCluster{Cluster: c, EncryptedKubeconfig: sealed}, nil
+}
+
+// decryptCluster restores a cluster from its on-disk form. It reports whether
+// the record should be rewritten: stored in plaintext while encryption is
+// enabled, or sealed with a previous master key.
+func (k *Keyring) decryptCluster(sc storedCluster) (models.Cluster, bool, error) {
+	c := sc.Cluster
+	if sc.EncryptedKubeconfig == nil {
+		return c, k != nil && c.Kubeconfig != "", nil
+	}
+	if k == nil {
+		return c, false, fmt.Errorf("cluster %s has encrypted credentials but no encryption key is configured", c.Name)
+	}
+	plaintext, err := k.open(sc.EncryptedKubeconfig, []byte(c.ID))
+	if err != nil {
+		return c, false, fmt.Errorf("failed to decrypt kubeconfig of cluster %s: %w", c.Name, err)
+	}
+	c.Kubeconfig = string(plaintext)
+	return c, sc.EncryptedKubeconfig.KeyID != k.primary, nil
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+func testKey(t *testing.T, fill byte) []byte {
+	t.Helper()
+	return bytes.Repeat([]byte{fill}, KeySize)
+}
+
+func testKeyring(t *testing.T, primary []byte, previous ...[]byte) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(primary, previous...)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return k
+}
+
+func TestParseKey(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "valid", input: valid},
+		{name: "trailing newline", input: valid + "\n"},
+		{name: "not base64", input: "not a key!", wantErr: true},
+		{name: "too short", input: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseKey(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(key) != KeySize {
+				t.Errorf("ParseKey() returned %d bytes, want %d", len(key), KeySize)
+			}
+		})
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	a, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	b, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	if a == b {
+		t.Error("GenerateKey returned the same key twice")
+	}
+	if _, err := ParseKey(a); err != nil {
+		t.Errorf("ParseKey(GenerateKey()) error = %v", err)
+	}
+}
+
+func TestNewKeyringRejectsShortKeys(t *testing.T) {
+	if _, err := NewKeyring([]byte("short")); err == nil {
+		t.Error("expected an error for a short primary key")
+	}
+	if _, err := NewKeyring(testKey(t, 1), []byte("short")); err == nil {
+		t.Error("expected an error for a short previous key")
+	}
+}
+
+func TestKeyringSealOpen(t *testing.T) {
+	oldKey, newKey, otherKey := testKey(t, 1), testKey(t, 2), testKey(t, 3)
+	plaintext := []byte("apiVersion: v1\nkind: Config\n")
+
+	tests := []struct {
+		name    string
+		sealer  *Keyring
+		opener  *Keyring
+		aad     string
+		tamper  func(s *sealedSecret)
+		wantErr bool
+	}{
+		{
+			name:   "same key",
+			sealer: testKeyring(t, newKey),
+			opener: testKeyring(t, newKey),
+			aad:    "cluster-a",
+		},
+		{
+			name:   "previous key after rotation",
+			sealer: testKeyring(t, oldKey),
+			opener: testKeyring(t, newKey, oldKey),
+			aad:    "cluster-a",
+		},
+		{
+			name:    "previous key removed",
+			sealer:  testKeyring(t, oldKey),
+			opener:  testKeyring(t, newKey),
+			aad:     "cluster-a",
+			wantErr: true,
+		},
+		{
+			name:    "wrong key",
+			sealer:  testKeyring(t, newKey),
+			opener:  testKeyring(t, otherKey),
+			aad:     "cluster-a",
+			wantErr: true,
+		},
+		{
+			name:    "moved to another record",
+			sealer:  testKeyring(t, newKey),
+			opener:  testKeyring(t, newKey),
+			aad:     "cluster-b",
+			wantErr: true,
+		},
+		{
+			name:    "tampered ciphertext",
+			sealer:  testKeyring(t, newKey),
+			opener:  testKeyring(t, newKey),
+			aad:     "cluster-a",
+			tamper:  func(s *sealedSecret) { s.Ciphertext[len(s.Ciphertext)-1] ^= 1 },
+			wantErr: true,
+		},
+		{
+			name:    "tampered data key",
+			sealer:  testKeyring(t, newKey),
+			opener:  testKeyring(t, newKey),
+			aad:     "cluster-a",
+			tamper:  func(s *sealedSecret) { s.DataKey[len(s.DataKey)-1] ^= 1 },
+			wantErr: true,
+		},
+		{
+			name:    "truncated ciphertext",
+			sealer:  testKeyring(t, newKey),
+			opener:  testKeyring(t, newKey),
+			aad:     "cluster-a",
+			tamper:  func(s *sealedSecret) { s.Ciphertext = s.Ciphertext[:4] },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := tt.sealer.seal(plaintext, []byte("cluster-a"))
+			if err != nil {
+				t.Fatalf("seal: %v", err)
+			}
+			if bytes.Contains(sealed.Ciphertext, plaintext) {
+				t.Fatal("ciphertext contains the plaintext")
+			}
+			if tt.tamper != nil {
+				tt.tamper(sealed)
+			}
+
+			got, err := tt.opener.open(sealed, []byte(tt.aad))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, plaintext) {
+				t.Errorf("open() = %q, want %q", got, plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptCluster(t *testing.T) {
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+	cluster := models.Cluster{ID: "a", Name: "prod-a", Kubeconfig: "a3ViZWNvbmZpZw=="}
+
+	sealWith := func(k *Keyring) storedCluster {
+		sc, err := k.encryptCluster(cluster)
+		if err != nil {
+			t.Fatalf("encryptCluster: %v", err)
+		}
+		if sc.Kubeconfig != "" || sc.EncryptedKubeconfig == nil {
+			t.Fatalf("encryptCluster left the kubeconfig in plaintext")
+		}
+		return sc
+	}
+
+	tests := []struct {
+		name      string
+		stored    storedCluster
+		keys      *Keyring
+		wantStale bool
+		wantErr   bool
+	}{
+		{
+			name:   "plaintext without encryption",
+			stored: storedCluster{Cluster: cluster},
+		},
+		{
+			name:      "plaintext is migrated",
+			stored:    storedCluster{Cluster: cluster},
+			keys:      testKeyring(t, newKey),
+			wantStale: true,
+		},
+		{
+			name:   "no credentials",
+			stored: storedCluster{Cluster: models.Cluster{ID: "local", Source: models.SourceInCluster}},
+			keys:   testKeyring(t, newKey),
+		},
+		{
+			name:   "sealed with the primary key",
+			stored: sealWith(testKeyring(t, newKey)),
+			keys:   testKeyring(t, newKey),
+		},
+		{
+			name:      "sealed with a previous key is rotated",
+			stored:    sealWith(testKeyring(t, oldKey)),
+			keys:      testKeyring(t, newKey, oldKey),
+			wantStale: true,
+		},
+		{
+			name:    "sealed with an unknown key",
+			stored:  sealWith(testKeyring(t, oldKey)),
+			keys:    testKeyring(t, newKey),
+			wantErr: true,
+		},
+		{
+			name:    "sealed but encryption disabled",
+			stored:  sealWith(testKeyring(t, newKey)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, stale, err := tt.keys.decryptCluster(tt.stored)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decryptCluster() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if stale != tt.wantStale {
+				t.Errorf("decryptCluster() stale = %v, want %v", stale, tt.wantStale)
+			}
+			want := tt.stored.Kubeconfig
+			if tt.stored.EncryptedKubeconfig != nil {
+				want = cluster.Kubeconfig
+			}
+			if got.Kubeconfig != want {
+				t.Errorf("decryptCluster() kubeconfig = %q, want %q", got.Kubeconfig, want)
+			}
+		})
+	}
+}
+
+func TestJSONStoreMigratesAndRotates(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, newKey := testKey(t, 1), testKey(t, 2)
+	const secret = "c2VjcmV0LWt1YmVjb25maWc="
+
+	// Written by a version without encryption
+	s, err := NewJSONStore(dir, nil)
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	if err := s.AddCluster(models.Cluster{ID: "a", Name: "prod-a", Kubeconfig: secret}); err != nil {
+		t.Fatalf("AddCluster: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "clusters.json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		keys *Keyring
+	}{
+		{name: "encrypt plaintext", keys: testKeyring(t, oldKey)},
+		{name: "rotate to a new key", keys: testKeyring(t, newKey, oldKey)},
+		{name: "drop the previous key", keys: testKeyring(t, newKey)},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			s, err := NewJSONStore(dir, step.keys)
+			if err != nil {
+				t.Fatalf("NewJSONStore: %v", err)
+			}
+			cluster, ok := s.GetCluster("a")
+			if !ok || cluster.Kubeconfig != secret {
+				t.Fatalf("GetCluster() = %+v, %v; want the decrypted kubeconfig", cluster, ok)
+			}
+
+			path := filepath.Join(dir, "clusters.json")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), secret) {
+				t.Error("clusters.json contains the plaintext kubeconfig")
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("clusters.json mode = %o, want 600", perm)
+			}
+		})
+	}
+
+	if _, err := NewJSONStore(dir, testKeyring(t, oldKey)); err == nil {
+		t.Error("expected an error opening the store with a key that was rotated out")
+	}
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"coredns-multi-configuration/pkg/models"
+
+	"github.com/google/uuid"
+)
+
+// JSONStore keeps all data in memory and rewrites it to JSON files on each change
+type JSONStore struct {
+	dataDir  string
+	mu       sync.RWMutex
+	clusters []models.Cluster
+	rules    map[string][]models.ForwardRule // desired forward rules by cluster ID
+	keys     *Keyring                        // encrypts kubeconfigs at rest, nil stores them in plaintext
+}
+
+// NewJSONStore creates a JSON file store in dataDir. Kubeconfigs are encrypted with keys
+// unless it is nil; existing entries in plaintext or sealed with a previous
+// master key are re-encrypted with the primary key.
+func NewJSONStore(dataDir string, keys *Keyring) (*JSONStore, error) {
+	// Ensure data directory exists
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+
+	s := &JSONStore{
+		dataDir:  dataDir,
+		clusters: make([]models.Cluster, 0),
+		rules:    make(map[string][]models.ForwardRule),
+		keys:     keys,
+	}
+
+	// Tighten permissions of files written by older versions
+	for _, path := range []string{s.clustersFile(), s.rulesFile()} {
+		if err := os.Chmod(path, 0600); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	// Load existing data
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *JSONStore) clustersFile() string {
+	return filepath.Join(s.dataDir, "clusters.json")
+}
+
+func (s *JSONStore) rulesFile() string {
+	return filepath.Join(s.dataDir, "rules.json")
+}
+
+func (s *JSONStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Load clusters
+	var stored []storedCluster
+	if err := readJSON(s.clustersFile(), &stored); err != nil {
+		return err
+	}
+	rewrite := 0
+	for _, sc := range stored {
+		cluster, stale, err := s.keys.decryptCluster(sc)
+		if err != nil {
+			return err
+		}
+		if stale {
+			rewrite++
+		}
+		s.clusters = append(s.clusters, cluster)
+	}
+	if rewrite > 0 {
+		if err := s.save(); err != nil {
+			return fmt.Errorf("failed to re-encrypt clusters: %w", err)
+		}
+		log.Printf("Encrypted the credentials of %d stored clusters with the primary key", rewrite)
+	}
+
+	// Load desired rules
+	return readJSON(s.rulesFile(), &s.rules)
+}
+
+func (s *JSONStore) save() error {
+	stored := make([]storedCluster, len(s.clusters))
+	for i, c := range s.clusters {
+		sc, err := s.keys.encryptCluster(c)
+		if err != nil {
+			return err
+		}
+		stored[i] = sc
+	}
+	return writeJSON(s.clustersFile(), stored)
+}
+
+func (s *JSONStore) saveRules() error {
+	return writeJSON(s.rulesFile(), s.rules)
+}
+
+// readJSON decodes a JSON file into v, leaving v untouched if the file doesn't exist
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // No data yet
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSON encodes v as indented JSON into path. The file is replaced
+// atomically and only readable by the owner.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// GetClusters returns all clusters
+func (s *JSONStore) GetClusters() []models.Cluster {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Cluster, len(s.clusters))
+	copy(result, s.clusters)
+	return result
+}
+
+// GetCluster returns a cluster by ID
+func (s *JSONStore) GetCluster(id string) (*models.Cluster, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.clusters {
+		if c.ID == id {
+			return &c, true
+		}
+	}
+	return nil, false
+}
+
+// SelectClusters returns the clusters matching a label selector
+func (s *JSONStore) SelectClusters(selector string) ([]models.Cluster, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return selectClusters(s.clusters, selector)
+}
+
+// GetClusterByName returns the cluster with the given name
+func (s *JSONStore) GetClusterByName(name string) (*models.Cluster, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return clusterByName(s.clusters, name)
+}
+
+// Close implements Store, the JSON store holds no open resources
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// AddCluster adds a new cluster
+func (s *JSONStore) AddCluster(cluster models.Cluster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := checkNameFree(s.clusters, cluster.ID, cluster.Name); err != nil {
+		return err
+	}
+	if cluster.ID == "" {
+		cluster.ID = uuid.New().String()
+	}
+	s.clusters = append(s.clusters, cluster)
+	return s.save()
+}
+
+// EnsureCluster adds a cluster unless one with the same ID exists.
+// It reports whether the cluster was added.
+func (s *JSONStore) EnsureCluster(cluster models.Cluster) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.clusters {
+		if c.ID == cluster.ID {
+			return false, nil
+		}
+	}
+	s.clusters = append(s.clusters, cluster)
+	return true, s.save()
+}
+
+// DeleteCluster deletes a cluster by ID
+func (s *JSONStore) DeleteCluster(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.clusters {
+		if c.ID == id {
+			s.clusters = append(s.clusters[:i], s.clusters[i+1:]...)
+			if err := s.save(); err != nil {
+				return err
+			}
+			if _, ok := s.rules[id]; ok {
+				delete(s.rules, id)
+				return s.saveRules()
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// UpdateCluster updates an existing cluster
+func (s *JSONStore) UpdateCluster(cluster models.Cluster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.clusters {
+		if c.ID == cluster.ID {
+			s.clusters[i] = cluster
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// GetDesiredRules returns the intended forward rules of a cluster.
+// The second return value is false if no desired state was recorded yet.
+func (s *JSONStore) GetDesiredRules(clusterID string) ([]models.ForwardRule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules, ok := s.rules[clusterID]
+	if !ok {
+		return nil, false
+	}
+	result := make([]models.ForwardRule, len(rules))
+	copy(result, rules)
+	return result, true
+}
+
+// SetDesiredRules replaces the intended forward rules of a cluster
+func (s *JSONStore) SetDesiredRules(clusterID string, rules []models.ForwardRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]models.ForwardRule, len(rules))
+	copy(stored, rules)
+	s.rules[clusterID] = stored
+	return s.saveRules()
+}
+
+// AddDesiredRule adds or replaces an intended forward rule of a cluster
+func (s *JSONStore) AddDesiredRule(clusterID string, rule models.ForwardRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := s.rules[clusterID]
+	for i, r := range rules {
+		if r.GetDomainBlock() == rule.GetDomainBlock() {
+			rules[i] = rule
+			return s.saveRules()
+		}
+	}
+	s.rules[clusterID] = append(rules, rule)
+	return s.saveRules()
+}
+
+// DeleteDesiredRule removes an intended forward rule identified by its domain block
+func (s *JSONStore) DeleteDesiredRule(clusterID, domainBlock string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules, ok := s.rules[clusterID]
+	if !ok {
+		return nil
+	}
+	for i, r := range rules {
+		if r.GetDomainBlock() == domainBlock {
+			s.rules[clusterID] = append(rules[:i], rules[i+1:]...)
+			return s.saveRules()
+		}
+	}
+	return nil
+}
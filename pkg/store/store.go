@@ -1,129 +1,112 @@
 package store
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sync"
+	"errors"
+	"fmt"
 
 	"coredns-multi-configuration/pkg/models"
 
-	"github.com/google/uuid"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
-// Store provides JSON file-based storage for application data
-type Store struct {
-	dataDir  string
-	mu       sync.RWMutex
-	clusters []models.Cluster
+// Store persists clusters and their desired forward rules
+type Store interface {
+	// GetClusters returns all clusters
+	GetClusters() []models.Cluster
+	// GetCluster returns a cluster by ID
+	GetCluster(id string) (*models.Cluster, bool)
+	// SelectClusters returns the clusters matching a label selector such as
+	// "env=staging,region in (eu-west,eu-central)". An empty selector matches all clusters.
+	SelectClusters(selector string) ([]models.Cluster, error)
+	// GetClusterByName returns the cluster with the given name.
+	// An error is returned if no cluster or more than one cluster has that name.
+	GetClusterByName(name string) (*models.Cluster, error)
+	// AddCluster adds a new cluster. It returns ErrClusterExists if another
+	// cluster already has the same name.
+	AddCluster(cluster models.Cluster) error
+	// EnsureCluster adds a cluster unless one with the same ID exists.
+	// It reports whether the cluster was added.
+	EnsureCluster(cluster models.Cluster) (bool, error)
+	// DeleteCluster deletes a cluster and its desired rules by ID
+	DeleteCluster(id string) error
+	// UpdateCluster updates an existing cluster
+	UpdateCluster(cluster models.Cluster) error
+
+	// GetDesiredRules returns the intended forward rules of a cluster.
+	// The second return value is false if no desired state was recorded yet.
+	GetDesiredRules(clusterID string) ([]models.ForwardRule, bool)
+	// SetDesiredRules replaces the intended forward rules of a cluster
+	SetDesiredRules(clusterID string, rules []models.ForwardRule) error
+	// AddDesiredRule adds or replaces an intended forward rule of a cluster
+	AddDesiredRule(clusterID string, rule models.ForwardRule) error
+	// DeleteDesiredRule removes an intended forward rule identified by its domain block
+	DeleteDesiredRule(clusterID, domainBlock string) error
+
+	// Close releases the resources of the store
+	Close() error
 }
 
-// New creates a new Store instance
-func New(dataDir string) (*Store, error) {
-	// Ensure data directory exists
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return nil, err
-	}
-
-	s := &Store{
-		dataDir:  dataDir,
-		clusters: make([]models.Cluster, 0),
-	}
-
-	// Load existing data
-	if err := s.load(); err != nil {
-		return nil, err
-	}
-
-	return s, nil
-}
+// ErrClusterExists is returned when a cluster name is already taken
+var ErrClusterExists = errors.New("cluster already exists")
 
-func (s *Store) clustersFile() string {
-	return filepath.Join(s.dataDir, "clusters.json")
-}
-
-func (s *Store) load() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// Storage backends
+const (
+	BackendJSON = "json" // JSON files in the data directory
+	BackendBolt = "bolt" // embedded bbolt database in the data directory
+)
 
-	// Load clusters
-	data, err := os.ReadFile(s.clustersFile())
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // No data yet
-		}
-		return err
+// Open opens the store of the given backend in dataDir
+func Open(backend, dataDir string, keys *Keyring) (Store, error) {
+	switch backend {
+	case BackendJSON, "":
+		return NewJSONStore(dataDir, keys)
+	case BackendBolt:
+		return NewBoltStore(dataDir, keys)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
 	}
-
-	return json.Unmarshal(data, &s.clusters)
 }
 
-func (s *Store) save() error {
-	data, err := json.MarshalIndent(s.clusters, "", "  ")
+// selectClusters returns the clusters matching a label selector
+func selectClusters(clusters []models.Cluster, selector string) ([]models.Cluster, error) {
+	sel, err := labels.Parse(selector)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("invalid selector: %w", err)
 	}
-	return os.WriteFile(s.clustersFile(), data, 0644)
-}
 
-// GetClusters returns all clusters
-func (s *Store) GetClusters() []models.Cluster {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	result := make([]models.Cluster, len(s.clusters))
-	copy(result, s.clusters)
-	return result
-}
-
-// GetCluster returns a cluster by ID
-func (s *Store) GetCluster(id string) (*models.Cluster, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	for _, c := range s.clusters {
-		if c.ID == id {
-			return &c, true
+	result := make([]models.Cluster, 0)
+	for _, c := range clusters {
+		if sel.Matches(labels.Set(c.SelectorLabels())) {
+			result = append(result, c)
 		}
 	}
-	return nil, false
+	return result, nil
 }
 
-// AddCluster adds a new cluster
-func (s *Store) AddCluster(cluster models.Cluster) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if cluster.ID == "" {
-		cluster.ID = uuid.New().String()
-	}
-	s.clusters = append(s.clusters, cluster)
-	return s.save()
-}
-
-// DeleteCluster deletes a cluster by ID
-func (s *Store) DeleteCluster(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for i, c := range s.clusters {
-		if c.ID == id {
-			s.clusters = append(s.clusters[:i], s.clusters[i+1:]...)
-			return s.save()
+// clusterByName returns the only cluster with the given name
+func clusterByName(clusters []models.Cluster, name string) (*models.Cluster, error) {
+	var found *models.Cluster
+	for i := range clusters {
+		if clusters[i].Name != name {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("cluster name %s is ambiguous", name)
 		}
+		c := clusters[i]
+		found = &c
 	}
-	return nil
+	if found == nil {
+		return nil, fmt.Errorf("cluster %s not found", name)
+	}
+	return found, nil
 }
 
-// UpdateCluster updates an existing cluster
-func (s *Store) UpdateCluster(cluster models.Cluster) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for i, c := range s.clusters {
-		if c.ID == cluster.ID {
-			s.clusters[i] = cluster
-			return s.save()
+// checkNameFree returns ErrClusterExists if a cluster other than id has the given name
+func checkNameFree(clusters []models.Cluster, id, name string) error {
+	for _, c := range clusters {
+		if c.ID != id && c.Name == name {
+			return fmt.Errorf("cluster %s: %w", name, ErrClusterExists)
 		}
 	}
 	return nil
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+func TestAddClusterRejectsDuplicateName(t *testing.T) {
+	backends := map[string]func(dir string) (Store, error){
+		BackendJSON: func(dir string) (Store, error) { return NewJSONStore(dir, nil) },
+		BackendBolt: func(dir string) (Store, error) { return NewBoltStore(dir, nil) },
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			s, err := open(t.TempDir())
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer s.Close()
+
+			if err := s.AddCluster(models.Cluster{ID: "a", Name: "prod"}); err != nil {
+				t.Fatalf("AddCluster: %v", err)
+			}
+			if err := s.AddCluster(models.Cluster{ID: "b", Name: "prod"}); !errors.Is(err, ErrClusterExists) {
+				t.Fatalf("AddCluster() with a taken name error = %v, want ErrClusterExists", err)
+			}
+			if err := s.AddCluster(models.Cluster{ID: "c", Name: "staging"}); err != nil {
+				t.Fatalf("AddCluster: %v", err)
+			}
+			if got := len(s.GetClusters()); got != 2 {
+				t.Errorf("GetClusters() returned %d clusters, want 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package topology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"coredns-multi-configuration/pkg/k8s"
+	"coredns-multi-configuration/pkg/models"
+	"coredns-multi-configuration/pkg/store"
+)
+
+// Node is a cluster with its DNS addresses and parsed forward rules
+type Node struct {
+	ClusterID   string               `json:"cluster_id"`
+	ClusterName string               `json:"cluster_name"`
+	Addresses   []string             `json:"addresses"`
+	Rules       []models.ForwardRule `json:"-"`
+	Error       string               `json:"error,omitempty"`
+	Unknown     bool                 `json:"unknown,omitempty"` // rules could not be read, addresses may come from the cache
+}
+
+// Edge is a forward rule from one cluster to another cluster's DNS
+type Edge struct {
+	From       string `json:"from"`         // cluster ID
+	To         string `json:"to,omitempty"` // cluster ID, empty for dead-ends
+	TargetIP   string `json:"target_ip"`
+	IsFullFQDN bool   `json:"is_full_fqdn"`
+	DeadEnd    bool   `json:"dead_end,omitempty"`   // target IP is not any cluster's DNS
+	Unverified bool   `json:"unverified,omitempty"` // target is an unknown cluster or may belong to one
+}
+
+// NameGraph is the forwarding graph of a single name (namespace or service.namespace)
+type NameGraph struct {
+	Name   string     `json:"name"`
+	Edges  []Edge     `json:"edges"`
+	Cycles [][]string `json:"cycles"` // each cycle is a list of cluster IDs
+}
+
+// Report is the result of a global forwarding analysis
+type Report struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Clusters    []Node      `json:"clusters"`
+	Names       []NameGraph `json:"names"`
+	Cycles      int         `json:"cycles"`
+	DeadEnds    int         `json:"dead_ends"`
+	Unverified  int         `json:"unverified"`
+}
+
+// clusterTimeout bounds the read of each cluster in Collect, so unreachable
+// clusters can't use up the deadline of the caller
+const clusterTimeout = 3 * time.Second
+
+// Analyzer builds forwarding graphs over all registered clusters
+type Analyzer struct {
+	store   store.Store
+	coreDNS *k8s.CoreDNSHandler
+}
+
+// NewAnalyzer creates a new Analyzer
+func NewAnalyzer(store store.Store, coreDNS *k8s.CoreDNSHandler) *Analyzer {
+	return &Analyzer{store: store, coreDNS: coreDNS}
+}
+
+// Collect fetches DNS addresses and forward rules of every cluster concurrently.
+// Each cluster gets at most clusterTimeout, clusters that don't answer in time
+// are returned as unknown with an error and the addresses of their cache, if any.
+func (a *Analyzer) Collect(ctx context.Context) []Node {
+	clusters := a.store.GetClusters()
+	nodes := make([]Node, len(clusters))
+
+	var wg sync.WaitGroup
+	for i := range clusters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cluster := &clusters[i]
+			node := Node{ClusterID: cluster.ID, ClusterName: cluster.Name}
+
+			clusterCtx, cancel := context.WithTimeout(ctx, clusterTimeout)
+			defer cancel()
+
+			info, err := a.coreDNS.GetCoreDNSInfo(clusterCtx, cluster)
+			if err != nil {
+				node.Error = err.Error()
+				node.Unknown = true
+				node.Addresses = a.coreDNS.CachedDNSAddresses(cluster.ID)
+			} else {
+				node.Addresses = k8s.ServiceAddresses(info.Service)
+				node.Rules = info.ForwardRules
+			}
+			nodes[i] = node
+		}(i)
+	}
+	wg.Wait()
+
+	return nodes
+}
+
+// Analyze builds the forwarding graph of every forwarded name and detects
+// cycles and dead-ends. Edges into unknown clusters, and edges to unowned
+// addresses while an unknown cluster has no known address, are unverified
+// instead: the forwarding beyond them can't be followed.
+//
+// Short rules (payments:53) rewrite queries to payments.svc.cluster.local
+// before forwarding, and FQDN rules forward them unchanged, so a query that
+// arrives at another cluster is always a *.svc.cluster.local name. It is
+// forwarded again only by an FQDN rule there, so cycles can only be formed
+// by FQDN rules; short rules can at most lead into one.
+func Analyze(nodes []Node) *Report {
+	owners := ownersOf(nodes)
+	unknown := unknownClusters(nodes)
+	blind := len(addresslessClusters(nodes)) > 0
+
+	graphs := make(map[string]*NameGraph)
+	for _, n := range nodes {
+		for _, rule := range n.Rules {
+			name := rule.GetFullName()
+			g, ok := graphs[name]
+			if !ok {
+				g = &NameGraph{Name: name, Edges: make([]Edge, 0), Cycles: make([][]string, 0)}
+				graphs[name] = g
+			}
+
+			edge := Edge{From: n.ClusterID, TargetIP: rule.TargetIP, IsFullFQDN: rule.IsFullFQDN}
+			if to, ok := owners[rule.TargetIP]; ok {
+				edge.To = to
+				edge.Unverified = unknown[to]
+			} else if blind {
+				edge.Unverified = true
+			} else {
+				edge.DeadEnd = true
+			}
+			g.Edges = append(g.Edges, edge)
+		}
+	}
+
+	report := &Report{
+		GeneratedAt: time.Now(),
+		Clusters:    nodes,
+		Names:       make([]NameGraph, 0, len(graphs)),
+	}
+
+	for name, g := range graphs {
+		g.Cycles = findCycles(nodes, owners, name)
+		report.Cycles += len(g.Cycles)
+		for _, e := range g.Edges {
+			if e.DeadEnd {
+				report.DeadEnds++
+			}
+			if e.Unverified {
+				report.Unverified++
+			}
+		}
+		report.Names = append(report.Names, *g)
+	}
+
+	sort.Slice(report.Names, func(i, j int) bool { return report.Names[i].Name < report.Names[j].Name })
+	return report
+}
+
+// WouldCreateCycle reports whether adding rule to a cluster introduces a new
+// forwarding cycle, and returns a description of it. Unverified lists the
+// unknown clusters the rule may forward into: a loop through them can't be
+// ruled out, so the check is only partial if it is not empty.
+func WouldCreateCycle(nodes []Node, clusterID string, rule models.ForwardRule) (loop bool, description string, unverified []string) {
+	withRule := WithRule(nodes, clusterID, rule)
+	before := Analyze(nodes)
+	after := Analyze(withRule)
+
+	existing := make(map[string]bool)
+	for _, g := range before.Names {
+		for _, c := range g.Cycles {
+			existing[g.Name+"|"+strings.Join(c, ",")] = true
+		}
+	}
+
+	names := make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		names[n.ClusterID] = n.ClusterName
+	}
+
+	for _, g := range after.Names {
+		for _, c := range g.Cycles {
+			if existing[g.Name+"|"+strings.Join(c, ",")] {
+				continue
+			}
+			path := make([]string, 0, len(c)+1)
+			for _, id := range c {
+				path = append(path, names[id])
+			}
+			path = append(path, names[c[0]])
+			return true, fmt.Sprintf("forwarding loop for %s: %s", g.Name, strings.Join(path, " -> ")), nil
+		}
+	}
+	return false, "", unverifiedPath(withRule, clusterID, rule)
+}
+
+// unverifiedPath follows rule from a cluster through the FQDN rules of the
+// clusters it reaches and returns the names of the unknown clusters where the
+// path can't be followed any further
+func unverifiedPath(nodes []Node, clusterID string, rule models.ForwardRule) []string {
+	if !rule.IsFullFQDN {
+		return nil // the forwarded query is not forwarded again, see Analyze
+	}
+
+	owners := ownersOf(nodes)
+	byID := make(map[string]*Node, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ClusterID] = &nodes[i]
+	}
+
+	name := rule.GetFullName()
+	visited := map[string]bool{clusterID: true}
+	for r := &rule; r != nil; {
+		next, ok := owners[r.TargetIP]
+		if !ok {
+			return addresslessClusters(nodes) // the target may be one of them
+		}
+		if byID[next].Unknown {
+			return []string{byID[next].ClusterName}
+		}
+		if visited[next] {
+			return nil
+		}
+		visited[next] = true
+		r = matchRule(byID[next].Rules, name)
+	}
+	return nil
+}
+
+// ownersOf maps DNS addresses to the IDs of the clusters they belong to
+func ownersOf(nodes []Node) map[string]string {
+	owners := make(map[string]string)
+	for _, n := range nodes {
+		for _, addr := range n.Addresses {
+			owners[addr] = n.ClusterID
+		}
+	}
+	return owners
+}
+
+// unknownClusters returns the IDs of the clusters that could not be read
+func unknownClusters(nodes []Node) map[string]bool {
+	unknown := make(map[string]bool)
+	for _, n := range nodes {
+		if n.Unknown {
+			unknown[n.ClusterID] = true
+		}
+	}
+	return unknown
+}
+
+// addresslessClusters returns the names of the unknown clusters whose DNS
+// addresses are not known either
+func addresslessClusters(nodes []Node) []string {
+	var names []string
+	for _, n := range nodes {
+		if n.Unknown && len(n.Addresses) == 0 {
+			names = append(names, n.ClusterName)
+		}
+	}
+	return names
+}
+
+// WithRule returns a copy of nodes in which a cluster also has rule. The
+// original nodes are not modified.
+func WithRule(nodes []Node, clusterID string, rule models.ForwardRule) []Node {
+	result := make([]Node, len(nodes))
+	copy(result, nodes)
+	for i := range result {
+		if result[i].ClusterID == clusterID {
+			rules := make([]models.ForwardRule, 0, len(result[i].Rules)+1)
+			rules = append(rules, result[i].Rules...)
+			result[i].Rules = append(rules, rule)
+		}
+	}
+	return result
+}
+
+// findCycles follows FQDN rules for name from every cluster and returns the
+// distinct cycles found, each rotated to start at its smallest cluster ID
+func findCycles(nodes []Node, owners map[string]string, name string) [][]string {
+	byID := make(map[string]*Node, len(nodes))
+	for i := range nodes {
+		byID[nodes[i].ClusterID] = &nodes[i]
+	}
+
+	seen := make(map[string]bool)
+	cycles := make([][]string, 0)
+
+	for _, start := range nodes {
+		rule := matchRule(start.Rules, name)
+		if rule == nil {
+			continue
+		}
+
+		path := []string{start.ClusterID}
+		index := map[string]int{start.ClusterID: 0}
+		for rule != nil {
+			next, ok := owners[rule.TargetIP]
+			if !ok {
+				break // dead-end
+			}
+			if i, visited := index[next]; visited {
+				cycle := canonicalCycle(path[i:])
+				key := strings.Join(cycle, ",")
+				if !seen[key] {
+					seen[key] = true
+					cycles = append(cycles, cycle)
+				}
+				break
+			}
+			index[next] = len(path)
+			path = append(path, next)
+			rule = matchRule(byID[next].Rules, name)
+		}
+	}
+
+	return cycles
+}
+
+// matchRule returns the most specific FQDN rule of a cluster that handles a
+// forwarded query for name. A namespace rule also handles the services in
+// that namespace.
+func matchRule(rules []models.ForwardRule, name string) *models.ForwardRule {
+	namespace := name
+	if i := strings.Index(name, "."); i >= 0 {
+		namespace = name[i+1:]
+	}
+
+	var fallback *models.ForwardRule
+	for i := range rules {
+		r := &rules[i]
+		if !r.IsFullFQDN {
+			continue
+		}
+		if r.GetFullName() == name {
+			return r
+		}
+		if r.ServiceName == "" && r.Namespace == namespace && fallback == nil {
+			fallback = r
+		}
+	}
+	return fallback
+}
+
+// canonicalCycle rotates a cycle so it starts at its smallest element
+func canonicalCycle(cycle []string) []string {
+	min := 0
+	for i := range cycle {
+		if cycle[i] < cycle[min] {
+			min = i
+		}
+	}
+	result := make([]string, 0, len(cycle))
+	result = append(result, cycle[min:]...)
+	return append(result, cycle[:min]...)
+}
@@ -0,0 +1,385 @@
+package topology
+
+import (
+	"reflect"
+	"testing"
+
+	"coredns-multi-configuration/pkg/models"
+)
+
+// DNS addresses of the test clusters
+const (
+	dnsA = "10.0.0.10"
+	dnsB = "10.1.0.10"
+	dnsC = "10.2.0.10"
+)
+
+func fqdnRule(name, target string) models.ForwardRule {
+	serviceName, namespace, _ := models.ParseNameInput(name)
+	return models.ForwardRule{Namespace: namespace, ServiceName: serviceName, TargetIP: target, IsFullFQDN: true}
+}
+
+func shortRule(name, target string) models.ForwardRule {
+	serviceName, namespace, _ := models.ParseNameInput(name)
+	return models.ForwardRule{Namespace: namespace, ServiceName: serviceName, TargetIP: target}
+}
+
+// testNodes returns clusters a, b and c with the given rules
+func testNodes(a, b, c []models.ForwardRule) []Node {
+	return []Node{
+		{ClusterID: "a", ClusterName: "prod-a", Addresses: []string{dnsA}, Rules: a},
+		{ClusterID: "b", ClusterName: "prod-b", Addresses: []string{dnsB}, Rules: b},
+		{ClusterID: "c", ClusterName: "prod-c", Addresses: []string{dnsC}, Rules: c},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodes      []Node
+		wantCycles map[string][][]string // per name, only names with cycles
+		wantDead   int
+	}{
+		{
+			name:  "no rules",
+			nodes: testNodes(nil, nil, nil),
+		},
+		{
+			name: "one way forward",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				nil, nil),
+		},
+		{
+			name: "FQDN loop between two clusters",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", dnsA)},
+				nil),
+			wantCycles: map[string][][]string{"payments": {{"a", "b"}}},
+		},
+		{
+			name: "FQDN loop across three clusters",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", dnsC)},
+				[]models.ForwardRule{fqdnRule("payments", dnsA)}),
+			wantCycles: map[string][][]string{"payments": {{"a", "b", "c"}}},
+		},
+		{
+			name: "short rule leading into a loop",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", dnsA)},
+				[]models.ForwardRule{shortRule("payments", dnsA)}),
+			wantCycles: map[string][][]string{"payments": {{"a", "b"}}},
+		},
+		{
+			name: "short rules both ways are not a loop",
+			nodes: testNodes(
+				[]models.ForwardRule{shortRule("payments", dnsB)},
+				[]models.ForwardRule{shortRule("payments", dnsA)},
+				nil),
+		},
+		{
+			name: "namespace rule forwards service queries back",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("mysql.payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", dnsA)},
+				nil),
+			wantCycles: map[string][][]string{"mysql.payments": {{"a", "b"}}},
+		},
+		{
+			name: "different names don't form a loop",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("orders", dnsA)},
+				nil),
+		},
+		{
+			name: "dead-end",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", "192.168.1.1")},
+				nil, nil),
+			wantDead: 1,
+		},
+		{
+			name: "chain ending in a dead-end",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", "192.168.1.1")},
+				nil),
+			wantDead: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Analyze(tt.nodes)
+
+			total := 0
+			for _, g := range report.Names {
+				want := tt.wantCycles[g.Name]
+				if want == nil {
+					want = [][]string{}
+				}
+				if !reflect.DeepEqual(g.Cycles, want) {
+					t.Errorf("cycles of %s = %v, want %v", g.Name, g.Cycles, want)
+				}
+				total += len(want)
+			}
+			if report.Cycles != total {
+				t.Errorf("Cycles = %d, want %d", report.Cycles, total)
+			}
+			if report.DeadEnds != tt.wantDead {
+				t.Errorf("DeadEnds = %d, want %d", report.DeadEnds, tt.wantDead)
+			}
+		})
+	}
+}
+
+func TestAnalyzeEdges(t *testing.T) {
+	report := Analyze(testNodes(
+		[]models.ForwardRule{fqdnRule("payments", dnsB)},
+		[]models.ForwardRule{shortRule("payments", "192.168.1.1")},
+		nil))
+
+	if len(report.Names) != 1 {
+		t.Fatalf("Names = %+v, want one graph", report.Names)
+	}
+	want := []Edge{
+		{From: "a", To: "b", TargetIP: dnsB, IsFullFQDN: true},
+		{From: "b", TargetIP: "192.168.1.1", DeadEnd: true},
+	}
+	if !reflect.DeepEqual(report.Names[0].Edges, want) {
+		t.Errorf("Edges = %+v, want %+v", report.Names[0].Edges, want)
+	}
+}
+
+func TestAnalyzeUnknownClusters(t *testing.T) {
+	// b could not be read but its address is cached, c is not known at all
+	nodes := testNodes(
+		[]models.ForwardRule{fqdnRule("payments", dnsB), fqdnRule("orders", dnsC)},
+		nil, nil)
+	nodes[1].Unknown = true
+	nodes[2].Unknown = true
+	nodes[2].Addresses = nil
+
+	report := Analyze(nodes)
+	want := map[string][]Edge{
+		"orders":   {{From: "a", TargetIP: dnsC, IsFullFQDN: true, Unverified: true}},
+		"payments": {{From: "a", To: "b", TargetIP: dnsB, IsFullFQDN: true, Unverified: true}},
+	}
+	for _, g := range report.Names {
+		if !reflect.DeepEqual(g.Edges, want[g.Name]) {
+			t.Errorf("Edges of %s = %+v, want %+v", g.Name, g.Edges, want[g.Name])
+		}
+	}
+	if report.DeadEnds != 0 || report.Unverified != 2 {
+		t.Errorf("DeadEnds, Unverified = %d, %d; want 0, 2", report.DeadEnds, report.Unverified)
+	}
+
+	// Once c's address is known, the unowned target is a dead-end again
+	nodes[2].Addresses = []string{dnsC}
+	nodes[0].Rules = []models.ForwardRule{fqdnRule("orders", "192.168.1.1")}
+	report = Analyze(nodes)
+	if report.DeadEnds != 1 || report.Unverified != 0 {
+		t.Errorf("DeadEnds, Unverified = %d, %d; want 1, 0", report.DeadEnds, report.Unverified)
+	}
+}
+
+func TestWouldCreateCycleUnknownClusters(t *testing.T) {
+	tests := []struct {
+		name      string
+		unknown   string // cluster that could not be read
+		cached    bool   // whether its address is known from the cache
+		clusterID string
+		rule      models.ForwardRule
+		want      []string
+	}{
+		{
+			name:      "into an unknown cluster",
+			unknown:   "c",
+			cached:    true,
+			clusterID: "a",
+			rule:      fqdnRule("payments", dnsC),
+			want:      []string{"prod-c"},
+		},
+		{
+			name:      "through a known cluster into an unknown one",
+			unknown:   "c",
+			cached:    true,
+			clusterID: "b",
+			rule:      fqdnRule("payments", dnsA),
+			want:      []string{"prod-c"},
+		},
+		{
+			name:      "unowned target while an address is unknown",
+			unknown:   "c",
+			clusterID: "a",
+			rule:      fqdnRule("orders", dnsC),
+			want:      []string{"prod-c"},
+		},
+		{
+			name:      "unowned target with every address known",
+			unknown:   "c",
+			cached:    true,
+			clusterID: "a",
+			rule:      fqdnRule("orders", "192.168.1.1"),
+		},
+		{
+			name:      "path ends at a known cluster",
+			unknown:   "c",
+			cached:    true,
+			clusterID: "a",
+			rule:      fqdnRule("orders", dnsB),
+		},
+		{
+			name:      "short rules are not forwarded again",
+			unknown:   "c",
+			cached:    true,
+			clusterID: "a",
+			rule:      shortRule("orders", dnsC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a forwards payments to c
+			nodes := testNodes([]models.ForwardRule{fqdnRule("payments", dnsC)}, nil, nil)
+			for i := range nodes {
+				if nodes[i].ClusterID == tt.unknown {
+					nodes[i].Unknown = true
+					if !tt.cached {
+						nodes[i].Addresses = nil
+					}
+				}
+			}
+
+			loop, _, unverified := WouldCreateCycle(nodes, tt.clusterID, tt.rule)
+			if loop {
+				t.Fatal("WouldCreateCycle() reported a loop")
+			}
+			if !reflect.DeepEqual(unverified, tt.want) {
+				t.Errorf("WouldCreateCycle() unverified = %v, want %v", unverified, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	nodes := testNodes(
+		[]models.ForwardRule{fqdnRule("payments", dnsC)},
+		[]models.ForwardRule{fqdnRule("payments", dnsA)},
+		[]models.ForwardRule{fqdnRule("payments", dnsB)})
+	owners := map[string]string{dnsA: "a", dnsB: "b", dnsC: "c"}
+
+	// Found from every start, reported once and rotated to the smallest ID
+	got := findCycles(nodes, owners, "payments")
+	want := [][]string{{"a", "c", "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findCycles() = %v, want %v", got, want)
+	}
+
+	if got := findCycles(nodes, owners, "orders"); len(got) != 0 {
+		t.Errorf("findCycles() for an unforwarded name = %v, want none", got)
+	}
+}
+
+func TestWouldCreateCycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []Node
+		clusterID string
+		rule      models.ForwardRule
+		wantLoop  bool
+		wantDesc  string
+	}{
+		{
+			name: "closes an FQDN loop",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				nil, nil),
+			clusterID: "b",
+			rule:      fqdnRule("payments", dnsA),
+			wantLoop:  true,
+			wantDesc:  "forwarding loop for payments: prod-a -> prod-b -> prod-a",
+		},
+		{
+			name: "short rule back is not a loop",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				nil, nil),
+			clusterID: "b",
+			rule:      shortRule("payments", dnsA),
+		},
+		{
+			name: "short rule into an existing loop",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				[]models.ForwardRule{fqdnRule("payments", dnsA)},
+				nil),
+			clusterID: "c",
+			rule:      shortRule("payments", dnsA),
+		},
+		{
+			name: "dead-end",
+			nodes: testNodes(
+				[]models.ForwardRule{fqdnRule("payments", dnsB)},
+				nil, nil),
+			clusterID: "b",
+			rule:      fqdnRule("payments", "192.168.1.1"),
+		},
+		{
+			name:      "forward to itself",
+			nodes:     testNodes(nil, nil, nil),
+			clusterID: "a",
+			rule:      fqdnRule("payments", dnsA),
+			wantLoop:  true,
+			wantDesc:  "forwarding loop for payments: prod-a -> prod-a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop, desc, unverified := WouldCreateCycle(tt.nodes, tt.clusterID, tt.rule)
+			if loop != tt.wantLoop || desc != tt.wantDesc {
+				t.Errorf("WouldCreateCycle() = %v, %q; want %v, %q", loop, desc, tt.wantLoop, tt.wantDesc)
+			}
+			if len(unverified) != 0 {
+				t.Errorf("WouldCreateCycle() unverified = %v with every cluster known", unverified)
+			}
+		})
+	}
+
+	// The nodes passed in must not be modified
+	nodes := testNodes(nil, nil, nil)
+	WouldCreateCycle(nodes, "a", fqdnRule("payments", dnsB))
+	if len(nodes[0].Rules) != 0 {
+		t.Errorf("WouldCreateCycle() modified the input rules: %+v", nodes[0].Rules)
+	}
+}
+
+// TestBulkAddDetectsLoopThroughEarlierClusters mirrors a bulk add that applies
+// each added rule to nodes collected once
+func TestBulkAddDetectsLoopThroughEarlierClusters(t *testing.T) {
+	nodes := testNodes(nil, nil, nil)
+	original := nodes
+
+	// payments on a -> b, then b -> a closes the loop
+	rule := fqdnRule("payments", dnsB)
+	if loop, _, _ := WouldCreateCycle(nodes, "a", rule); loop {
+		t.Fatal("first rule reported as a loop")
+	}
+	nodes = WithRule(nodes, "a", rule)
+
+	if loop, description, _ := WouldCreateCycle(nodes, "b", fqdnRule("payments", dnsA)); !loop {
+		t.Error("second rule not reported as a loop through the first one")
+	} else if description != "forwarding loop for payments: prod-a -> prod-b -> prod-a" {
+		t.Errorf("description = %q", description)
+	}
+
+	if len(original[0].Rules) != 0 {
+		t.Errorf("WithRule() modified the original nodes: %+v", original[0].Rules)
+	}
+}
@@ -41,7 +41,7 @@ func DashboardPage() templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"header\"><div class=\"logo\">🌐 CoreDNS Manager</div><div style=\"display: flex; gap: 1rem; align-items: center;\"><button class=\"btn btn-primary\" onclick=\"showAddClusterModal()\">➕ 添加集群</button> <a href=\"/logout\" class=\"btn btn-secondary\">退出登录</a></div></div><div class=\"container\"><h2 style=\"margin-bottom: 1.5rem;\">集群列表</h2><div id=\"clusters-container\" class=\"grid grid-cols-2\"><div style=\"text-align: center; padding: 3rem; color: var(--text-secondary);\"><span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span><p style=\"margin-top: 1rem;\">加载集群列表...</p></div></div></div><!-- Add Cluster Modal --> <div id=\"add-cluster-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\"><div class=\"modal-header\"><h3 class=\"modal-title\">添加新集群</h3><button class=\"close-btn\" onclick=\"hideAddClusterModal()\">&times;</button></div><div id=\"add-cluster-error\"></div><form id=\"add-cluster-form\" onsubmit=\"handleAddCluster(event)\"><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-name\">集群名称</label> <input type=\"text\" id=\"cluster-name\" class=\"form-input\" required placeholder=\"例如: production-cluster\"></div><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-kubeconfig\">Kubeconfig</label> <textarea id=\"cluster-kubeconfig\" class=\"form-textarea\" required placeholder=\"粘贴 kubeconfig 内容...\"></textarea></div><div style=\"display: flex; gap: 1rem; justify-content: flex-end;\"><button type=\"button\" class=\"btn btn-secondary\" onclick=\"hideAddClusterModal()\">取消</button> <button type=\"submit\" class=\"btn btn-primary\" id=\"add-cluster-btn\"><span id=\"add-cluster-text\">添加集群</span> <span id=\"add-cluster-loading\" class=\"loading\" style=\"display: none;\"></span></button></div></form></div></div><!-- CoreDNS Config Modal --> <div id=\"coredns-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 900px;\"><div class=\"modal-header\"><h3 class=\"modal-title\" id=\"coredns-modal-title\">CoreDNS 配置</h3><button class=\"close-btn\" onclick=\"hideCoreDNSModal()\">&times;</button></div><div id=\"coredns-content\"><div style=\"text-align: center; padding: 2rem;\"><span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span></div></div></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"header\"><div class=\"logo\">🌐 CoreDNS Manager</div><div style=\"display: flex; gap: 1rem; align-items: center;\"><button class=\"btn btn-secondary\" onclick=\"showTopologyModal()\">🕸️ 转发拓扑</button> <button class=\"btn btn-secondary\" onclick=\"showBulkModal()\">📦 批量规则</button> <button class=\"btn btn-secondary\" onclick=\"showImportModal()\">📥 导入 kubeconfig</button> <button class=\"btn btn-primary\" onclick=\"showAddClusterModal()\">➕ 添加集群</button> <a href=\"/logout\" class=\"btn btn-secondary\">退出登录</a></div></div><div class=\"container\"><div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1.5rem;\"><h2>集群列表</h2><form style=\"display: flex; gap: 0.5rem;\" onsubmit=\"event.preventDefault(); loadClusters();\"><input type=\"text\" id=\"cluster-selector\" class=\"form-input\" style=\"width: 280px;\" placeholder=\"标签筛选，例如: env=staging\"> <button type=\"submit\" class=\"btn btn-secondary\">筛选</button></form></div><div id=\"clusters-container\" class=\"grid grid-cols-2\"><div style=\"text-align: center; padding: 3rem; color: var(--text-secondary);\"><span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span><p style=\"margin-top: 1rem;\">加载集群列表...</p></div></div></div><!-- Add Cluster Modal --> <div id=\"add-cluster-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\"><div class=\"modal-header\"><h3 class=\"modal-title\" id=\"add-cluster-title\">添加新集群</h3><button class=\"close-btn\" onclick=\"hideAddClusterModal()\">&times;</button></div><div id=\"add-cluster-error\"></div><form id=\"add-cluster-form\" onsubmit=\"handleAddCluster(event)\"><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-name\">集群名称</label> <input type=\"text\" id=\"cluster-name\" class=\"form-input\" required placeholder=\"例如: production-cluster\"></div><div style=\"display: flex; gap: 1rem;\"><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"cluster-group\">分组</label> <input type=\"text\" id=\"cluster-group\" class=\"form-input\" placeholder=\"例如: staging\"></div><div class=\"form-group\" style=\"flex: 2;\"><label class=\"form-label\" for=\"cluster-labels\">标签</label> <input type=\"text\" id=\"cluster-labels\" class=\"form-input\" placeholder=\"例如: env=staging,region=eu-west\"></div></div><div id=\"credential-section\"><p id=\"credential-keep-hint\" style=\"display: none; color: var(--text-secondary); font-size: 0.85rem; margin-bottom: 0.5rem;\">凭据留空则保持不变；填写新凭据时会先测试连接再替换</p><div class=\"form-group\"><label class=\"form-label\">凭据类型</label><div style=\"display: flex; gap: 1.5rem; font-size: 0.9rem;\"><label><input type=\"radio\" name=\"cluster-credential\" value=\"kubeconfig\" checked onchange=\"toggleCredentialType()\"> Kubeconfig</label> <label><input type=\"radio\" name=\"cluster-credential\" value=\"token\" onchange=\"toggleCredentialType()\"> Token + CA 证书</label></div></div><div class=\"form-group\" id=\"credential-kubeconfig\"><label class=\"form-label\" for=\"cluster-kubeconfig\">Kubeconfig</label> <textarea id=\"cluster-kubeconfig\" class=\"form-textarea\" placeholder=\"粘贴 kubeconfig 内容...\"></textarea><p style=\"color: var(--text-secondary); font-size: 0.85rem; margin-top: 0.25rem;\">不支持依赖 exec / auth-provider 插件（aws、gke-gcloud-auth-plugin、kubelogin 等）的 kubeconfig，请改用 Token + CA 证书</p></div><div id=\"credential-token\" style=\"display: none;\"><p style=\"font-size: 0.85rem; margin-bottom: 0.75rem;\">还没有 Token？<a href=\"#\" onclick=\"event.preventDefault(); hideAddClusterModal(); showOnboardingModal();\">生成最小权限 ServiceAccount 清单</a></p><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-server\">API Server 地址</label> <input type=\"text\" id=\"cluster-server\" class=\"form-input\" placeholder=\"例如: https://10.0.0.1:6443\"></div><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-token\">Bearer Token</label> <input type=\"password\" id=\"cluster-token\" class=\"form-input\" placeholder=\"ServiceAccount Token\"></div><div class=\"form-group\"><label class=\"form-label\" for=\"cluster-ca\">CA 证书</label> <textarea id=\"cluster-ca\" class=\"form-textarea\" style=\"min-height: 120px;\" placeholder=\"-----BEGIN CERTIFICATE----- ...（PEM 或 base64，留空则使用系统根证书）\"></textarea></div></div></div><div class=\"form-group\" id=\"auto-restart-group\"><label style=\"display: flex; gap: 0.5rem; align-items: center; font-size: 0.9rem;\"><input type=\"checkbox\" id=\"cluster-auto-restart\"> 未启用 reload 插件时，修改 Corefile 后自动滚动重启 CoreDNS</label></div><div style=\"display: flex; gap: 1rem; justify-content: flex-end;\"><button type=\"button\" class=\"btn btn-secondary\" onclick=\"hideAddClusterModal()\">取消</button> <button type=\"submit\" class=\"btn btn-primary\" id=\"add-cluster-btn\"><span id=\"add-cluster-text\">添加集群</span> <span id=\"add-cluster-loading\" class=\"loading\" style=\"display: none;\"></span></button></div></form></div></div><!-- Onboarding Modal --> <div id=\"onboarding-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 800px;\"><div class=\"modal-header\"><h3 class=\"modal-title\">最小权限接入</h3><button class=\"close-btn\" onclick=\"hideOnboardingModal()\">&times;</button></div><h4 style=\"margin-bottom: 0.5rem;\">1. 在目标集群中创建 ServiceAccount 和 RBAC</h4><div style=\"display: flex; gap: 0.5rem; margin-bottom: 0.5rem;\"><input type=\"text\" id=\"onboarding-sa\" class=\"form-input\" style=\"flex: 1;\" value=\"coredns-manager\" placeholder=\"ServiceAccount 名称\"> <button class=\"btn btn-secondary\" onclick=\"loadOnboardingManifests()\">生成清单</button></div><textarea id=\"onboarding-manifests\" class=\"form-textarea\" style=\"min-height: 240px; font-size: 0.8rem;\" readonly></textarea><p style=\"font-size: 0.85rem; color: var(--text-secondary); margin: 0.5rem 0 1rem;\">保存为 coredns-manager.yaml 后执行 <code>kubectl apply -f coredns-manager.yaml</code>， 再执行 <code id=\"onboarding-secret-cmd\"></code> 获取 Token Secret</p><h4 style=\"margin-bottom: 0.5rem;\">2. 使用 Token 注册集群</h4><div id=\"onboarding-error\"></div><div style=\"display: flex; gap: 1rem;\"><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"onboarding-name\">集群名称</label> <input type=\"text\" id=\"onboarding-name\" class=\"form-input\" placeholder=\"例如: production-cluster\"></div><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"onboarding-server\">API Server 地址</label> <input type=\"text\" id=\"onboarding-server\" class=\"form-input\" placeholder=\"例如: https://10.0.0.1:6443\"></div></div><div class=\"form-group\"><label class=\"form-label\" for=\"onboarding-secret\">Token Secret (YAML)</label> <textarea id=\"onboarding-secret\" class=\"form-textarea\" style=\"min-height: 160px;\" placeholder=\"粘贴 kubectl get secret -o yaml 的输出，Token 和 CA 证书会自动提取\"></textarea></div><div style=\"display: flex; justify-content: flex-end;\"><button class=\"btn btn-primary\" onclick=\"registerOnboardedCluster()\">注册集群</button></div></div></div><!-- Import Kubeconfig Modal --> <div id=\"import-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 700px;\"><div class=\"modal-header\"><h3 class=\"modal-title\">从 kubeconfig 导入集群</h3><button class=\"close-btn\" onclick=\"hideImportModal()\">&times;</button></div><div class=\"form-group\"><label class=\"form-label\" for=\"import-kubeconfig\">Kubeconfig</label> <textarea id=\"import-kubeconfig\" class=\"form-textarea\" placeholder=\"粘贴包含多个上下文的 kubeconfig 内容...\"></textarea></div><div style=\"display: flex; justify-content: flex-end; margin-bottom: 1rem;\"><button class=\"btn btn-secondary\" onclick=\"parseImportContexts()\">解析上下文</button></div><div id=\"import-contexts\"></div><div id=\"import-options\" style=\"display: none;\"><div style=\"display: flex; gap: 1rem;\"><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"import-group\">分组</label> <input type=\"text\" id=\"import-group\" class=\"form-input\" placeholder=\"例如: staging\"></div><div class=\"form-group\" style=\"flex: 2;\"><label class=\"form-label\" for=\"import-labels\">标签</label> <input type=\"text\" id=\"import-labels\" class=\"form-input\" placeholder=\"例如: env=staging,region=eu-west\"></div></div><div style=\"display: flex; justify-content: flex-end;\"><button class=\"btn btn-primary\" onclick=\"importContexts()\">导入所选</button></div></div><div id=\"import-results\" style=\"margin-top: 1rem;\"></div></div></div><!-- Bulk Rules Modal --> <div id=\"bulk-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 700px;\"><div class=\"modal-header\"><h3 class=\"modal-title\">批量转发规则</h3><button class=\"close-btn\" onclick=\"hideBulkModal()\">&times;</button></div><div class=\"form-group\"><label class=\"form-label\" for=\"bulk-selector\">集群选择器</label> <input type=\"text\" id=\"bulk-selector\" class=\"form-input\" placeholder=\"例如: env=staging 或 group=staging,region=eu-west\"></div><div style=\"display: flex; gap: 1rem;\"><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"bulk-namespace\">名称</label> <input type=\"text\" id=\"bulk-namespace\" class=\"form-input\" placeholder=\"prod / mysql.tidb-cluster\"></div><div class=\"form-group\" style=\"flex: 1;\"><label class=\"form-label\" for=\"bulk-target-ip\">目标 DNS IP (添加时)</label> <input type=\"text\" id=\"bulk-target-ip\" class=\"form-input\" placeholder=\"例如: 10.96.0.10\"></div></div><div class=\"form-group\"><label><input type=\"checkbox\" id=\"bulk-stop-on-failure\"> 遇到第一个失败时停止</label></div><div style=\"display: flex; gap: 1rem; justify-content: flex-end;\"><button class=\"btn btn-danger\" onclick=\"runBulk('delete')\">批量删除</button> <button class=\"btn btn-primary\" onclick=\"runBulk('add')\">批量添加</button></div><div id=\"bulk-results\" style=\"margin-top: 1rem;\"></div></div></div><!-- Topology Modal --> <div id=\"topology-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 900px;\"><div class=\"modal-header\"><h3 class=\"modal-title\">跨集群转发拓扑</h3><button class=\"close-btn\" onclick=\"hideTopologyModal()\">&times;</button></div><div id=\"topology-content\"></div></div></div><!-- CoreDNS Config Modal --> <div id=\"coredns-modal\" class=\"modal\" style=\"display: none;\"><div class=\"modal-content\" style=\"max-width: 900px;\"><div class=\"modal-header\"><h3 class=\"modal-title\" id=\"coredns-modal-title\">CoreDNS 配置</h3><button class=\"close-btn\" onclick=\"hideCoreDNSModal()\">&times;</button></div><div id=\"coredns-content\"><div style=\"text-align: center; padding: 2rem;\"><span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span></div></div></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
@@ -80,7 +80,7 @@ func DashboardScript() templ.Component {
 			templ_7745c5c3_Var3 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<script>\n\t\tlet currentClusterId = null;\n\t\t\n\t\tdocument.addEventListener('DOMContentLoaded', loadClusters);\n\t\t\n\t\tasync function loadClusters() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters');\n\t\t\t\tif (!response.ok) throw new Error('Failed to load clusters');\n\t\t\t\tconst clusters = await response.json();\n\t\t\t\trenderClusters(clusters);\n\t\t\t} catch (error) {\n\t\t\t\tdocument.getElementById('clusters-container').innerHTML = \n\t\t\t\t\t'<div class=\"alert alert-error\">加载集群列表失败: ' + error.message + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderClusters(clusters) {\n\t\t\tconst container = document.getElementById('clusters-container');\n\t\t\t\n\t\t\tif (clusters.length === 0) {\n\t\t\t\tcontainer.innerHTML = '<div style=\"text-align: center; padding: 3rem; color: var(--text-secondary); grid-column: 1/-1;\">' +\n\t\t\t\t\t'<p style=\"font-size: 3rem; margin-bottom: 1rem;\">📭</p>' +\n\t\t\t\t\t'<p>暂无集群，点击上方按钮添加</p></div>';\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\tlet html = '';\n\t\t\tfor (let i = 0; i < clusters.length; i++) {\n\t\t\t\tconst cluster = clusters[i];\n\t\t\t\tconst statusClass = cluster.connected ? 'badge-success' : 'badge-danger';\n\t\t\t\tconst statusText = cluster.connected ? '✓ 已连接' : '✗ 未连接';\n\t\t\t\tconst errorHtml = cluster.error ? '<p style=\"color: var(--danger);\">错误: ' + cluster.error + '</p>' : '';\n\t\t\t\t\n\t\t\t\thtml += '<div class=\"card cluster-card\" onclick=\"showCoreDNSConfig(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">' +\n\t\t\t\t\t'<div class=\"cluster-header\">' +\n\t\t\t\t\t'<span class=\"cluster-name\">' + cluster.name + '</span>' +\n\t\t\t\t\t'<span class=\"badge ' + statusClass + '\">' + statusText + '</span>' +\n\t\t\t\t\t'</div>' +\n\t\t\t\t\t'<div class=\"cluster-info\">' +\n\t\t\t\t\t'<p>ID: ' + cluster.id.substring(0, 8) + '...</p>' +\n\t\t\t\t\t'<p>添加时间: ' + new Date(cluster.created_at).toLocaleString('zh-CN') + '</p>' +\n\t\t\t\t\terrorHtml +\n\t\t\t\t\t'</div>' +\n\t\t\t\t\t'<div style=\"margin-top: 1rem; display: flex; gap: 0.5rem;\">' +\n\t\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"event.stopPropagation(); showCoreDNSConfig(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">查看 CoreDNS</button>' +\n\t\t\t\t\t'<button class=\"btn btn-danger\" onclick=\"event.stopPropagation(); deleteCluster(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">删除</button>' +\n\t\t\t\t\t'</div></div>';\n\t\t\t}\n\t\t\tcontainer.innerHTML = html;\n\t\t}\n\t\t\n\t\tfunction showAddClusterModal() {\n\t\t\tdocument.getElementById('add-cluster-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('add-cluster-form').reset();\n\t\t\tdocument.getElementById('add-cluster-error').innerHTML = '';\n\t\t}\n\t\t\n\t\tfunction hideAddClusterModal() {\n\t\t\tdocument.getElementById('add-cluster-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function handleAddCluster(event) {\n\t\t\tevent.preventDefault();\n\t\t\t\n\t\t\tconst btn = document.getElementById('add-cluster-btn');\n\t\t\tconst text = document.getElementById('add-cluster-text');\n\t\t\tconst loading = document.getElementById('add-cluster-loading');\n\t\t\tconst errorDiv = document.getElementById('add-cluster-error');\n\t\t\t\n\t\t\tbtn.disabled = true;\n\t\t\ttext.style.display = 'none';\n\t\t\tloading.style.display = 'inline-block';\n\t\t\t\n\t\t\tconst name = document.getElementById('cluster-name').value;\n\t\t\tconst kubeconfig = document.getElementById('cluster-kubeconfig').value;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ name, kubeconfig }),\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tconst data = await response.json();\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\thideAddClusterModal();\n\t\t\t\t\tloadClusters();\n\t\t\t\t} else {\n\t\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">' + (data.error || '添加失败') + '</div>';\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t} finally {\n\t\t\t\tbtn.disabled = false;\n\t\t\t\ttext.style.display = 'inline';\n\t\t\t\tloading.style.display = 'none';\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function deleteCluster(id, name) {\n\t\t\tif (!confirm('确定要删除集群 \"' + name + '\" 吗？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + id, { method: 'DELETE' });\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tloadClusters();\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('删除失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function showCoreDNSConfig(clusterId, clusterName) {\n\t\t\tcurrentClusterId = clusterId;\n\t\t\tdocument.getElementById('coredns-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('coredns-modal-title').textContent = clusterName + ' - CoreDNS 配置';\n\t\t\tdocument.getElementById('coredns-content').innerHTML = \n\t\t\t\t'<div style=\"text-align: center; padding: 2rem;\">' +\n\t\t\t\t'<span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span>' +\n\t\t\t\t'<p style=\"margin-top: 1rem; color: var(--text-secondary);\">加载配置...</p></div>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + clusterId + '/coredns');\n\t\t\t\tif (!response.ok) throw new Error('Failed to load CoreDNS config');\n\t\t\t\tconst data = await response.json();\n\t\t\t\trenderCoreDNSConfig(data);\n\t\t\t} catch (error) {\n\t\t\t\tdocument.getElementById('coredns-content').innerHTML = \n\t\t\t\t\t'<div class=\"alert alert-error\">加载失败: ' + error.message + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction hideCoreDNSModal() {\n\t\t\tdocument.getElementById('coredns-modal').style.display = 'none';\n\t\t\tcurrentClusterId = null;\n\t\t}\n\t\t\n\t\tfunction renderCoreDNSConfig(data) {\n\t\t\tconst serviceName = (data.service && data.service.metadata && data.service.metadata.name) || 'kube-dns';\n\t\t\tconst configMapName = (data.configmap && data.configmap.metadata && data.configmap.metadata.name) || 'coredns';\n\t\t\tconst serviceIP = data.service_ip || 'N/A';\n\t\t\tconst corefile = data.corefile || '';\n\t\t\tconst rules = data.forward_rules || [];\n\t\t\t\n\t\t\tlet rulesHtml = '';\n\t\t\tif (rules.length === 0) {\n\t\t\t\trulesHtml = '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">暂无转发规则</p>';\n\t\t\t} else {\n\t\t\t\tfor (let i = 0; i < rules.length; i++) {\n\t\t\t\t\tconst rule = rules[i];\n\t\t\t\t\t// Build full name: service.namespace or just namespace\n\t\t\t\t\tconst fullName = rule.service_name ? rule.service_name + '.' + rule.namespace : rule.namespace;\n\t\t\t\t\t// Display domain: FQDN format shows .svc.cluster.local, short format shows just fullName\n\t\t\t\t\tconst displayDomain = rule.is_full_fqdn ? fullName + '.svc.cluster.local:53' : fullName + ':53';\n\t\t\t\t\trulesHtml += '<div class=\"rule-item\">' +\n\t\t\t\t\t\t'<div><span class=\"rule-domain\">' + displayDomain + '</span>' +\n\t\t\t\t\t\t'<span style=\"margin: 0 0.5rem;\">→</span>' +\n\t\t\t\t\t\t'<span class=\"rule-target\">' + rule.target_ip + '</span></div>' +\n\t\t\t\t\t\t'<button class=\"btn btn-danger\" style=\"padding: 0.5rem 1rem;\" onclick=\"deleteForwardRule(\\'' + fullName + '\\', ' + (rule.is_full_fqdn ? 'true' : 'false') + ')\">删除</button></div>';\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\tdocument.getElementById('coredns-content').innerHTML = \n\t\t\t\t'<div class=\"service-info\">' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">Service Name</div><div class=\"info-value\">' + serviceName + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">Cluster IP</div><div class=\"info-value\">' + serviceIP + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">ConfigMap</div><div class=\"info-value\">' + configMapName + '</div></div>' +\n\t\t\t\t'</div>' +\n\t\t\t\t'<div class=\"tabs\">' +\n\t\t\t\t'<button class=\"tab active\" onclick=\"switchTab(\\'rules\\', this)\">转发规则</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'corefile\\', this)\">Corefile</button>' +\n\t\t\t\t'</div>' +\n\t\t\t\t'<div id=\"tab-rules\">' +\n\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<h4>已配置的转发规则</h4>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"showAddRuleForm()\">➕ 添加规则</button></div>' +\n\t\t\t\t'<div id=\"add-rule-form\" style=\"display: none; margin-bottom: 1rem;\">' +\n\t\t\t'\t<div class=\"card\" style=\"padding: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 1rem; align-items: flex-end;\">' +\n\t\t\t\t'<div class=\"form-group\" style=\"flex: 1; margin-bottom: 0;\"><label class=\"form-label\">名称</label>' +\n\t\t\t\t'<input type=\"text\" id=\"rule-namespace\" class=\"form-input\" placeholder=\"prod / mysql.tidb-cluster / prod.svc.cluster.local\"/></div>' +\n\t\t\t\t'<div class=\"form-group\" style=\"flex: 1; margin-bottom: 0;\"><label class=\"form-label\">目标 DNS IP</label>' +\n\t\t\t\t'<input type=\"text\" id=\"rule-target-ip\" class=\"form-input\" placeholder=\"例如: 10.96.0.10\"/></div>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"addForwardRule()\">添加</button>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"hideAddRuleForm()\">取消</button></div>' +\n\t\t\t\t'<p style=\"font-size: 0.8rem; color: var(--text-secondary); margin-top: 0.5rem;\">短格式(prod/mysql.tidb-cluster)自动添加rewrite，完整格式(*.svc.cluster.local)只forward</p>' +\n\t\t\t\t'</div></div>' +\n\t\t\t\t'<div class=\"rules-list\" id=\"rules-list\">' + rulesHtml + '</div></div>' +\n\t\t\t\t'<div id=\"tab-corefile\" style=\"display: none;\">' +\n\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<h4>Corefile 内容</h4>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"saveCorefile()\" id=\"save-corefile-btn\">保存修改</button></div>' +\n\t\t\t\t'<textarea id=\"corefile-editor\" class=\"form-textarea\" style=\"min-height: 400px; font-size: 0.9rem;\">' + escapeHtml(corefile) + '</textarea></div>';\n\t\t}\n\t\t\n\t\tfunction escapeHtml(text) {\n\t\t\tconst div = document.createElement('div');\n\t\t\tdiv.textContent = text;\n\t\t\treturn div.innerHTML;\n\t\t}\n\t\t\n\t\tfunction switchTab(tabName, element) {\n\t\t\tdocument.querySelectorAll('.tab').forEach(function(t) { t.classList.remove('active'); });\n\t\t\telement.classList.add('active');\n\t\t\tdocument.getElementById('tab-rules').style.display = tabName === 'rules' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-corefile').style.display = tabName === 'corefile' ? 'block' : 'none';\n\t\t}\n\t\t\n\t\tfunction showAddRuleForm() {\n\t\t\tdocument.getElementById('add-rule-form').style.display = 'block';\n\t\t}\n\t\t\n\t\tfunction hideAddRuleForm() {\n\t\t\tdocument.getElementById('add-rule-form').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function addForwardRule() {\n\t\t\tconst namespace = document.getElementById('rule-namespace').value.trim();\n\t\t\tconst targetIP = document.getElementById('rule-target-ip').value.trim();\n\t\t\t\n\t\t\tif (!namespace || !targetIP) {\n\t\t\t\talert('请填写完整信息');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/rules', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ namespace: namespace, target_ip: targetIP }),\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tconst title = document.getElementById('coredns-modal-title').textContent;\n\t\t\t\t\tshowCoreDNSConfig(currentClusterId, title.split(' - ')[0]);\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('添加失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function deleteForwardRule(name, isFullFQDN) {\n\t\t\tconst displayName = isFullFQDN ? name + '.svc.cluster.local' : name;\n\t\t\tif (!confirm('确定要删除 ' + displayName + ' 的转发规则吗？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst encodedName = encodeURIComponent(name);\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/rules/' + encodedName + '?fqdn=' + isFullFQDN, {\n\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tconst title = document.getElementById('coredns-modal-title').textContent;\n\t\t\t\t\tshowCoreDNSConfig(currentClusterId, title.split(' - ')[0]);\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('删除失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function saveCorefile() {\n\t\t\tconst corefile = document.getElementById('corefile-editor').value;\n\t\t\tconst btn = document.getElementById('save-corefile-btn');\n\t\t\t\n\t\t\tbtn.disabled = true;\n\t\t\tbtn.textContent = '保存中...';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns', {\n\t\t\t\t\tmethod: 'PUT',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ corefile: corefile }),\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\talert('保存成功！CoreDNS 配置已更新。');\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('保存失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t} finally {\n\t\t\t\tbtn.disabled = false;\n\t\t\t\tbtn.textContent = '保存修改';\n\t\t\t}\n\t\t}\n\t</script>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<script>\n\t\tlet currentClusterId = null;\n\t\tlet changeTimer = null;\n\t\tlet restartTimer = null;\n\t\tlet editingClusterId = null;\n\t\tlet clustersById = {};\n\t\tlet logSource = null;\n\t\tlet queryLogTimer = null;\n\t\t\n\t\tdocument.addEventListener('DOMContentLoaded', loadClusters);\n\t\t\n\t\tasync function loadClusters() {\n\t\t\ttry {\n\t\t\t\tconst selector = document.getElementById('cluster-selector').value.trim();\n\t\t\t\tconst url = selector ? '/api/clusters?selector=' + encodeURIComponent(selector) : '/api/clusters';\n\t\t\t\tconst response = await fetch(url);\n\t\t\t\tif (response.status === 400) {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tthrow new Error(data.error);\n\t\t\t\t}\n\t\t\t\tif (!response.ok) throw new Error('Failed to load clusters');\n\t\t\t\tconst clusters = await response.json();\n\t\t\t\trenderClusters(clusters);\n\t\t\t\tloadShadowReports();\n\t\t\t\tloadDriftReports();\n\t\t\t} catch (error) {\n\t\t\t\tdocument.getElementById('clusters-container').innerHTML = \n\t\t\t\t\t'<div class=\"alert alert-error\">加载集群列表失败: ' + error.message + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function loadShadowReports() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/audit/shadowing');\n\t\t\t\tif (!response.ok) return;\n\t\t\t\tconst reports = await response.json();\n\t\t\t\tfor (let i = 0; i < reports.length; i++) {\n\t\t\t\t\tconst report = reports[i];\n\t\t\t\t\tconst el = document.getElementById('shadow-' + report.cluster_id);\n\t\t\t\t\tif (!el || !report.findings || report.findings.length === 0) continue;\n\t\t\t\t\tconst messages = report.findings.map(function(f) { return f.message; }).join('\\n');\n\t\t\t\t\tel.innerHTML = '<span class=\"badge badge-danger\" title=\"' + escapeHtml(messages) + '\">⚠ 遮蔽 ' + report.findings.length + '</span>';\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\t// Audit badges are optional\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function loadDriftReports() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/audit/drift');\n\t\t\t\tif (!response.ok) return;\n\t\t\t\tconst reports = await response.json();\n\t\t\t\tfor (let i = 0; i < reports.length; i++) {\n\t\t\t\t\tconst report = reports[i];\n\t\t\t\t\tconst el = document.getElementById('drift-' + report.cluster_id);\n\t\t\t\t\tif (!el || report.in_sync || !report.items) continue;\n\t\t\t\t\tconst messages = report.items.map(function(item) { return item.message; }).join('\\n');\n\t\t\t\t\tel.innerHTML = '<span class=\"badge badge-danger\" style=\"cursor: pointer;\" title=\"' + escapeHtml(messages) + '\" ' +\n\t\t\t\t\t\t'onclick=\"event.stopPropagation(); remediateDrift(\\'' + report.cluster_id + '\\', \\'' + report.cluster_name + '\\')\">⚠ 漂移 ' + report.items.length + '</span>';\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\t// Audit badges are optional\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function remediateDrift(id, name) {\n\t\t\tif (!confirm('将集群 \"' + name + '\" 中缺失或被修改的转发规则恢复为期望状态？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + id + '/drift/remediate', { method: 'POST' });\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tloadClusters();\n\t\t\t\t} else {\n\t\t\t\t\talert('修复失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderClusters(clusters) {\n\t\t\tconst container = document.getElementById('clusters-container');\n\t\t\t\n\t\t\tif (clusters.length === 0) {\n\t\t\t\tcontainer.innerHTML = '<div style=\"text-align: center; padding: 3rem; color: var(--text-secondary); grid-column: 1/-1;\">' +\n\t\t\t\t\t'<p style=\"font-size: 3rem; margin-bottom: 1rem;\">📭</p>' +\n\t\t\t\t\t'<p>暂无集群，点击上方按钮添加</p></div>';\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\tlet html = '';\n\t\t\tlet pending = false;\n\t\t\tfor (let i = 0; i < clusters.length; i++) {\n\t\t\t\tconst cluster = clusters[i];\n\t\t\t\tclustersById[cluster.id] = cluster;\n\t\t\t\tconst checked = !!cluster.checked_at;\n\t\t\t\tconst statusClass = !checked ? 'badge-info' : cluster.connected ? 'badge-success' : 'badge-danger';\n\t\t\t\tconst statusText = !checked ? '… 检测中' : cluster.connected ? '✓ 已连接' : '✗ 未连接';\n\t\t\t\tconst errorHtml = cluster.error ? '<p style=\"color: var(--danger);\">错误: ' + cluster.error + '</p>' : '';\n\t\t\t\tconst healthHtml = checked ?\n\t\t\t\t\t'<p>版本: ' + escapeHtml(cluster.version || '-') + (cluster.connected ? '，延迟 ' + cluster.latency_ms + 'ms' : '') + '</p>' +\n\t\t\t\t\t(cluster.last_success ? '<p>最近成功: ' + new Date(cluster.last_success).toLocaleString('zh-CN') + '</p>' : '') : '';\n\t\t\t\tif (!checked) pending = true;\n\t\t\t\tlet labelsHtml = cluster.source === 'in-cluster' ? '<span class=\"badge badge-success\">集群内</span>' : '';\n\t\t\t\tif (cluster.capabilities && missingCapabilities(cluster.capabilities).length > 0) {\n\t\t\t\t\tlabelsHtml += '<span class=\"badge badge-warning\" title=\"缺少权限: ' + missingCapabilities(cluster.capabilities).join('、') + '\">⚠ 权限受限</span>';\n\t\t\t\t}\n\t\t\t\tlabelsHtml += cluster.group ? '<span class=\"badge badge-info\">group=' + escapeHtml(cluster.group) + '</span>' : '';\n\t\t\t\tfor (const key in (cluster.labels || {})) {\n\t\t\t\t\tlabelsHtml += '<span class=\"badge badge-info\">' + escapeHtml(key + '=' + cluster.labels[key]) + '</span>';\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\thtml += '<div class=\"card cluster-card\" onclick=\"showCoreDNSConfig(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">' +\n\t\t\t\t\t'<div class=\"cluster-header\">' +\n\t\t\t\t\t'<span class=\"cluster-name\">' + cluster.name + '</span>' +\n\t\t\t\t\t'<span class=\"badge ' + statusClass + '\">' + statusText + '</span>' +\n\t\t\t\t\t'</div>' +\n\t\t\t\t\t'<div style=\"display: flex; gap: 0.5rem;\"><span id=\"shadow-' + cluster.id + '\"></span><span id=\"drift-' + cluster.id + '\"></span></div>' +\n\t\t\t\t\t(labelsHtml ? '<div style=\"display: flex; flex-wrap: wrap; gap: 0.25rem; margin-bottom: 0.5rem;\">' + labelsHtml + '</div>' : '') +\n\t\t\t\t\t'<div class=\"cluster-info\">' +\n\t\t\t\t\t'<p>ID: ' + cluster.id.substring(0, 8) + '...</p>' +\n\t\t\t\t\t'<p>添加时间: ' + new Date(cluster.created_at).toLocaleString('zh-CN') + '</p>' +\n\t\t\t\t\thealthHtml +\n\t\t\t\t\terrorHtml +\n\t\t\t\t\t'</div>' +\n\t\t\t\t\t'<div style=\"margin-top: 1rem; display: flex; gap: 0.5rem;\">' +\n\t\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"event.stopPropagation(); showCoreDNSConfig(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">查看 CoreDNS</button>' +\n\t\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"event.stopPropagation(); showEditClusterModal(\\'' + cluster.id + '\\')\">编辑</button>' +\n\t\t\t\t\t'<button class=\"btn btn-danger\" onclick=\"event.stopPropagation(); deleteCluster(\\'' + cluster.id + '\\', \\'' + cluster.name + '\\')\">删除</button>' +\n\t\t\t\t\t'</div></div>';\n\t\t\t}\n\t\t\tcontainer.innerHTML = html;\n\t\t\t\n\t\t\t// Newly added clusters get their first health check in the background\n\t\t\tif (pending) setTimeout(loadClusters, 3000);\n\t\t}\n\t\t\n\t\tfunction showAddClusterModal() {\n\t\t\teditingClusterId = null;\n\t\t\tdocument.getElementById('add-cluster-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('add-cluster-form').reset();\n\t\t\tdocument.getElementById('add-cluster-error').innerHTML = '';\n\t\t\tdocument.getElementById('add-cluster-title').textContent = '添加新集群';\n\t\t\tdocument.getElementById('add-cluster-text').textContent = '添加集群';\n\t\t\tdocument.getElementById('credential-keep-hint').style.display = 'none';\n\t\t\tdocument.getElementById('credential-section').style.display = 'block';\n\t\t\tdocument.getElementById('auto-restart-group').style.display = 'block';\n\t\t\ttoggleCredentialType();\n\t\t}\n\t\t\n\t\tfunction showEditClusterModal(id) {\n\t\t\tconst cluster = clustersById[id];\n\t\t\tshowAddClusterModal();\n\t\t\teditingClusterId = id;\n\t\t\tdocument.getElementById('add-cluster-title').textContent = '编辑集群';\n\t\t\tdocument.getElementById('add-cluster-text').textContent = '保存';\n\t\t\tdocument.getElementById('credential-keep-hint').style.display = 'block';\n\t\t\tdocument.getElementById('credential-section').style.display = cluster.source === 'in-cluster' ? 'none' : 'block';\n\t\t\tdocument.getElementById('auto-restart-group').style.display = 'none';\n\t\t\tdocument.getElementById('cluster-name').value = cluster.name;\n\t\t\tdocument.getElementById('cluster-group').value = cluster.group || '';\n\t\t\tdocument.getElementById('cluster-labels').value = Object.keys(cluster.labels || {}).map(function(k) { return k + '=' + cluster.labels[k]; }).join(',');\n\t\t}\n\t\t\n\t\tfunction toggleCredentialType() {\n\t\t\tconst token = document.querySelector('input[name=\"cluster-credential\"]:checked').value === 'token';\n\t\t\tdocument.getElementById('credential-kubeconfig').style.display = token ? 'none' : 'block';\n\t\t\tdocument.getElementById('credential-token').style.display = token ? 'block' : 'none';\n\t\t}\n\t\t\n\t\tfunction hideAddClusterModal() {\n\t\t\tdocument.getElementById('add-cluster-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function handleAddCluster(event) {\n\t\t\tevent.preventDefault();\n\t\t\t\n\t\t\tconst btn = document.getElementById('add-cluster-btn');\n\t\t\tconst text = document.getElementById('add-cluster-text');\n\t\t\tconst loading = document.getElementById('add-cluster-loading');\n\t\t\tconst errorDiv = document.getElementById('add-cluster-error');\n\t\t\t\n\t\t\tbtn.disabled = true;\n\t\t\ttext.style.display = 'none';\n\t\t\tloading.style.display = 'inline-block';\n\t\t\t\n\t\t\tconst name = document.getElementById('cluster-name').value;\n\t\t\tconst group = document.getElementById('cluster-group').value.trim();\n\t\t\tconst labels = parseLabels(document.getElementById('cluster-labels').value);\n\t\t\tconst auto_restart = document.getElementById('cluster-auto-restart').checked;\n\t\t\tconst body = editingClusterId ? { name, group, labels } : { name, group, labels, auto_restart };\n\t\t\tif (document.getElementById('credential-section').style.display === 'none') {\n\t\t\t\t// in-cluster clusters have no credentials\n\t\t\t} else if (document.querySelector('input[name=\"cluster-credential\"]:checked').value === 'token') {\n\t\t\t\tbody.server = document.getElementById('cluster-server').value.trim();\n\t\t\t\tbody.token = document.getElementById('cluster-token').value.trim();\n\t\t\t\tbody.ca_cert = document.getElementById('cluster-ca').value.trim();\n\t\t\t} else {\n\t\t\t\tbody.kubeconfig = document.getElementById('cluster-kubeconfig').value;\n\t\t\t}\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch(editingClusterId ? '/api/clusters/' + editingClusterId : '/api/clusters', {\n\t\t\t\t\tmethod: editingClusterId ? 'PATCH' : 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify(body),\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tconst data = await response.json();\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\thideAddClusterModal();\n\t\t\t\t\tloadClusters();\n\t\t\t\t} else {\n\t\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || (editingClusterId ? '保存失败' : '添加失败')) + '</div>';\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t} finally {\n\t\t\t\tbtn.disabled = false;\n\t\t\t\ttext.style.display = 'inline';\n\t\t\t\tloading.style.display = 'none';\n\t\t\t}\n\t\t}\n\t\t\n\t\t// parseLabels turns \"env=staging,region=eu\" into an object\n\t\tfunction parseLabels(text) {\n\t\t\tconst labels = {};\n\t\t\ttext.split(',').forEach(function(pair) {\n\t\t\t\tconst idx = pair.indexOf('=');\n\t\t\t\tif (idx > 0) labels[pair.substring(0, idx).trim()] = pair.substring(idx + 1).trim();\n\t\t\t});\n\t\t\treturn labels;\n\t\t}\n\t\t\n\t\tfunction showOnboardingModal() {\n\t\t\tdocument.getElementById('onboarding-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('onboarding-error').innerHTML = '';\n\t\t\tloadOnboardingManifests();\n\t\t}\n\t\t\n\t\tfunction hideOnboardingModal() {\n\t\t\tdocument.getElementById('onboarding-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function loadOnboardingManifests() {\n\t\t\tconst name = document.getElementById('onboarding-sa').value.trim() || 'coredns-manager';\n\t\t\tconst manifests = document.getElementById('onboarding-manifests');\n\t\t\tdocument.getElementById('onboarding-secret-cmd').textContent = 'kubectl -n kube-system get secret ' + name + '-token -o yaml';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/onboarding/manifests?name=' + encodeURIComponent(name));\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\tmanifests.value = '';\n\t\t\t\t\tdocument.getElementById('onboarding-error').innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '生成失败') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tdocument.getElementById('onboarding-error').innerHTML = '';\n\t\t\t\tmanifests.value = await response.text();\n\t\t\t} catch (error) {\n\t\t\t\tdocument.getElementById('onboarding-error').innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function registerOnboardedCluster() {\n\t\t\tconst errorDiv = document.getElementById('onboarding-error');\n\t\t\tconst body = {\n\t\t\t\tname: document.getElementById('onboarding-name').value.trim(),\n\t\t\t\tserver: document.getElementById('onboarding-server').value.trim(),\n\t\t\t\tsecret: document.getElementById('onboarding-secret').value,\n\t\t\t};\n\t\t\tif (!body.name || !body.server || !body.secret.trim()) {\n\t\t\t\talert('请填写完整信息');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\terrorDiv.innerHTML = '<span class=\"loading\"></span>';\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/onboarding/register', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify(body),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '注册失败') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\thideOnboardingModal();\n\t\t\t\tloadClusters();\n\t\t\t} catch (error) {\n\t\t\t\terrorDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction showImportModal() {\n\t\t\tdocument.getElementById('import-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('import-contexts').innerHTML = '';\n\t\t\tdocument.getElementById('import-options').style.display = 'none';\n\t\t\tdocument.getElementById('import-results').innerHTML = '';\n\t\t}\n\t\t\n\t\tfunction hideImportModal() {\n\t\t\tdocument.getElementById('import-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function parseImportContexts() {\n\t\t\tconst kubeconfig = document.getElementById('import-kubeconfig').value.trim();\n\t\t\tconst contextsDiv = document.getElementById('import-contexts');\n\t\t\tdocument.getElementById('import-options').style.display = 'none';\n\t\t\tdocument.getElementById('import-results').innerHTML = '';\n\t\t\t\n\t\t\tif (!kubeconfig) {\n\t\t\t\talert('请粘贴 kubeconfig 内容');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\tcontextsDiv.innerHTML = '<span class=\"loading\"></span>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/import/contexts', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ kubeconfig: kubeconfig }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\tcontextsDiv.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '解析失败') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tif (data.length === 0) {\n\t\t\t\t\tcontextsDiv.innerHTML = '<p style=\"color: var(--text-secondary);\">未找到任何上下文</p>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet html = '<label class=\"form-label\">选择要导入的上下文（集群名称与上下文名称相同）</label>';\n\t\t\t\tfor (let i = 0; i < data.length; i++) {\n\t\t\t\t\tconst ctx = data[i];\n\t\t\t\t\thtml += '<div class=\"rule-item\"><label style=\"display: flex; gap: 0.5rem; align-items: center;\">' +\n\t\t\t\t\t\t'<input type=\"checkbox\" class=\"import-context\" value=\"' + escapeHtml(ctx.name) + '\"' + (ctx.credential_plugin ? ' disabled' : ' checked') + '/>' +\n\t\t\t\t\t\t'<strong>' + escapeHtml(ctx.name) + '</strong>' +\n\t\t\t\t\t\t(ctx.current ? ' <span class=\"badge badge-info\">当前</span>' : '') +\n\t\t\t\t\t\t(ctx.credential_plugin ? ' <span class=\"badge badge-warning\" title=\"凭据插件在管理器中不可用，请改用 Token + CA 证书添加\">' + escapeHtml(ctx.credential_plugin) + ' 不支持</span>' : '') + '</label>' +\n\t\t\t\t\t\t'<span style=\"color: var(--text-secondary); font-size: 0.85rem;\">' + escapeHtml(ctx.server || ctx.cluster) + '</span></div>';\n\t\t\t\t}\n\t\t\t\tcontextsDiv.innerHTML = html;\n\t\t\t\tdocument.getElementById('import-options').style.display = 'block';\n\t\t\t} catch (error) {\n\t\t\t\tcontextsDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function importContexts() {\n\t\t\tconst kubeconfig = document.getElementById('import-kubeconfig').value.trim();\n\t\t\tconst group = document.getElementById('import-group').value.trim();\n\t\t\tconst labels = parseLabels(document.getElementById('import-labels').value);\n\t\t\tconst resultsDiv = document.getElementById('import-results');\n\t\t\tconst contexts = Array.from(document.querySelectorAll('.import-context:checked')).map(function(el) { return el.value; });\n\t\t\t\n\t\t\tif (contexts.length === 0) {\n\t\t\t\talert('请至少选择一个上下文');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\tresultsDiv.innerHTML = '<span class=\"loading\"></span> 正在并发测试连接...';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/import', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ kubeconfig: kubeconfig, contexts: contexts, group: group, labels: labels }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!data.results) {\n\t\t\t\t\tresultsDiv.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '未知错误') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet html = '<p style=\"margin-bottom: 0.5rem;\">' + escapeHtml(data.message) + '</p>';\n\t\t\t\tfor (let i = 0; i < data.results.length; i++) {\n\t\t\t\t\tconst r = data.results[i];\n\t\t\t\t\tconst badge = r.success ? '<span class=\"badge badge-success\">成功</span>' : '<span class=\"badge badge-danger\">失败</span>';\n\t\t\t\t\thtml += '<div class=\"rule-item\"><span>' + escapeHtml(r.cluster_name) + '</span>' +\n\t\t\t\t\t\t'<span>' + badge + (r.error ? ' <span style=\"color: var(--danger);\">' + escapeHtml(r.error) + '</span>' : '') + '</span></div>';\n\t\t\t\t}\n\t\t\t\tresultsDiv.innerHTML = html;\n\t\t\t\tloadClusters();\n\t\t\t} catch (error) {\n\t\t\t\tresultsDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction showBulkModal() {\n\t\t\tdocument.getElementById('bulk-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('bulk-results').innerHTML = '';\n\t\t}\n\t\t\n\t\tfunction hideBulkModal() {\n\t\t\tdocument.getElementById('bulk-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function runBulk(action) {\n\t\t\tconst selector = document.getElementById('bulk-selector').value.trim();\n\t\t\tconst namespace = document.getElementById('bulk-namespace').value.trim();\n\t\t\tconst targetIP = document.getElementById('bulk-target-ip').value.trim();\n\t\t\tconst stopOnFailure = document.getElementById('bulk-stop-on-failure').checked;\n\t\t\tconst resultsDiv = document.getElementById('bulk-results');\n\t\t\t\n\t\t\tif (!selector || !namespace || (action === 'add' && !targetIP)) {\n\t\t\t\talert('请填写完整信息');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\tif (action === 'delete' && !confirm('确定要从所有匹配 \"' + selector + '\" 的集群删除 ' + namespace + ' 的转发规则吗？')) return;\n\t\t\t\n\t\t\tresultsDiv.innerHTML = '<span class=\"loading\"></span>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tlet response;\n\t\t\t\tif (action === 'add') {\n\t\t\t\t\tresponse = await fetch('/api/bulk/rules', {\n\t\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\t\tbody: JSON.stringify({ selector: selector, namespace: namespace, target_ip: targetIP, stop_on_failure: stopOnFailure }),\n\t\t\t\t\t});\n\t\t\t\t} else {\n\t\t\t\t\tconst fqdn = namespace.endsWith('.svc.cluster.local');\n\t\t\t\t\tconst name = fqdn ? namespace.slice(0, -'.svc.cluster.local'.length) : namespace;\n\t\t\t\t\tresponse = await fetch('/api/bulk/rules/' + encodeURIComponent(name) +\n\t\t\t\t\t\t'?selector=' + encodeURIComponent(selector) + '&fqdn=' + fqdn + '&stop_on_failure=' + stopOnFailure, {\n\t\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t\t});\n\t\t\t\t}\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!data.results) {\n\t\t\t\t\tresultsDiv.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '未知错误') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet html = '<p style=\"margin-bottom: 0.5rem;\">' + escapeHtml(data.message) + '</p>';\n\t\t\t\tfor (let i = 0; i < data.results.length; i++) {\n\t\t\t\t\tconst r = data.results[i];\n\t\t\t\t\tconst badge = r.success ? '<span class=\"badge badge-success\">成功</span>' :\n\t\t\t\t\t\tr.skipped ? '<span class=\"badge badge-info\">跳过</span>' : '<span class=\"badge badge-danger\">失败</span>';\n\t\t\t\t\tconst unverified = r.loop_unverified ? ' <span style=\"color: var(--warning);\">循环检测不完整: ' + escapeHtml(r.loop_unverified.join(', ')) + '</span>' : '';\n\t\t\t\t\thtml += '<div class=\"rule-item\"><span>' + escapeHtml(r.cluster_name) + '</span>' +\n\t\t\t\t\t\t'<span>' + badge + (r.error ? ' <span style=\"color: var(--danger);\">' + escapeHtml(r.error) + '</span>' : '') + unverified + '</span></div>';\n\t\t\t\t}\n\t\t\t\tresultsDiv.innerHTML = html;\n\t\t\t\tloadClusters();\n\t\t\t} catch (error) {\n\t\t\t\tresultsDiv.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction hideTopologyModal() {\n\t\t\tdocument.getElementById('topology-modal').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function showTopologyModal() {\n\t\t\tconst content = document.getElementById('topology-content');\n\t\t\tdocument.getElementById('topology-modal').style.display = 'flex';\n\t\t\tcontent.innerHTML = '<div style=\"text-align: center; padding: 2rem;\"><span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span></div>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/topology');\n\t\t\t\tif (!response.ok) throw new Error('Failed to load topology');\n\t\t\t\trenderTopology(await response.json());\n\t\t\t} catch (error) {\n\t\t\t\tcontent.innerHTML = '<div class=\"alert alert-error\">加载失败: ' + error.message + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderTopology(report) {\n\t\t\tconst names = {};\n\t\t\t(report.clusters || []).forEach(function(c) { names[c.cluster_id] = c.cluster_name; });\n\t\t\t\n\t\t\tlet html = '<div class=\"service-info\">' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">转发名称</div><div class=\"info-value\">' + report.names.length + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">循环</div><div class=\"info-value\" style=\"color: ' + (report.cycles > 0 ? 'var(--danger)' : 'var(--success)') + ';\">' + report.cycles + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">未知目标</div><div class=\"info-value\" style=\"color: ' + (report.dead_ends > 0 ? 'var(--warning)' : 'var(--success)') + ';\">' + report.dead_ends + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">未验证</div><div class=\"info-value\" style=\"color: ' + (report.unverified > 0 ? 'var(--warning)' : 'var(--success)') + ';\">' + report.unverified + '</div></div>' +\n\t\t\t\t'</div>';\n\t\t\t\n\t\t\t(report.clusters || []).forEach(function(c) {\n\t\t\t\tif (c.error) html += '<div class=\"alert alert-error\">' + escapeHtml(c.cluster_name) + ' 无法读取，指向它的转发未经验证: ' + escapeHtml(c.error) + '</div>';\n\t\t\t});\n\t\t\t\n\t\t\tif (report.names.length === 0) {\n\t\t\t\thtml += '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">暂无转发规则</p>';\n\t\t\t}\n\t\t\t\n\t\t\tfor (let i = 0; i < report.names.length; i++) {\n\t\t\t\tconst g = report.names[i];\n\t\t\t\tconst cycleHtml = g.cycles.map(function(cycle) {\n\t\t\t\t\treturn '<span class=\"badge badge-danger\">循环: ' + escapeHtml(cycle.concat([cycle[0]]).map(function(id) { return names[id]; }).join(' → ')) + '</span>';\n\t\t\t\t}).join(' ');\n\t\t\t\thtml += '<div class=\"card\" style=\"padding: 1rem; margin-bottom: 1rem;\">' +\n\t\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center;\">' +\n\t\t\t\t\t'<span class=\"rule-domain\">' + escapeHtml(g.name) + '</span><span>' + cycleHtml + '</span></div>' +\n\t\t\t\t\ttopologySVG(g, names) + '</div>';\n\t\t\t}\n\t\t\t\n\t\t\tdocument.getElementById('topology-content').innerHTML = html;\n\t\t}\n\t\t\n\t\t// topologySVG draws the clusters of one name on a circle with an arrow per forward rule.\n\t\t// Edges in a cycle are red, edges to unknown targets or unreadable clusters are dashed.\n\t\tfunction topologySVG(g, names) {\n\t\t\tconst ids = [];\n\t\t\tg.edges.forEach(function(e) {\n\t\t\t\tif (ids.indexOf(e.from) < 0) ids.push(e.from);\n\t\t\t\tconst to = e.to || 'ip:' + e.target_ip;\n\t\t\t\tif (ids.indexOf(to) < 0) ids.push(to);\n\t\t\t});\n\t\t\t\n\t\t\tconst inCycle = {};\n\t\t\tg.cycles.forEach(function(cycle) {\n\t\t\t\tfor (let i = 0; i < cycle.length; i++) inCycle[cycle[i] + '>' + cycle[(i + 1) % cycle.length]] = true;\n\t\t\t});\n\t\t\t\n\t\t\tconst w = 820, h = 220, r = 80, cx = w / 2, cy = h / 2;\n\t\t\tconst pos = {};\n\t\t\tids.forEach(function(id, i) {\n\t\t\t\tconst angle = ids.length === 1 ? 0 : (2 * Math.PI * i) / ids.length - Math.PI / 2;\n\t\t\t\tpos[id] = { x: cx + r * 2.5 * Math.cos(angle), y: cy + r * Math.sin(angle) };\n\t\t\t});\n\t\t\t\n\t\t\tlet svg = '<svg width=\"100%\" viewBox=\"0 0 ' + w + ' ' + h + '\" style=\"margin-top: 0.5rem;\">' +\n\t\t\t\t'<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto-start-reverse\">' +\n\t\t\t\t'<path d=\"M 0 0 L 10 5 L 0 10 z\" fill=\"context-stroke\"/></marker></defs>';\n\t\t\t\n\t\t\tg.edges.forEach(function(e) {\n\t\t\t\tconst to = e.to || 'ip:' + e.target_ip;\n\t\t\t\tconst a = pos[e.from], b = pos[to];\n\t\t\t\tconst color = inCycle[e.from + '>' + e.to] ? 'var(--danger)' : (e.dead_end || e.unverified ? 'var(--warning)' : 'var(--accent)');\n\t\t\t\tconst dash = e.dead_end || e.unverified ? ' stroke-dasharray=\"6 4\"' : '';\n\t\t\t\tif (e.from === to) {\n\t\t\t\t\tsvg += '<circle cx=\"' + a.x + '\" cy=\"' + (a.y - 22) + '\" r=\"14\" fill=\"none\" stroke=\"' + color + '\" stroke-width=\"2\"' + dash + '/>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tconst dx = b.x - a.x, dy = b.y - a.y, len = Math.sqrt(dx * dx + dy * dy);\n\t\t\t\tconst x1 = a.x + dx / len * 40, y1 = a.y + dy / len * 18, x2 = b.x - dx / len * 40, y2 = b.y - dy / len * 18;\n\t\t\t\tsvg += '<line x1=\"' + x1 + '\" y1=\"' + y1 + '\" x2=\"' + x2 + '\" y2=\"' + y2 + '\" stroke=\"' + color + '\" stroke-width=\"2\"' + dash + ' marker-end=\"url(#arrow)\">' +\n\t\t\t\t\t'<title>' + escapeHtml((e.is_full_fqdn ? 'FQDN' : '短格式') + ' → ' + e.target_ip + (e.unverified ? ' (未验证)' : '')) + '</title></line>';\n\t\t\t});\n\t\t\t\n\t\t\tids.forEach(function(id) {\n\t\t\t\tconst p = pos[id];\n\t\t\t\tconst label = id.indexOf('ip:') === 0 ? id.substring(3) : (names[id] || id);\n\t\t\t\tconst fill = id.indexOf('ip:') === 0 ? '#fef3c7' : '#e0e7ff';\n\t\t\t\tsvg += '<rect x=\"' + (p.x - 60) + '\" y=\"' + (p.y - 16) + '\" width=\"120\" height=\"32\" rx=\"6\" fill=\"' + fill + '\" stroke=\"var(--border)\"/>' +\n\t\t\t\t\t'<text x=\"' + p.x + '\" y=\"' + (p.y + 5) + '\" text-anchor=\"middle\" font-size=\"12\">' + escapeHtml(label) + '</text>';\n\t\t\t});\n\t\t\t\n\t\t\treturn svg + '</svg>';\n\t\t}\n\t\t\n\t\tasync function deleteCluster(id, name) {\n\t\t\tif (!confirm('确定要删除集群 \"' + name + '\" 吗？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + id, { method: 'DELETE' });\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tloadClusters();\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('删除失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function showCoreDNSConfig(clusterId, clusterName) {\n\t\t\tcurrentClusterId = clusterId;\n\t\t\tdocument.getElementById('coredns-modal').style.display = 'flex';\n\t\t\tdocument.getElementById('coredns-modal-title').textContent = clusterName + ' - CoreDNS 配置';\n\t\t\tdocument.getElementById('coredns-content').innerHTML = \n\t\t\t\t'<div style=\"text-align: center; padding: 2rem;\">' +\n\t\t\t\t'<span class=\"loading\" style=\"width: 2rem; height: 2rem;\"></span>' +\n\t\t\t\t'<p style=\"margin-top: 1rem; color: var(--text-secondary);\">加载配置...</p></div>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + clusterId + '/coredns');\n\t\t\t\tif (!response.ok) throw new Error('Failed to load CoreDNS config');\n\t\t\t\tconst data = await response.json();\n\t\t\t\trenderCoreDNSConfig(data);\n\t\t\t\tloadLatestChange();\n\t\t\t} catch (error) {\n\t\t\t\tdocument.getElementById('coredns-content').innerHTML = renderAccessWarning() +\n\t\t\t\t\t'<div class=\"alert alert-error\">加载失败: ' + error.message + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction hideCoreDNSModal() {\n\t\t\tdocument.getElementById('coredns-modal').style.display = 'none';\n\t\t\tcurrentClusterId = null;\n\t\t\tclearTimeout(changeTimer);\n\t\t\tclearTimeout(restartTimer);\n\t\t\tstopLogStream();\n\t\t\tclearTimeout(queryLogTimer);\n\t\t}\n\t\t\n\t\tasync function loadLatestChange() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/changes');\n\t\t\t\tif (!response.ok) return;\n\t\t\t\tconst changes = await response.json();\n\t\t\t\tif (changes.length > 0) renderChange(changes[0]);\n\t\t\t} catch (error) {\n\t\t\t\t// Change status is optional\n\t\t\t}\n\t\t}\n\t\t\n\t\t// watchChange polls a Corefile change until CoreDNS is healthy or failed\n\t\tasync function watchChange(changeId) {\n\t\t\tclearTimeout(changeTimer);\n\t\t\tif (!changeId || !currentClusterId) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/changes/' + changeId);\n\t\t\t\tif (!response.ok) return;\n\t\t\t\trenderChange(await response.json());\n\t\t\t} catch (error) {\n\t\t\t\t// Retry on the next poll\n\t\t\t\tchangeTimer = setTimeout(function() { watchChange(changeId); }, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderChange(change) {\n\t\t\tconst el = document.getElementById('change-status');\n\t\t\tif (!el) return;\n\t\t\t\n\t\t\tconst states = {\n\t\t\t\tpending: '<span class=\"badge badge-info\">⏳ 生效中</span>',\n\t\t\t\thealthy: '<span class=\"badge badge-success\">✓ 已生效</span>',\n\t\t\t\tfailed: '<span class=\"badge badge-danger\">✗ 失败</span>',\n\t\t\t};\n\t\t\tconst pods = (change.pods || []).map(function(p) {\n\t\t\t\treturn escapeHtml(p.name) + (p.ready ? ' ✓' : ' ✗') + (p.reloaded ? ' (已重载)' : '') + (p.reason ? ' ' + escapeHtml(p.reason) : '');\n\t\t\t}).join('，');\n\t\t\t\n\t\t\tel.innerHTML = '<div class=\"card\" style=\"padding: 0.75rem 1rem; margin-bottom: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 0.5rem; align-items: center;\"><strong>最近变更</strong>' + (states[change.state] || change.state) +\n\t\t\t\t'<span style=\"color: var(--text-secondary); font-size: 0.85rem;\">' + new Date(change.started_at).toLocaleString('zh-CN') + '</span></div>' +\n\t\t\t\t(change.message ? '<p style=\"font-size: 0.85rem; margin-top: 0.25rem;\">' + escapeHtml(change.message) + '</p>' : '') +\n\t\t\t\t(pods ? '<p style=\"font-size: 0.8rem; color: var(--text-secondary); margin-top: 0.25rem;\">' + pods + '</p>' : '') +\n\t\t\t\t(change.rolled_back ? '<p style=\"font-size: 0.85rem; margin-top: 0.25rem;\"><span class=\"badge badge-warning\">↩ 已自动回滚</span></p>' : '') +\n\t\t\t\t(change.rollback_error ? '<p style=\"font-size: 0.85rem; color: var(--danger); margin-top: 0.25rem;\">回滚未执行: ' + escapeHtml(change.rollback_error) + '</p>' : '') +\n\t\t\t\t(change.log_excerpt ? '<p style=\"font-size: 0.8rem; margin-top: 0.5rem;\">' + escapeHtml(change.failed_pod || '') + ' 日志:</p>' +\n\t\t\t\t\t'<pre style=\"font-size: 0.75rem; max-height: 200px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px;\">' + escapeHtml(change.log_excerpt) + '</pre>' : '') +\n\t\t\t\t'</div>';\n\t\t\t\n\t\t\tif (change.state === 'pending') {\n\t\t\t\tchangeTimer = setTimeout(function() { watchChange(change.id); }, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tconst capabilityNames = {\n\t\t\tread_corefile: '读取 ConfigMap',\n\t\t\tupdate_corefile: '修改 ConfigMap',\n\t\t\tread_service: '读取 DNS Service',\n\t\t\tlist_pods: '列出 Pod',\n\t\t\tread_logs: '读取 Pod 日志',\n\t\t\trestart: '重启 Deployment',\n\t\t\tread_status: '读取工作负载和事件',\n\t\t\tnode_local: '修改 NodeLocal DNS ConfigMap',\n\t\t\trecord_events: '记录变更事件',\n\t\t\tbackup: '读写备份 ConfigMap',\n\t\t};\n\t\t\n\t\tfunction missingCapabilities(caps) {\n\t\t\treturn Object.keys(capabilityNames).filter(function(key) { return !caps[key]; }).map(function(key) { return capabilityNames[key]; });\n\t\t}\n\t\t\n\t\t// can reports whether the current cluster's credentials allow an action.\n\t\t// Clusters that were never checked are assumed to allow everything.\n\t\tfunction can(capability) {\n\t\t\tconst caps = (clustersById[currentClusterId] || {}).capabilities;\n\t\t\treturn !caps || !!caps[capability];\n\t\t}\n\t\t\n\t\t// denied returns the attributes that disable a button the credentials can't use\n\t\tfunction denied(capability) {\n\t\t\treturn can(capability) ? '' : ' disabled title=\"凭据缺少' + capabilityNames[capability] + '权限\"';\n\t\t}\n\t\t\n\t\tfunction renderAccessWarning() {\n\t\t\tconst caps = (clustersById[currentClusterId] || {}).capabilities;\n\t\t\tconst missing = caps ? missingCapabilities(caps) : [];\n\t\t\tif (missing.length === 0) return '';\n\t\t\treturn '<div class=\"card\" style=\"padding: 0.75rem 1rem; margin-bottom: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 0.5rem; align-items: center; justify-content: space-between;\">' +\n\t\t\t\t'<span><span class=\"badge badge-warning\">权限受限</span> 当前凭据缺少: ' + missing.join('、') + '，相关操作已禁用</span>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"recheckAccess()\">重新检测权限</button></div></div>';\n\t\t}\n\t\t\n\t\tasync function recheckAccess() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/access', { method: 'POST' });\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert('检测失败: ' + (data.error || '未知错误'));\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tconst cluster = clustersById[currentClusterId];\n\t\t\t\tcluster.capabilities = data.capabilities;\n\t\t\t\tshowCoreDNSConfig(cluster.id, cluster.name);\n\t\t\t\tloadClusters();\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderCoreDNSConfig(data) {\n\t\t\tconst serviceName = (data.service && data.service.metadata && data.service.metadata.name) || 'kube-dns';\n\t\t\tconst configMapName = (data.configmap && data.configmap.metadata && data.configmap.metadata.name) || 'coredns';\n\t\t\tconst serviceIP = data.service_ip || 'N/A';\n\t\t\tconst annotations = (data.configmap && data.configmap.metadata && data.configmap.metadata.annotations) || {};\n\t\t\tconst revision = annotations['coredns-manager/revision'];\n\t\t\tconst corefile = data.corefile || '';\n\t\t\tconst rules = data.forward_rules || [];\n\t\t\t\n\t\t\tlet rulesHtml = '';\n\t\t\tif (rules.length === 0) {\n\t\t\t\trulesHtml = '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">暂无转发规则</p>';\n\t\t\t} else {\n\t\t\t\tfor (let i = 0; i < rules.length; i++) {\n\t\t\t\t\tconst rule = rules[i];\n\t\t\t\t\t// Build full name: service.namespace or just namespace\n\t\t\t\t\tconst fullName = rule.service_name ? rule.service_name + '.' + rule.namespace : rule.namespace;\n\t\t\t\t\t// Display domain: FQDN format shows .svc.cluster.local, short format shows just fullName\n\t\t\t\t\tconst displayDomain = rule.is_full_fqdn ? fullName + '.svc.cluster.local:53' : fullName + ':53';\n\t\t\t\t\trulesHtml += '<div class=\"rule-item\">' +\n\t\t\t\t\t\t'<div><span class=\"rule-domain\">' + displayDomain + '</span>' +\n\t\t\t\t\t\t'<span style=\"margin: 0 0.5rem;\">→</span>' +\n\t\t\t\t\t\t'<span class=\"rule-target\">' + rule.target_ip + '</span></div>' +\n\t\t\t\t\t\t'<div style=\"display: flex; gap: 0.5rem;\">' +\n\t\t\t\t\t\t'<button class=\"btn btn-secondary\" style=\"padding: 0.5rem 1rem;\" onclick=\"startQueryLog(\\'' + fullName + '\\', ' + (rule.is_full_fqdn ? 'true' : 'false') + ')\"' + denied('update_corefile') + '>调试</button>' +\n\t\t\t\t\t\t'<button class=\"btn btn-danger\" style=\"padding: 0.5rem 1rem;\" onclick=\"deleteForwardRule(\\'' + fullName + '\\', ' + (rule.is_full_fqdn ? 'true' : 'false') + ')\"' + denied('update_corefile') + '>删除</button></div></div>';\n\t\t\t\t}\n\t\t\t}\n\t\t\t\n\t\t\tdocument.getElementById('coredns-content').innerHTML = \n\t\t\t\t'<div class=\"service-info\">' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">Service Name</div><div class=\"info-value\">' + serviceName + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">Cluster IP</div><div class=\"info-value\">' + serviceIP + '</div></div>' +\n\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">ConfigMap</div><div class=\"info-value\">' + configMapName + '</div></div>' +\n\t\t\t\t'</div>' +\n\t\t\t\t(revision ? '<p style=\"font-size: 0.85rem; color: var(--text-secondary); margin-bottom: 1rem;\">修订 #' + escapeHtml(revision) + ' · ' +\n\t\t\t\t\tescapeHtml(annotations['coredns-manager/last-applied-by'] || '-') + ' · ' +\n\t\t\t\t\tnew Date(annotations['coredns-manager/timestamp']).toLocaleString('zh-CN') +\n\t\t\t\t\t(annotations['coredns-manager/reason'] ? ' · ' + escapeHtml(annotations['coredns-manager/reason']) : '') + '</p>' : '') +\n\t\t\t\t'<div id=\"change-status\"></div>' +\n\t\t\t\t'<div id=\"querylog-status\"></div>' +\n\t\t\t\trenderAccessWarning() +\n\t\t\t\trenderReloadWarning(data) +\n\t\t\t\t'<div class=\"tabs\">' +\n\t\t\t\t'<button class=\"tab active\" onclick=\"switchTab(\\'rules\\', this)\">转发规则</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'corefile\\', this)\">Corefile</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'status\\', this)\">运行状态</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'logs\\', this)\">日志</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'nodelocal\\', this)\">NodeLocal DNS</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'backups\\', this)\">备份</button>' +\n\t\t\t\t'<button class=\"tab\" onclick=\"switchTab(\\'migration\\', this)\">版本迁移</button>' +\n\t\t\t\t'</div>' +\n\t\t\t\t'<div id=\"tab-rules\">' +\n\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<h4>已配置的转发规则</h4>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"showAddRuleForm()\"' + denied('update_corefile') + '>➕ 添加规则</button></div>' +\n\t\t\t\t'<div id=\"add-rule-form\" style=\"display: none; margin-bottom: 1rem;\">' +\n\t\t\t'\t<div class=\"card\" style=\"padding: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 1rem; align-items: flex-end;\">' +\n\t\t\t\t'<div class=\"form-group\" style=\"flex: 1; margin-bottom: 0;\"><label class=\"form-label\">名称</label>' +\n\t\t\t\t'<input type=\"text\" id=\"rule-namespace\" class=\"form-input\" placeholder=\"prod / mysql.tidb-cluster / prod.svc.cluster.local\"/></div>' +\n\t\t\t\t'<div class=\"form-group\" style=\"flex: 1; margin-bottom: 0;\"><label class=\"form-label\">目标 DNS IP</label>' +\n\t\t\t\t'<input type=\"text\" id=\"rule-target-ip\" class=\"form-input\" placeholder=\"例如: 10.96.0.10\"/></div>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"addForwardRule()\">添加</button>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"hideAddRuleForm()\">取消</button></div>' +\n\t\t\t\t'<p style=\"font-size: 0.8rem; color: var(--text-secondary); margin-top: 0.5rem;\">短格式(prod/mysql.tidb-cluster)自动添加rewrite，完整格式(*.svc.cluster.local)只forward</p>' +\n\t\t\t\t'</div></div>' +\n\t\t\t\t'<div class=\"rules-list\" id=\"rules-list\">' + rulesHtml + '</div></div>' +\n\t\t\t\t'<div id=\"tab-corefile\" style=\"display: none;\">' +\n\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<h4>Corefile 内容</h4>' +\n\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"saveCorefile()\" id=\"save-corefile-btn\"' + denied('update_corefile') + '>保存修改</button></div>' +\n\t\t\t\t'<textarea id=\"corefile-editor\" class=\"form-textarea\" style=\"min-height: 400px; font-size: 0.9rem;\">' + escapeHtml(corefile) + '</textarea></div>' +\n\t\t\t\t'<div id=\"tab-status\" style=\"display: none;\">' +\n\t\t\t\t'<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<h4>CoreDNS 运行状态</h4>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"loadCoreDNSStatus()\"' + denied('read_status') + '>刷新</button></div>' +\n\t\t\t\t'<div id=\"coredns-status\"></div></div>' +\n\t\t\t\t'<div id=\"tab-logs\" style=\"display: none;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 0.5rem; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t'<input type=\"text\" id=\"log-filter\" class=\"form-input\" style=\"flex: 1;\" placeholder=\"过滤文本，例如: payments 或 SERVFAIL\"/>' +\n\t\t\t\t'<button class=\"btn btn-primary\" id=\"log-toggle-btn\" onclick=\"toggleLogStream()\"' + denied('read_logs') + '>开始</button>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"document.getElementById(\\'log-output\\').textContent = \\'\\'\">清空</button></div>' +\n\t\t\t\t'<pre id=\"log-output\" style=\"font-size: 0.75rem; height: 400px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px; white-space: pre-wrap;\"></pre></div>' +\n\t\t\t\t'<div id=\"tab-nodelocal\" style=\"display: none;\"></div>' +\n\t\t\t\t'<div id=\"tab-backups\" style=\"display: none;\"></div>' +\n\t\t\t\t'<div id=\"tab-migration\" style=\"display: none;\"></div>';\n\t\t}\n\t\t\n\t\t// renderReloadWarning offers a CoreDNS restart when the reload plugin is missing\n\t\tfunction renderReloadWarning(data) {\n\t\t\tif (data.reload) return '';\n\t\t\tconst cluster = clustersById[currentClusterId] || {};\n\t\t\treturn '<div class=\"card\" style=\"padding: 0.75rem 1rem; margin-bottom: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 0.5rem; align-items: center; justify-content: space-between;\">' +\n\t\t\t\t'<span><span class=\"badge badge-warning\">未启用 reload</span> Corefile 修改需重启 CoreDNS 后才能生效</span>' +\n\t\t\t\t'<button class=\"btn btn-secondary\" onclick=\"restartCoreDNS()\"' + denied('restart') + '>🔄 滚动重启 CoreDNS</button></div>' +\n\t\t\t\t'<label style=\"display: flex; gap: 0.5rem; align-items: center; font-size: 0.85rem; margin-top: 0.5rem;\">' +\n\t\t\t\t'<input type=\"checkbox\" onchange=\"setAutoRestart(this.checked)\"' + (cluster.auto_restart ? ' checked' : '') + '/>修改 Corefile 后自动重启</label>' +\n\t\t\t\t'<p id=\"restart-status\" style=\"font-size: 0.85rem; color: var(--text-secondary); margin-top: 0.25rem;\"></p></div>';\n\t\t}\n\t\t\n\t\tasync function restartCoreDNS() {\n\t\t\tif (!confirm('确定要滚动重启 CoreDNS 吗？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns/restart', { method: 'POST' });\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert(data.error || '重启失败');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\trenderRestartStatus(data.status);\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\t// watchRestart polls the restart progress until the rollout is done\n\t\tasync function watchRestart() {\n\t\t\tclearTimeout(restartTimer);\n\t\t\tif (!currentClusterId) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns/restart');\n\t\t\t\tif (!response.ok) return;\n\t\t\t\trenderRestartStatus(await response.json());\n\t\t\t} catch (error) {\n\t\t\t\trestartTimer = setTimeout(watchRestart, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderRestartStatus(status) {\n\t\t\tconst el = document.getElementById('restart-status');\n\t\t\tif (!el) return;\n\t\t\t\n\t\t\tconst state = status.done ? '✓ 重启完成' : status.failed ? '✗ 重启失败' : '⏳ 重启中';\n\t\t\tel.textContent = state + ' - 已更新 ' + status.updated + '/' + status.desired + '，就绪 ' + status.ready + '/' + status.desired + '（' + status.message + '）';\n\t\t\t\n\t\t\tif (!status.done && !status.failed) {\n\t\t\t\trestartTimer = setTimeout(watchRestart, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function setAutoRestart(enabled) {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/settings', {\n\t\t\t\t\tmethod: 'PUT',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ auto_restart: enabled }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert(data.error || '保存失败');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tif (clustersById[currentClusterId]) clustersById[currentClusterId].auto_restart = enabled;\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction escapeHtml(text) {\n\t\t\tconst div = document.createElement('div');\n\t\t\tdiv.textContent = text;\n\t\t\treturn div.innerHTML;\n\t\t}\n\t\t\n\t\tfunction switchTab(tabName, element) {\n\t\t\tdocument.querySelectorAll('.tab').forEach(function(t) { t.classList.remove('active'); });\n\t\t\telement.classList.add('active');\n\t\t\tdocument.getElementById('tab-rules').style.display = tabName === 'rules' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-corefile').style.display = tabName === 'corefile' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-status').style.display = tabName === 'status' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-logs').style.display = tabName === 'logs' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-nodelocal').style.display = tabName === 'nodelocal' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-backups').style.display = tabName === 'backups' ? 'block' : 'none';\n\t\t\tdocument.getElementById('tab-migration').style.display = tabName === 'migration' ? 'block' : 'none';\n\t\t\tif (tabName === 'status' && can('read_status')) loadCoreDNSStatus();\n\t\t\tif (tabName === 'nodelocal') loadNodeLocal();\n\t\t\tif (tabName === 'backups') loadBackups();\n\t\t\tif (tabName === 'migration') loadMigration('');\n\t\t}\n\t\t\n\t\tasync function loadCoreDNSStatus() {\n\t\t\tconst el = document.getElementById('coredns-status');\n\t\t\tel.innerHTML = '<div style=\"text-align: center; padding: 1rem;\"><span class=\"loading\"></span></div>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns/status');\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) throw new Error(data.error || 'Failed to load status');\n\t\t\t\trenderCoreDNSStatus(data);\n\t\t\t} catch (error) {\n\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">加载失败: ' + escapeHtml(error.message) + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderCoreDNSStatus(data) {\n\t\t\tlet html = '<div class=\"service-info\">';\n\t\t\tfor (let i = 0; i < data.workloads.length; i++) {\n\t\t\t\tconst w = data.workloads[i];\n\t\t\t\tconst ok = w.ready >= w.desired;\n\t\t\t\thtml += '<div class=\"info-card\"><div class=\"info-label\">' + w.kind + ' / ' + escapeHtml(w.name) + '</div>' +\n\t\t\t\t\t'<div class=\"info-value\"><span class=\"badge ' + (ok ? 'badge-success' : 'badge-danger') + '\">' + w.ready + '/' + w.desired + ' 就绪</span></div>' +\n\t\t\t\t\t'<div style=\"font-size: 0.8rem; color: var(--text-secondary);\">已更新 ' + w.updated + '，可用 ' + w.available + '</div></div>';\n\t\t\t}\n\t\t\thtml += '</div>';\n\t\t\tif (data.workloads.length === 0) {\n\t\t\t\thtml = '<p style=\"color: var(--text-secondary);\">未找到 CoreDNS Deployment 或 DaemonSet</p>';\n\t\t\t}\n\t\t\t\n\t\t\thtml += '<h4 style=\"margin: 1rem 0 0.5rem;\">Pods</h4>';\n\t\t\tfor (let i = 0; i < data.pods.length; i++) {\n\t\t\t\tconst p = data.pods[i];\n\t\t\t\thtml += '<div class=\"rule-item\"><div>' +\n\t\t\t\t\t'<span class=\"rule-domain\">' + escapeHtml(p.name) + '</span> ' +\n\t\t\t\t\t'<span class=\"badge ' + (p.ready ? 'badge-success' : 'badge-danger') + '\">' + escapeHtml(p.phase) + (p.reason ? ' / ' + escapeHtml(p.reason) : '') + '</span>' +\n\t\t\t\t\t'<p style=\"font-size: 0.8rem; color: var(--text-secondary); margin-top: 0.25rem;\">节点 ' + escapeHtml(p.node || '-') + ' · 重启 ' + p.restarts + ' 次 · ' +\n\t\t\t\t\tescapeHtml(p.image) + (p.version ? ' (v' + escapeHtml(p.version) + ')' : '') + '</p></div></div>';\n\t\t\t}\n\t\t\t\n\t\t\thtml += '<h4 style=\"margin: 1rem 0 0.5rem;\">最近事件</h4>';\n\t\t\tif (data.events.length === 0) {\n\t\t\t\thtml += '<p style=\"color: var(--text-secondary);\">暂无事件</p>';\n\t\t\t}\n\t\t\tfor (let i = 0; i < data.events.length; i++) {\n\t\t\t\tconst e = data.events[i];\n\t\t\t\thtml += '<p style=\"font-size: 0.85rem; margin-bottom: 0.25rem;\">' +\n\t\t\t\t\t'<span class=\"badge ' + (e.type === 'Warning' ? 'badge-warning' : 'badge-info') + '\">' + escapeHtml(e.reason) + '</span> ' +\n\t\t\t\t\t'<span style=\"color: var(--text-secondary);\">' + new Date(e.last_seen).toLocaleString('zh-CN') + ' ' + escapeHtml(e.pod) + (e.count > 1 ? ' ×' + e.count : '') + '</span> ' +\n\t\t\t\t\tescapeHtml(e.message) + '</p>';\n\t\t\t}\n\t\t\t\n\t\t\tdocument.getElementById('coredns-status').innerHTML = html;\n\t\t}\n\t\t\n\t\t// toggleLogStream starts or stops following the logs of all CoreDNS pods\n\t\tfunction toggleLogStream() {\n\t\t\tif (logSource) {\n\t\t\t\tstopLogStream();\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\tconst filter = document.getElementById('log-filter').value.trim();\n\t\t\tconst output = document.getElementById('log-output');\n\t\t\tlogSource = new EventSource('/api/clusters/' + currentClusterId + '/coredns/logs?filter=' + encodeURIComponent(filter));\n\t\t\tdocument.getElementById('log-toggle-btn').textContent = '停止';\n\t\t\t\n\t\t\tlogSource.addEventListener('log', function(event) {\n\t\t\t\tconst data = JSON.parse(event.data);\n\t\t\t\tconst atBottom = output.scrollTop + output.clientHeight >= output.scrollHeight - 10;\n\t\t\t\toutput.textContent += '[' + data.pod + '] ' + data.line + '\\n';\n\t\t\t\t// Keep the last 1000 lines\n\t\t\t\tconst lines = output.textContent.split('\\n');\n\t\t\t\tif (lines.length > 1000) output.textContent = lines.slice(lines.length - 1000).join('\\n');\n\t\t\t\tif (atBottom) output.scrollTop = output.scrollHeight;\n\t\t\t});\n\t\t\tlogSource.addEventListener('error', function(event) {\n\t\t\t\tif (event.data) output.textContent += '错误: ' + JSON.parse(event.data).error + '\\n';\n\t\t\t\tstopLogStream();\n\t\t\t});\n\t\t}\n\t\t\n\t\tfunction stopLogStream() {\n\t\t\tif (logSource) logSource.close();\n\t\t\tlogSource = null;\n\t\t\tconst btn = document.getElementById('log-toggle-btn');\n\t\t\tif (btn) btn.textContent = '开始';\n\t\t}\n\t\t\n\t\t// startQueryLog enables the log plugin for one rule for a few minutes\n\t\tasync function startQueryLog(name, isFullFQDN) {\n\t\t\tconst minutes = parseInt(prompt('为 ' + name + ' 开启查询日志，持续分钟数（最多 60）:', '5'), 10);\n\t\t\tif (!minutes) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/rules/' + encodeURIComponent(name) + '/querylog?fqdn=' + isFullFQDN, {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ minutes: minutes }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert(data.error || '开启失败');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\twatchChange(data.change_id);\n\t\t\t\trenderQueryLog(data.session);\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function watchQueryLog(sessionId) {\n\t\t\tclearTimeout(queryLogTimer);\n\t\t\tif (!currentClusterId) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/querylogs/' + sessionId);\n\t\t\t\tif (!response.ok) return;\n\t\t\t\trenderQueryLog(await response.json());\n\t\t\t} catch (error) {\n\t\t\t\tqueryLogTimer = setTimeout(function() { watchQueryLog(sessionId); }, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function stopQueryLog(sessionId) {\n\t\t\ttry {\n\t\t\t\tawait fetch('/api/clusters/' + currentClusterId + '/querylogs/' + sessionId, { method: 'DELETE' });\n\t\t\t\twatchQueryLog(sessionId);\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction renderQueryLog(session) {\n\t\t\tconst el = document.getElementById('querylog-status');\n\t\t\tif (!el) return;\n\t\t\t\n\t\t\tconst states = {\n\t\t\t\tactive: '<span class=\"badge badge-info\">⏳ 记录中</span>',\n\t\t\t\tfinished: '<span class=\"badge badge-success\">✓ 已结束</span>',\n\t\t\t\tfailed: '<span class=\"badge badge-danger\">✗ 失败</span>',\n\t\t\t};\n\t\t\tconst lines = (session.lines || []).map(function(l) { return '[' + l.pod + '] ' + l.line; }).join('\\n');\n\t\t\t\n\t\t\tel.innerHTML = '<div class=\"card\" style=\"padding: 0.75rem 1rem; margin-bottom: 1rem;\">' +\n\t\t\t\t'<div style=\"display: flex; gap: 0.5rem; align-items: center;\"><strong>查询日志 ' + escapeHtml(session.rule) + '</strong>' + (states[session.state] || session.state) +\n\t\t\t\t'<span style=\"color: var(--text-secondary); font-size: 0.85rem;\">至 ' + new Date(session.expires_at).toLocaleTimeString('zh-CN') + '</span>' +\n\t\t\t\t(session.state === 'active' ? '<button class=\"btn btn-secondary\" style=\"padding: 0.25rem 0.75rem; margin-left: auto;\" onclick=\"stopQueryLog(\\'' + session.id + '\\')\">停止</button>' : '') + '</div>' +\n\t\t\t\t(session.error ? '<p style=\"font-size: 0.85rem; color: var(--danger); margin-top: 0.25rem;\">' + escapeHtml(session.error) + '</p>' : '') +\n\t\t\t\t'<pre style=\"font-size: 0.75rem; max-height: 250px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px; margin-top: 0.5rem; white-space: pre-wrap;\">' +\n\t\t\t\t(lines ? escapeHtml(lines) : '等待查询...') + '</pre></div>';\n\t\t\t\n\t\t\tif (session.state === 'active') {\n\t\t\t\tqueryLogTimer = setTimeout(function() { watchQueryLog(session.id); }, 3000);\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function loadNodeLocal() {\n\t\t\tconst el = document.getElementById('tab-nodelocal');\n\t\t\tif (!can('node_local')) {\n\t\t\t\tel.innerHTML = '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">凭据缺少' + capabilityNames.node_local + '权限</p>';\n\t\t\t\treturn;\n\t\t\t}\n\t\t\tel.innerHTML = '<div style=\"text-align: center; padding: 1rem;\"><span class=\"loading\"></span></div>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/nodelocal');\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) throw new Error(data.error || 'Failed to load node-local-dns');\n\t\t\t\t\n\t\t\t\tconst info = data.nodelocal;\n\t\t\t\tif (!info.detected) {\n\t\t\t\t\tel.innerHTML = '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">未检测到 NodeLocal DNSCache (kube-system/node-local-dns)</p>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet vars = '';\n\t\t\t\tfor (const key in (info.variables || {})) {\n\t\t\t\t\tvars += '<div class=\"info-card\"><div class=\"info-label\">' + escapeHtml(key) + '</div><div class=\"info-value\">' + escapeHtml(info.variables[key] || '-') + '</div></div>';\n\t\t\t\t}\n\t\t\t\tconst mirrored = info.mirrored_rules.map(function(r) { return escapeHtml(r.service_name ? r.service_name + '.' + r.namespace : r.namespace); }).join('，');\n\t\t\t\t\n\t\t\t\tel.innerHTML = '<div style=\"display: flex; justify-content: space-between; align-items: center; margin-bottom: 1rem;\">' +\n\t\t\t\t\t'<label style=\"display: flex; gap: 0.5rem; align-items: center; font-size: 0.9rem;\">' +\n\t\t\t\t\t'<input type=\"checkbox\" onchange=\"setMirrorNodeLocal(this.checked)\"' + (data.mirror ? ' checked' : '') + denied('node_local') + '/>修改规则时自动同步到 NodeLocal DNS</label>' +\n\t\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"syncNodeLocal()\"' + denied('node_local') + '>立即同步规则</button></div>' +\n\t\t\t\t\t'<p style=\"font-size: 0.85rem; margin-bottom: 1rem;\">已同步规则: ' + (mirrored || '无') + '</p>' +\n\t\t\t\t\t'<div class=\"service-info\">' + vars + '</div>' +\n\t\t\t\t\t'<h4 style=\"margin-bottom: 0.5rem;\">Corefile（已替换 __PILLAR__ 变量）</h4>' +\n\t\t\t\t\t'<pre style=\"font-size: 0.8rem; max-height: 400px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px;\">' + escapeHtml(info.rendered) + '</pre>';\n\t\t\t} catch (error) {\n\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">加载失败: ' + escapeHtml(error.message) + '</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function syncNodeLocal() {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/nodelocal/sync', { method: 'POST' });\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert(data.error || '同步失败');\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\tloadNodeLocal();\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function setMirrorNodeLocal(enabled) {\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/settings', {\n\t\t\t\t\tmethod: 'PUT',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ mirror_node_local: enabled }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) alert(data.error || '保存失败');\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction showAddRuleForm() {\n\t\t\tdocument.getElementById('add-rule-form').style.display = 'block';\n\t\t}\n\t\t\n\t\tfunction hideAddRuleForm() {\n\t\t\tdocument.getElementById('add-rule-form').style.display = 'none';\n\t\t}\n\t\t\n\t\tasync function addForwardRule(force) {\n\t\t\tconst namespace = document.getElementById('rule-namespace').value.trim();\n\t\t\tconst targetIP = document.getElementById('rule-target-ip').value.trim();\n\t\t\t\n\t\t\tif (!namespace || !targetIP) {\n\t\t\t\talert('请填写完整信息');\n\t\t\t\treturn;\n\t\t\t}\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/rules', {\n\t\t\t\t\tmethod: 'POST',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ namespace: namespace, target_ip: targetIP, force: force === true }),\n\t\t\t\t});\n\t\t\t\tconst data = await response.json();\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tif (data.warnings && data.warnings.length > 0) {\n\t\t\t\t\t\talert('规则已添加，但存在遮蔽:\\n' + data.warnings.map(function(f) { return f.message; }).join('\\n'));\n\t\t\t\t\t}\n\t\t\t\t\tif (data.loop_unverified && data.loop_unverified.length > 0) {\n\t\t\t\t\t\talert('规则已添加，但以下集群无法读取，循环检测不完整:\\n' + data.loop_unverified.join('\\n'));\n\t\t\t\t\t}\n\t\t\t\t\tconst title = document.getElementById('coredns-modal-title').textContent;\n\t\t\t\t\tshowCoreDNSConfig(currentClusterId, title.split(' - ')[0]);\n\t\t\t\t} else if (response.status === 409 && data.findings) {\n\t\t\t\t\tconst messages = data.findings.map(function(f) { return f.message; }).join('\\n');\n\t\t\t\t\tif (confirm('该规则会遮蔽本地资源:\\n' + messages + '\\n\\n仍然添加吗？')) {\n\t\t\t\t\t\taddForwardRule(true);\n\t\t\t\t\t}\n\t\t\t\t} else {\n\t\t\t\t\talert('添加失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function deleteForwardRule(name, isFullFQDN) {\n\t\t\tconst displayName = isFullFQDN ? name + '.svc.cluster.local' : name;\n\t\t\tif (!confirm('确定要删除 ' + displayName + ' 的转发规则吗？')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst encodedName = encodeURIComponent(name);\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/rules/' + encodedName + '?fqdn=' + isFullFQDN, {\n\t\t\t\t\tmethod: 'DELETE',\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tconst title = document.getElementById('coredns-modal-title').textContent;\n\t\t\t\t\tshowCoreDNSConfig(currentClusterId, title.split(' - ')[0]);\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('删除失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function loadBackups() {\n\t\t\tconst el = document.getElementById('tab-backups');\n\t\t\tif (!can('backup')) {\n\t\t\t\tel.innerHTML = '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">凭据缺少' + capabilityNames.backup + '权限，修改前不会备份 Corefile</p>';\n\t\t\t\treturn;\n\t\t\t}\n\t\t\tel.innerHTML = '<span class=\"loading\"></span>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/backups');\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '加载失败') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet html = '<p style=\"font-size: 0.85rem; color: var(--text-secondary); margin-bottom: 1rem;\">每次修改前，当前 Corefile 会复制到 kube-system 中轮换使用的 coredns-backup-&lt;n&gt; ConfigMap，管理器丢失时也可用 kubectl 恢复</p>';\n\t\t\t\tif (data.length === 0) {\n\t\t\t\t\thtml += '<p style=\"color: var(--text-secondary); text-align: center; padding: 2rem;\">暂无备份</p>';\n\t\t\t\t}\n\t\t\t\tfor (let i = 0; i < data.length; i++) {\n\t\t\t\t\tconst backup = data[i];\n\t\t\t\t\thtml += '<div class=\"rule-item\" style=\"flex-wrap: wrap;\">' +\n\t\t\t\t\t\t'<div><strong>' + escapeHtml(backup.name) + '</strong>' +\n\t\t\t\t\t\t'<span style=\"color: var(--text-secondary); font-size: 0.85rem; margin-left: 0.5rem;\">' + new Date(backup.created_at).toLocaleString('zh-CN') + ' · ' + escapeHtml(backup.user || '-') + '</span>' +\n\t\t\t\t\t\t(backup.reason === 'rollback' ? ' <span class=\"badge badge-warning\">回滚前</span>' : '') + '</div>' +\n\t\t\t\t\t\t'<div style=\"display: flex; gap: 0.5rem;\">' +\n\t\t\t\t\t\t'<button class=\"btn btn-secondary\" style=\"padding: 0.5rem 1rem;\" onclick=\"toggleBackup(' + i + ')\">查看</button>' +\n\t\t\t\t\t\t'<button class=\"btn btn-primary\" style=\"padding: 0.5rem 1rem;\" onclick=\"restoreBackup(\\'' + backup.name + '\\')\"' + denied('update_corefile') + '>恢复</button></div>' +\n\t\t\t\t\t\t'<pre id=\"backup-' + i + '\" style=\"display: none; width: 100%; font-size: 0.75rem; max-height: 300px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px; margin-top: 0.5rem;\">' + escapeHtml(backup.corefile) + '</pre></div>';\n\t\t\t\t}\n\t\t\t\tel.innerHTML = html;\n\t\t\t} catch (error) {\n\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction toggleBackup(index) {\n\t\t\tconst el = document.getElementById('backup-' + index);\n\t\t\tel.style.display = el.style.display === 'none' ? 'block' : 'none';\n\t\t}\n\t\t\n\t\tasync function restoreBackup(name) {\n\t\t\tif (!confirm('确定要用 ' + name + ' 恢复 Corefile 吗？当前 Corefile 会先被备份。')) return;\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/backups/' + encodeURIComponent(name) + '/restore', { method: 'POST' });\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\talert('恢复失败: ' + (data.error || '未知错误'));\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\talert('恢复成功！正在验证生效情况。');\n\t\t\t\tconst cluster = clustersById[currentClusterId];\n\t\t\t\tshowCoreDNSConfig(cluster.id, cluster.name);\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t}\n\t\t}\n\t\t\n\t\tasync function loadMigration(target) {\n\t\t\tconst el = document.getElementById('tab-migration');\n\t\t\tel.innerHTML = '<span class=\"loading\"></span>';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns/migration?target=' + encodeURIComponent(target));\n\t\t\t\tconst data = await response.json();\n\t\t\t\tif (!response.ok) {\n\t\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">' + escapeHtml(data.error || '加载失败') + '</div>';\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tlet html = '<div class=\"service-info\">' +\n\t\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">当前版本</div><div class=\"info-value\">' + escapeHtml(data.current_version || '未知') + '</div></div>' +\n\t\t\t\t\t'<div class=\"info-card\"><div class=\"info-label\">Pod 版本</div><div class=\"info-value\">' + escapeHtml((data.pod_versions || []).join(', ') || '-') + '</div></div>' +\n\t\t\t\t\t'</div>';\n\t\t\t\tif (data.message) {\n\t\t\t\t\thtml += '<p style=\"font-size: 0.85rem; color: var(--text-secondary); margin-bottom: 1rem;\">' + escapeHtml(data.message) + '</p>';\n\t\t\t\t}\n\t\t\t\tif (!data.supported) {\n\t\t\t\t\tel.innerHTML = html;\n\t\t\t\t\treturn;\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\thtml += '<div style=\"display: flex; gap: 0.5rem; align-items: center; margin-bottom: 1rem;\"><label class=\"form-label\" style=\"margin: 0;\">目标版本</label>' +\n\t\t\t\t\t'<select id=\"migration-target\" class=\"form-input\" style=\"width: 160px;\" onchange=\"loadMigration(this.value)\">';\n\t\t\t\tfor (let i = 0; i < data.versions.length; i++) {\n\t\t\t\t\tconst v = data.versions[i];\n\t\t\t\t\thtml += '<option value=\"' + v + '\"' + (v === data.target_version ? ' selected' : '') + '>' + v + '</option>';\n\t\t\t\t}\n\t\t\t\thtml += '</select></div>';\n\t\t\t\t\n\t\t\t\tconst severities = {\n\t\t\t\t\tdeprecated: '<span class=\"badge badge-warning\">已弃用</span>',\n\t\t\t\t\tignored: '<span class=\"badge badge-warning\">被忽略</span>',\n\t\t\t\t\tremoved: '<span class=\"badge badge-danger\">已移除</span>',\n\t\t\t\t\tnewdefault: '<span class=\"badge badge-info\">新默认</span>',\n\t\t\t\t\tunsupported: '<span class=\"badge badge-info\">无法迁移</span>',\n\t\t\t\t};\n\t\t\t\tif (data.notices.length === 0) {\n\t\t\t\t\thtml += '<p style=\"color: var(--text-secondary); margin-bottom: 1rem;\">✓ 当前 Corefile 与目标版本兼容，无需迁移</p>';\n\t\t\t\t}\n\t\t\t\tfor (let i = 0; i < data.notices.length; i++) {\n\t\t\t\t\tconst n = data.notices[i];\n\t\t\t\t\thtml += '<div class=\"rule-item\"><span>' + (severities[n.severity] || escapeHtml(n.severity)) + ' ' + escapeHtml(n.message) + '</span></div>';\n\t\t\t\t}\n\t\t\t\t\n\t\t\t\tif (data.migrated && data.changed) {\n\t\t\t\t\thtml += '<div style=\"display: flex; justify-content: space-between; align-items: center; margin: 1rem 0 0.5rem;\">' +\n\t\t\t\t\t\t'<h4>迁移后的 Corefile（预览）</h4>' +\n\t\t\t\t\t\t'<button class=\"btn btn-primary\" onclick=\"useMigratedCorefile()\"' + denied('update_corefile') + '>载入到 Corefile 编辑器</button></div>' +\n\t\t\t\t\t\t'<p style=\"font-size: 0.8rem; color: var(--text-secondary); margin-bottom: 0.5rem;\">迁移会重新格式化 Corefile，注释不会保留。载入后请检查并保存，升级 CoreDNS 前后均可应用。</p>' +\n\t\t\t\t\t\t'<pre id=\"migrated-corefile\" style=\"font-size: 0.75rem; max-height: 400px; overflow: auto; background: var(--bg-tertiary); padding: 0.5rem; border-radius: 4px;\">' + escapeHtml(data.migrated) + '</pre>';\n\t\t\t\t}\n\t\t\t\tel.innerHTML = html;\n\t\t\t} catch (error) {\n\t\t\t\tel.innerHTML = '<div class=\"alert alert-error\">网络错误</div>';\n\t\t\t}\n\t\t}\n\t\t\n\t\tfunction useMigratedCorefile() {\n\t\t\tdocument.getElementById('corefile-editor').value = document.getElementById('migrated-corefile').textContent;\n\t\t\tswitchTab('corefile', document.querySelectorAll('.tab')[1]);\n\t\t}\n\t\t\n\t\tasync function saveCorefile() {\n\t\t\tconst corefile = document.getElementById('corefile-editor').value;\n\t\t\tconst btn = document.getElementById('save-corefile-btn');\n\t\t\t\n\t\t\tbtn.disabled = true;\n\t\t\tbtn.textContent = '保存中...';\n\t\t\t\n\t\t\ttry {\n\t\t\t\tconst response = await fetch('/api/clusters/' + currentClusterId + '/coredns', {\n\t\t\t\t\tmethod: 'PUT',\n\t\t\t\t\theaders: { 'Content-Type': 'application/json' },\n\t\t\t\t\tbody: JSON.stringify({ corefile: corefile }),\n\t\t\t\t});\n\t\t\t\t\n\t\t\t\tif (response.ok) {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('保存成功！CoreDNS 配置已更新，正在验证生效情况。');\n\t\t\t\t\twatchChange(data.change_id);\n\t\t\t\t} else {\n\t\t\t\t\tconst data = await response.json();\n\t\t\t\t\talert('保存失败: ' + (data.error || '未知错误'));\n\t\t\t\t}\n\t\t\t} catch (error) {\n\t\t\t\talert('网络错误');\n\t\t\t} finally {\n\t\t\t\tbtn.disabled = false;\n\t\t\t\tbtn.textContent = '保存修改';\n\t\t\t}\n\t\t}\n\t</script>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
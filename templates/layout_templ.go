@@ -42,7 +42,7 @@ func Layout(title string) templ.Component {
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, " - CoreDNS Manager</title><script src=\"https://unpkg.com/htmx.org@2.0.4\"></script><style>\n\t\t\t:root {\n\t\t\t\t--bg-primary: #f8fafc;\n\t\t\t\t--bg-secondary: #ffffff;\n\t\t\t\t--bg-tertiary: #e2e8f0;\n\t\t\t\t--text-primary: #1e293b;\n\t\t\t\t--text-secondary: #64748b;\n\t\t\t\t--accent: #3b82f6;\n\t\t\t\t--accent-hover: #2563eb;\n\t\t\t\t--success: #22c55e;\n\t\t\t\t--danger: #ef4444;\n\t\t\t\t--warning: #f59e0b;\n\t\t\t\t--border: #cbd5e1;\n\t\t\t}\n\t\t\t\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\t\t\t\n\t\t\tbody {\n\t\t\t\tfont-family: 'Inter', -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;\n\t\t\t\tbackground: linear-gradient(135deg, #e0e7ff 0%, #f0f9ff 50%, #ecfeff 100%);\n\t\t\t\tmin-height: 100vh;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.container {\n\t\t\t\tmax-width: 1400px;\n\t\t\t\tmargin: 0 auto;\n\t\t\t\tpadding: 2rem;\n\t\t\t}\n\t\t\t\n\t\t\t.header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 1.5rem 2rem;\n\t\t\t\tbackground: rgba(255, 255, 255, 0.9);\n\t\t\t\tbackdrop-filter: blur(10px);\n\t\t\t\tborder-bottom: 1px solid var(--border);\n\t\t\t\tposition: sticky;\n\t\t\t\ttop: 0;\n\t\t\t\tz-index: 100;\n\t\t\t\tbox-shadow: 0 1px 3px rgba(0,0,0,0.05);\n\t\t\t}\n\t\t\t\n\t\t\t.logo {\n\t\t\t\tfont-size: 1.5rem;\n\t\t\t\tfont-weight: 700;\n\t\t\t\tbackground: linear-gradient(135deg, var(--accent), #8b5cf6);\n\t\t\t\t-webkit-background-clip: text;\n\t\t\t\t-webkit-text-fill-color: transparent;\n\t\t\t\tbackground-clip: text;\n\t\t\t}\n\t\t\t\n\t\t\t.btn {\n\t\t\t\tpadding: 0.75rem 1.5rem;\n\t\t\t\tborder: none;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tfont-size: 0.9rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcursor: pointer;\n\t\t\t\ttransition: all 0.2s ease;\n\t\t\t\tdisplay: inline-flex;\n\t\t\t\talign-items: center;\n\t\t\t\tgap: 0.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-primary {\n\t\t\t\tbackground: linear-gradient(135deg, var(--accent), #2563eb);\n\t\t\t\tcolor: white;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-primary:hover {\n\t\t\t\ttransform: translateY(-1px);\n\t\t\t\tbox-shadow: 0 4px 12px rgba(59, 130, 246, 0.4);\n\t\t\t}\n\t\t\t\n\t\t\t.btn-danger {\n\t\t\t\tbackground: var(--danger);\n\t\t\t\tcolor: white;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-danger:hover {\n\t\t\t\tbackground: #dc2626;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-secondary {\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.btn-secondary:hover {\n\t\t\t\tbackground: var(--bg-tertiary);\n\t\t\t}\n\t\t\t\n\t\t\t.card {\n\t\t\t\tbackground: rgba(255, 255, 255, 0.85);\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 1rem;\n\t\t\t\tpadding: 1.5rem;\n\t\t\t\tbackdrop-filter: blur(10px);\n\t\t\t\ttransition: all 0.3s ease;\n\t\t\t\tbox-shadow: 0 4px 6px -1px rgba(0, 0, 0, 0.1), 0 2px 4px -1px rgba(0, 0, 0, 0.06);\n\t\t\t}\n\t\t\t\n\t\t\t.card:hover {\n\t\t\t\tborder-color: var(--accent);\n\t\t\t\tbox-shadow: 0 10px 15px -3px rgba(0, 0, 0, 0.1), 0 4px 6px -2px rgba(0, 0, 0, 0.05);\n\t\t\t}\n\t\t\t\n\t\t\t.form-group {\n\t\t\t\tmargin-bottom: 1.25rem;\n\t\t\t}\n\t\t\t\n\t\t\t.form-label {\n\t\t\t\tdisplay: block;\n\t\t\t\tmargin-bottom: 0.5rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t}\n\t\t\t\n\t\t\t.form-input, .form-textarea {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 0.75rem 1rem;\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t\tfont-size: 0.95rem;\n\t\t\t\ttransition: border-color 0.2s ease;\n\t\t\t}\n\t\t\t\n\t\t\t.form-input:focus, .form-textarea:focus {\n\t\t\t\toutline: none;\n\t\t\t\tborder-color: var(--accent);\n\t\t\t\tbox-shadow: 0 0 0 3px rgba(59, 130, 246, 0.1);\n\t\t\t}\n\t\t\t\n\t\t\t.form-textarea {\n\t\t\t\tfont-family: 'Monaco', 'Menlo', monospace;\n\t\t\t\tmin-height: 200px;\n\t\t\t\tresize: vertical;\n\t\t\t}\n\t\t\t\n\t\t\t.alert {\n\t\t\t\tpadding: 1rem;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.alert-error {\n\t\t\t\tbackground: rgba(239, 68, 68, 0.1);\n\t\t\t\tborder: 1px solid var(--danger);\n\t\t\t\tcolor: var(--danger);\n\t\t\t}\n\t\t\t\n\t\t\t.alert-success {\n\t\t\t\tbackground: rgba(34, 197, 94, 0.1);\n\t\t\t\tborder: 1px solid var(--success);\n\t\t\t\tcolor: var(--success);\n\t\t\t}\n\t\t\t\n\t\t\t.badge {\n\t\t\t\tdisplay: inline-flex;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 0.25rem 0.75rem;\n\t\t\t\tborder-radius: 9999px;\n\t\t\t\tfont-size: 0.75rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t}\n\t\t\t\n\t\t\t.badge-success {\n\t\t\t\tbackground: rgba(34, 197, 94, 0.2);\n\t\t\t\tcolor: var(--success);\n\t\t\t}\n\t\t\t\n\t\t\t.badge-danger {\n\t\t\t\tbackground: rgba(239, 68, 68, 0.2);\n\t\t\t\tcolor: var(--danger);\n\t\t\t}\n\t\t\t\n\t\t\t.grid {\n\t\t\t\tdisplay: grid;\n\t\t\t\tgap: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.grid-cols-2 {\n\t\t\t\tgrid-template-columns: repeat(auto-fit, minmax(400px, 1fr));\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-card {\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-name {\n\t\t\t\tfont-size: 1.25rem;\n\t\t\t\tfont-weight: 600;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-info {\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tfont-size: 0.9rem;\n\t\t\t}\n\t\t\t\n\t\t\t.modal {\n\t\t\t\tposition: fixed;\n\t\t\t\tinset: 0;\n\t\t\t\tbackground: rgba(0, 0, 0, 0.7);\n\t\t\t\tdisplay: flex;\n\t\t\t\talign-items: center;\n\t\t\t\tjustify-content: center;\n\t\t\t\tz-index: 1000;\n\t\t\t\tbackdrop-filter: blur(4px);\n\t\t\t}\n\t\t\t\n\t\t\t.modal-content {\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 1rem;\n\t\t\t\tpadding: 2rem;\n\t\t\t\tmax-width: 600px;\n\t\t\t\twidth: 90%;\n\t\t\t\tmax-height: 90vh;\n\t\t\t\toverflow-y: auto;\n\t\t\t\tbox-shadow: 0 25px 50px -12px rgba(0, 0, 0, 0.25);\n\t\t\t}\n\t\t\t\n\t\t\t.modal-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.modal-title {\n\t\t\t\tfont-size: 1.25rem;\n\t\t\t\tfont-weight: 600;\n\t\t\t}\n\t\t\t\n\t\t\t.close-btn {\n\t\t\t\tbackground: none;\n\t\t\t\tborder: none;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tfont-size: 1.5rem;\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\t\t\t\n\t\t\t.close-btn:hover {\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.tabs {\n\t\t\t\tdisplay: flex;\n\t\t\t\tgap: 0.5rem;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t\tborder-bottom: 1px solid var(--border);\n\t\t\t\tpadding-bottom: 0.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.tab {\n\t\t\t\tpadding: 0.5rem 1rem;\n\t\t\t\tbackground: none;\n\t\t\t\tborder: none;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tcursor: pointer;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\ttransition: all 0.2s;\n\t\t\t}\n\t\t\t\n\t\t\t.tab:hover, .tab.active {\n\t\t\t\tbackground: var(--bg-tertiary);\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.rules-list {\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t\tgap: 0.75rem;\n\t\t\t}\n\t\t\t\n\t\t\t.rule-item {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 1rem;\n\t\t\t\tbackground: #f8fafc;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.rule-domain {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tcolor: var(--accent);\n\t\t\t}\n\t\t\t\n\t\t\t.rule-target {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t}\n\t\t\t\n\t\t\t.loading {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\twidth: 1rem;\n\t\t\t\theight: 1rem;\n\t\t\t\tborder: 2px solid var(--border);\n\t\t\t\tborder-top-color: var(--accent);\n\t\t\t\tborder-radius: 50%;\n\t\t\t\tanimation: spin 1s linear infinite;\n\t\t\t}\n\t\t\t\n\t\t\t@keyframes spin {\n\t\t\t\tto { transform: rotate(360deg); }\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-indicator {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-request .htmx-indicator {\n\t\t\t\tdisplay: inline-block;\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-request.htmx-indicator {\n\t\t\t\tdisplay: inline-block;\n\t\t\t}\n\t\t\t\n\t\t\t.coredns-section {\n\t\t\t\tmargin-top: 2rem;\n\t\t\t}\n\t\t\t\n\t\t\t.coredns-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.service-info {\n\t\t\t\tdisplay: grid;\n\t\t\t\tgrid-template-columns: repeat(auto-fit, minmax(200px, 1fr));\n\t\t\t\tgap: 1rem;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.info-card {\n\t\t\t\tbackground: #f8fafc;\n\t\t\t\tpadding: 1rem;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.info-label {\n\t\t\t\tfont-size: 0.8rem;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tmargin-bottom: 0.25rem;\n\t\t\t}\n\t\t\t\n\t\t\t.info-value {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tfont-size: 1rem;\n\t\t\t\tcolor: var(--accent);\n\t\t\t}\n\t\t</style></head><body>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, " - CoreDNS Manager</title><script src=\"https://unpkg.com/htmx.org@2.0.4\"></script><style>\n\t\t\t:root {\n\t\t\t\t--bg-primary: #f8fafc;\n\t\t\t\t--bg-secondary: #ffffff;\n\t\t\t\t--bg-tertiary: #e2e8f0;\n\t\t\t\t--text-primary: #1e293b;\n\t\t\t\t--text-secondary: #64748b;\n\t\t\t\t--accent: #3b82f6;\n\t\t\t\t--accent-hover: #2563eb;\n\t\t\t\t--success: #22c55e;\n\t\t\t\t--danger: #ef4444;\n\t\t\t\t--warning: #f59e0b;\n\t\t\t\t--border: #cbd5e1;\n\t\t\t}\n\t\t\t\n\t\t\t* {\n\t\t\t\tmargin: 0;\n\t\t\t\tpadding: 0;\n\t\t\t\tbox-sizing: border-box;\n\t\t\t}\n\t\t\t\n\t\t\tbody {\n\t\t\t\tfont-family: 'Inter', -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;\n\t\t\t\tbackground: linear-gradient(135deg, #e0e7ff 0%, #f0f9ff 50%, #ecfeff 100%);\n\t\t\t\tmin-height: 100vh;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.container {\n\t\t\t\tmax-width: 1400px;\n\t\t\t\tmargin: 0 auto;\n\t\t\t\tpadding: 2rem;\n\t\t\t}\n\t\t\t\n\t\t\t.header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 1.5rem 2rem;\n\t\t\t\tbackground: rgba(255, 255, 255, 0.9);\n\t\t\t\tbackdrop-filter: blur(10px);\n\t\t\t\tborder-bottom: 1px solid var(--border);\n\t\t\t\tposition: sticky;\n\t\t\t\ttop: 0;\n\t\t\t\tz-index: 100;\n\t\t\t\tbox-shadow: 0 1px 3px rgba(0,0,0,0.05);\n\t\t\t}\n\t\t\t\n\t\t\t.logo {\n\t\t\t\tfont-size: 1.5rem;\n\t\t\t\tfont-weight: 700;\n\t\t\t\tbackground: linear-gradient(135deg, var(--accent), #8b5cf6);\n\t\t\t\t-webkit-background-clip: text;\n\t\t\t\t-webkit-text-fill-color: transparent;\n\t\t\t\tbackground-clip: text;\n\t\t\t}\n\t\t\t\n\t\t\t.btn {\n\t\t\t\tpadding: 0.75rem 1.5rem;\n\t\t\t\tborder: none;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tfont-size: 0.9rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcursor: pointer;\n\t\t\t\ttransition: all 0.2s ease;\n\t\t\t\tdisplay: inline-flex;\n\t\t\t\talign-items: center;\n\t\t\t\tgap: 0.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-primary {\n\t\t\t\tbackground: linear-gradient(135deg, var(--accent), #2563eb);\n\t\t\t\tcolor: white;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-primary:hover {\n\t\t\t\ttransform: translateY(-1px);\n\t\t\t\tbox-shadow: 0 4px 12px rgba(59, 130, 246, 0.4);\n\t\t\t}\n\t\t\t\n\t\t\t.btn-danger {\n\t\t\t\tbackground: var(--danger);\n\t\t\t\tcolor: white;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-danger:hover {\n\t\t\t\tbackground: #dc2626;\n\t\t\t}\n\t\t\t\n\t\t\t.btn-secondary {\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.btn-secondary:hover {\n\t\t\t\tbackground: var(--bg-tertiary);\n\t\t\t}\n\t\t\t\n\t\t\t.card {\n\t\t\t\tbackground: rgba(255, 255, 255, 0.85);\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 1rem;\n\t\t\t\tpadding: 1.5rem;\n\t\t\t\tbackdrop-filter: blur(10px);\n\t\t\t\ttransition: all 0.3s ease;\n\t\t\t\tbox-shadow: 0 4px 6px -1px rgba(0, 0, 0, 0.1), 0 2px 4px -1px rgba(0, 0, 0, 0.06);\n\t\t\t}\n\t\t\t\n\t\t\t.card:hover {\n\t\t\t\tborder-color: var(--accent);\n\t\t\t\tbox-shadow: 0 10px 15px -3px rgba(0, 0, 0, 0.1), 0 4px 6px -2px rgba(0, 0, 0, 0.05);\n\t\t\t}\n\t\t\t\n\t\t\t.form-group {\n\t\t\t\tmargin-bottom: 1.25rem;\n\t\t\t}\n\t\t\t\n\t\t\t.form-label {\n\t\t\t\tdisplay: block;\n\t\t\t\tmargin-bottom: 0.5rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t}\n\t\t\t\n\t\t\t.form-input, .form-textarea {\n\t\t\t\twidth: 100%;\n\t\t\t\tpadding: 0.75rem 1rem;\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t\tfont-size: 0.95rem;\n\t\t\t\ttransition: border-color 0.2s ease;\n\t\t\t}\n\t\t\t\n\t\t\t.form-input:focus, .form-textarea:focus {\n\t\t\t\toutline: none;\n\t\t\t\tborder-color: var(--accent);\n\t\t\t\tbox-shadow: 0 0 0 3px rgba(59, 130, 246, 0.1);\n\t\t\t}\n\t\t\t\n\t\t\t.form-textarea {\n\t\t\t\tfont-family: 'Monaco', 'Menlo', monospace;\n\t\t\t\tmin-height: 200px;\n\t\t\t\tresize: vertical;\n\t\t\t}\n\t\t\t\n\t\t\t.alert {\n\t\t\t\tpadding: 1rem;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.alert-error {\n\t\t\t\tbackground: rgba(239, 68, 68, 0.1);\n\t\t\t\tborder: 1px solid var(--danger);\n\t\t\t\tcolor: var(--danger);\n\t\t\t}\n\t\t\t\n\t\t\t.alert-success {\n\t\t\t\tbackground: rgba(34, 197, 94, 0.1);\n\t\t\t\tborder: 1px solid var(--success);\n\t\t\t\tcolor: var(--success);\n\t\t\t}\n\t\t\t\n\t\t\t.badge {\n\t\t\t\tdisplay: inline-flex;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 0.25rem 0.75rem;\n\t\t\t\tborder-radius: 9999px;\n\t\t\t\tfont-size: 0.75rem;\n\t\t\t\tfont-weight: 500;\n\t\t\t}\n\t\t\t\n\t\t\t.badge-success {\n\t\t\t\tbackground: rgba(34, 197, 94, 0.2);\n\t\t\t\tcolor: var(--success);\n\t\t\t}\n\t\t\t\n\t\t\t.badge-danger {\n\t\t\t\tbackground: rgba(239, 68, 68, 0.2);\n\t\t\t\tcolor: var(--danger);\n\t\t\t}\n\t\t\t\n\t\t\t.badge-info {\n\t\t\t\tbackground: rgba(59, 130, 246, 0.15);\n\t\t\t\tcolor: var(--accent);\n\t\t\t}\n\t\t\t\n\t\t\t.badge-warning {\n\t\t\t\tbackground: rgba(245, 158, 11, 0.2);\n\t\t\t\tcolor: var(--warning);\n\t\t\t}\n\t\t\t\n\t\t\t.grid {\n\t\t\t\tdisplay: grid;\n\t\t\t\tgap: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.grid-cols-2 {\n\t\t\t\tgrid-template-columns: repeat(auto-fit, minmax(400px, 1fr));\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-card {\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-name {\n\t\t\t\tfont-size: 1.25rem;\n\t\t\t\tfont-weight: 600;\n\t\t\t}\n\t\t\t\n\t\t\t.cluster-info {\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tfont-size: 0.9rem;\n\t\t\t}\n\t\t\t\n\t\t\t.modal {\n\t\t\t\tposition: fixed;\n\t\t\t\tinset: 0;\n\t\t\t\tbackground: rgba(0, 0, 0, 0.7);\n\t\t\t\tdisplay: flex;\n\t\t\t\talign-items: center;\n\t\t\t\tjustify-content: center;\n\t\t\t\tz-index: 1000;\n\t\t\t\tbackdrop-filter: blur(4px);\n\t\t\t}\n\t\t\t\n\t\t\t.modal-content {\n\t\t\t\tbackground: #ffffff;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t\tborder-radius: 1rem;\n\t\t\t\tpadding: 2rem;\n\t\t\t\tmax-width: 600px;\n\t\t\t\twidth: 90%;\n\t\t\t\tmax-height: 90vh;\n\t\t\t\toverflow-y: auto;\n\t\t\t\tbox-shadow: 0 25px 50px -12px rgba(0, 0, 0, 0.25);\n\t\t\t}\n\t\t\t\n\t\t\t.modal-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.modal-title {\n\t\t\t\tfont-size: 1.25rem;\n\t\t\t\tfont-weight: 600;\n\t\t\t}\n\t\t\t\n\t\t\t.close-btn {\n\t\t\t\tbackground: none;\n\t\t\t\tborder: none;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tfont-size: 1.5rem;\n\t\t\t\tcursor: pointer;\n\t\t\t}\n\t\t\t\n\t\t\t.close-btn:hover {\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.tabs {\n\t\t\t\tdisplay: flex;\n\t\t\t\tgap: 0.5rem;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t\tborder-bottom: 1px solid var(--border);\n\t\t\t\tpadding-bottom: 0.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.tab {\n\t\t\t\tpadding: 0.5rem 1rem;\n\t\t\t\tbackground: none;\n\t\t\t\tborder: none;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tcursor: pointer;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\ttransition: all 0.2s;\n\t\t\t}\n\t\t\t\n\t\t\t.tab:hover, .tab.active {\n\t\t\t\tbackground: var(--bg-tertiary);\n\t\t\t\tcolor: var(--text-primary);\n\t\t\t}\n\t\t\t\n\t\t\t.rules-list {\n\t\t\t\tdisplay: flex;\n\t\t\t\tflex-direction: column;\n\t\t\t\tgap: 0.75rem;\n\t\t\t}\n\t\t\t\n\t\t\t.rule-item {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tpadding: 1rem;\n\t\t\t\tbackground: #f8fafc;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.rule-domain {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tcolor: var(--accent);\n\t\t\t}\n\t\t\t\n\t\t\t.rule-target {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t}\n\t\t\t\n\t\t\t.loading {\n\t\t\t\tdisplay: inline-block;\n\t\t\t\twidth: 1rem;\n\t\t\t\theight: 1rem;\n\t\t\t\tborder: 2px solid var(--border);\n\t\t\t\tborder-top-color: var(--accent);\n\t\t\t\tborder-radius: 50%;\n\t\t\t\tanimation: spin 1s linear infinite;\n\t\t\t}\n\t\t\t\n\t\t\t@keyframes spin {\n\t\t\t\tto { transform: rotate(360deg); }\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-indicator {\n\t\t\t\tdisplay: none;\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-request .htmx-indicator {\n\t\t\t\tdisplay: inline-block;\n\t\t\t}\n\t\t\t\n\t\t\t.htmx-request.htmx-indicator {\n\t\t\t\tdisplay: inline-block;\n\t\t\t}\n\t\t\t\n\t\t\t.coredns-section {\n\t\t\t\tmargin-top: 2rem;\n\t\t\t}\n\t\t\t\n\t\t\t.coredns-header {\n\t\t\t\tdisplay: flex;\n\t\t\t\tjustify-content: space-between;\n\t\t\t\talign-items: center;\n\t\t\t\tmargin-bottom: 1rem;\n\t\t\t}\n\t\t\t\n\t\t\t.service-info {\n\t\t\t\tdisplay: grid;\n\t\t\t\tgrid-template-columns: repeat(auto-fit, minmax(200px, 1fr));\n\t\t\t\tgap: 1rem;\n\t\t\t\tmargin-bottom: 1.5rem;\n\t\t\t}\n\t\t\t\n\t\t\t.info-card {\n\t\t\t\tbackground: #f8fafc;\n\t\t\t\tpadding: 1rem;\n\t\t\t\tborder-radius: 0.5rem;\n\t\t\t\tborder: 1px solid var(--border);\n\t\t\t}\n\t\t\t\n\t\t\t.info-label {\n\t\t\t\tfont-size: 0.8rem;\n\t\t\t\tcolor: var(--text-secondary);\n\t\t\t\tmargin-bottom: 0.25rem;\n\t\t\t}\n\t\t\t\n\t\t\t.info-value {\n\t\t\t\tfont-family: monospace;\n\t\t\t\tfont-size: 1rem;\n\t\t\t\tcolor: var(--accent);\n\t\t\t}\n\t\t</style></head><body>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}